@@ -2,25 +2,16 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api/router"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/client"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/app"
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/helper"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
-	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
-	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/gin-contrib/cors"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
@@ -40,20 +31,6 @@ func main() {
 		log.Fatal("unmarshal yaml error", err)
 		return
 	}
-	var sqsClient *client.SQSClient
-	if cfg.IsDevEnv() {
-		storage.NewDevLocalClient(cfg.DB.Table, cfg.DB.Host, cfg.DB.Port)
-		sqsClient = client.NewDevSQSClient(cfg.SQS.URL, cfg.SQS.Host, cfg.SQS.Port)
-	} else {
-		if err := storage.NewDynamoClient(context.Background(), cfg.DB.Region, cfg.DB.Table); err != nil {
-			log.Fatalf(fmt.Sprintf("Failed to create dynamo client: %s", err))
-		}
-		sqsClient, err = client.NewSQSClient(context.Background(), cfg.SQS.Region, cfg.SQS.URL)
-		if err != nil {
-			log.Fatalf(fmt.Sprintf("Failed to create sqs client: %s", err))
-		}
-	}
-
 	secret, err := os.ReadFile(os.Getenv(cfg.Secret))
 	if err != nil {
 		log.Fatal("read jwt secret error", err)
@@ -67,72 +44,20 @@ func main() {
 		return
 	}
 
-	prot := cfg.HttpPort
-	ethClient, err := ethclient.Dial(cfg.EthUrl)
-	if err != nil {
-		log.Fatalf(fmt.Sprintf("Failed to connect ethereum: %s", err))
-	}
-	token, err := contract.CreateContract(cfg.Token.FilePath, cfg.Token.Address)
-	if err != nil {
-		log.Fatalf(fmt.Sprintf("Failed to create contract: %s", err))
+	if cfg.IsDevEnv() {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
 	}
-	chainId, err := ethClient.ChainID(context.Background())
+	application, err := app.New(context.Background(), cfg, string(owner), registry.DialRPC)
 	if err != nil {
-		log.Fatalf(fmt.Sprintf("Failed to get chain id: %s", err))
-	}
-	ercService := erc20.NewERC20Service(ethClient, token, chainId, cfg.Token.Decimals)
-
-	api.NewProductApi(time.Minute * 10)
-	api.NewOrderApi()
-	api.NewPaymentApi(ercService, ethClient, sqsClient, cfg.Token.Address)
-	api.NewUserApi(ethClient)
-	cfg.HttpPort = prot
-	if err := startServer(cfg, string(owner)); err != nil {
-		log.Fatalf(fmt.Sprintf("Failed to start server: %s", err))
-	}
-}
-
-func startServer(cfg *config.AppConfig, owner string) error {
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.HttpPort),
-		Handler: initEngine(cfg, owner),
+		log.Fatalf(fmt.Sprintf("Failed to create app: %s", err))
 	}
 	ctx, cancel := context.WithCancel(context.Background())
-
 	go listenToSystemSignals(cancel)
-
-	go func() {
-		<-ctx.Done()
-		if err := server.Shutdown(context.Background()); err != nil {
-			log.Fatalf(fmt.Sprintf("Failed to shutdown server: %s", err))
-		}
-	}()
-	log.Println("Server started success")
-	err := server.ListenAndServe()
-	if errors.Is(err, http.ErrServerClosed) {
-		log.Println("Server was shutdown gracefully")
-		return nil
+	if err := application.Run(ctx); err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to start server: %s", err))
 	}
-	return err
-}
-
-func initEngine(cfg *config.AppConfig, owner string) *gin.Engine {
-	gin.SetMode(func() string {
-		if cfg.IsDevEnv() {
-			return gin.DebugMode
-		}
-		return gin.ReleaseMode
-	}())
-	engine := gin.New()
-	engine.Use(cors.Default())
-	engine.Use(gin.CustomRecovery(func(c *gin.Context, err interface{}) {
-		c.AbortWithStatusJSON(http.StatusOK, gin.H{
-			"code": 500,
-			"msg":  "Service internal exception!",
-		})
-	}))
-	router.RegisterRoutes(engine, owner)
-	return engine
 }
 
 func listenToSystemSignals(cancel context.CancelFunc) {
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/app"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/deposit"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/model"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/hdwallet"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	interval := flag.Duration("interval", 0, "run as a worker with the interval, run once when it is 0")
+	flag.Parse()
+
+	godotenv.Load()
+	path := os.Getenv("CONFIG")
+	cfg := new(config.AppConfig)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("read yaml error", err)
+		return
+	}
+	err = yaml.Unmarshal(data, &cfg)
+	if err != nil {
+		log.Fatal("unmarshal yaml error", err)
+		return
+	}
+	if err := errors.Join(cfg.ValidateChains(), cfg.ValidateTreasuries(), cfg.ValidateDeposit()); err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to validate config: %s", err))
+	}
+	if cfg.Deposit == nil {
+		log.Fatal("deposit is not configured")
+		return
+	}
+	key, err := sweepKey(cfg.Deposit)
+	if err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to read deposit key: %s", err))
+	}
+	dynamo, err := app.NewDynamo(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to create dynamo client: %s", err))
+	}
+
+	chains, err := registry.Build(context.Background(), cfg, registry.DialRPC)
+	if err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to connect chains: %s", err))
+	}
+	srv := deposit.NewService(model.NewOrderRepository(dynamo), chains, key)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if *interval <= 0 {
+		if err := run(ctx, srv); err != nil {
+			log.Fatalf(fmt.Sprintf("Failed to settle deposits: %s", err))
+		}
+		return
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := run(ctx, srv); err != nil {
+			log.Printf("Failed to settle deposits: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepKey - the xprv of the deposit addresses, it is stored apart from the
+// config and must derive the configured xpub. The deposits are only watched
+// when no key is configured.
+func sweepKey(info *config.Deposit) (*hdwallet.ExtendedKey, error) {
+	if info.Key == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(os.Getenv(info.Key))
+	if err != nil {
+		return nil, err
+	}
+	key, err := hdwallet.ParseExtendedKey(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsPrivate() || key.Neuter().String() != info.XPub {
+		return nil, errors.New("key does not match the xpub")
+	}
+	return key, nil
+}
+
+func run(ctx context.Context, srv *deposit.Service) error {
+	report, err := srv.Run(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/monitor"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -20,8 +21,10 @@ import (
 )
 
 var (
-	Ether *ethclient.Client
-	db    *dynamodb.Client
+	// Ethers - the clients by chain id, 0 is the client of the RPC env for
+	// the requests without a chain id.
+	Ethers = make(map[uint64]chain.Client)
+	db     *dynamodb.Client
 )
 var (
 	TimeOut               = time.Minute * 3
@@ -30,6 +33,8 @@ var (
 	ErrTimeout      error = errors.New("timeout")
 	ErrMonitor      error = errors.New("monitor error")
 	ErrUpdateTrans  error = errors.New("update transaction error")
+	ErrRecipient    error = errors.New("invalid recipient")
+	ErrChain        error = errors.New("chain not supported")
 )
 
 func Handler(ctx context.Context, sqsEvent events.SQSEvent) error {
@@ -42,7 +47,10 @@ func Handler(ctx context.Context, sqsEvent events.SQSEvent) error {
 		return errors.Join(ErrUnmarshal, err)
 	}
 
-	data, stop, errChan := monitor.Monitor(Ether, request)
+	client, err := getClient(ctx, request.ChainId)
+	if err != nil {
+		return errors.Join(ErrChain, err)
+	}
 	ctx, cancel := context.WithTimeout(ctx, TimeOut)
 	defer cancel()
 
@@ -52,39 +60,153 @@ func Handler(ctx context.Context, sqsEvent events.SQSEvent) error {
 	trans.TxHash = request.TxHash
 	trans.Table = request.Table
 
-	select {
-	case <-ctx.Done():
-		stop()
-		trans.Status = protos.StatusMonitorFailed
-		if err := monitor.UpdateTransStatus(ctx, db, trans); err != nil {
-			return errors.Join(ErrUpdateTrans, err)
-		}
-		return errors.Join(ErrTimeout, ctx.Err())
-	case err := <-errChan:
-		stop()
-		trans.Status = protos.StatusMonitorFailed
-		if dberr := monitor.UpdateTransStatus(ctx, db, trans); dberr != nil {
-			return errors.Join(ErrUpdateTrans, dberr)
+	if request.Refund != nil {
+		return handleRefund(ctx, client, request)
+	}
+	if request.Native {
+		return handleNative(ctx, client, request, trans)
+	}
+	if request.Nonce != "" {
+		return handleAuthorization(ctx, client, request, trans)
+	}
+
+	data, stop, errChan := monitor.Monitor(client, request)
+	// the wallet can replace the payment, its nonce is checked meanwhile
+	ticker := time.NewTicker(monitor.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			trans.Status = protos.StatusMonitorFailed
+			// the context is done, the status is stored without it
+			if err := monitor.UpdateTransStatus(context.Background(), db, trans); err != nil {
+				return errors.Join(ErrUpdateTrans, err)
+			}
+			return errors.Join(ErrTimeout, ctx.Err())
+		case err := <-errChan:
+			stop()
+			trans.Status = protos.StatusMonitorFailed
+			if dberr := monitor.UpdateTransStatus(ctx, db, trans); dberr != nil {
+				return errors.Join(ErrUpdateTrans, dberr)
+			}
+			return errors.Join(ErrMonitor, err)
+		case <-ticker.C:
+			tx, receipt, err := monitor.Replacement(ctx, client, request)
+			if err != nil || tx == nil {
+				// the client errors are retried on the next tick
+				continue
+			}
+			stop()
+			trans.TxHash = tx.Hash().Hex()
+			trans.Status = protos.StatusPaid
+			replaceErr := monitor.CheckReplacement(tx, receipt, request)
+			if replaceErr != nil {
+				trans.Status = protos.StatusPaidFailed
+			}
+			if err := monitor.UpdateTransStatus(ctx, db, trans); err != nil {
+				return errors.Join(ErrUpdateTrans, err)
+			}
+			if replaceErr != nil {
+				return errors.Join(ErrMonitor, replaceErr)
+			}
+			return nil
+		case vLog := <-data:
+			if len(vLog.Topics) != 3 {
+				return errors.Join(ErrInvalidEvent, errors.New("the event topics error"))
+			}
+			if !monitor.IsRecipient(vLog, request.To) {
+				// requests without a recipient are left to the reconciliation
+				trans.Status = protos.StatusPaidFailed
+				if request.To == "" {
+					trans.Status = protos.StatusMonitorFailed
+				}
+				if err := monitor.UpdateTransStatus(ctx, db, trans); err != nil {
+					return errors.Join(ErrUpdateTrans, err)
+				}
+				return errors.Join(ErrRecipient, fmt.Errorf("transfer is not sent to %s", request.To))
+			}
+			trans.Status = protos.StatusPaid
+			if err := monitor.UpdateTransStatus(ctx, db, trans); err != nil {
+				return errors.Join(ErrUpdateTrans, err)
+			}
+			return nil
 		}
+	}
+}
+
+// handleNative - native transfers are monitored by the receipt and the value,
+// the transaction replacing the payment is stored as the payment.
+func handleNative(ctx context.Context, client chain.Client, request *protos.CreateMonitorRequest, trans *protos.UpdateTrans) error {
+	status, txHash, err := monitor.MonitorNative(ctx, client, request)
+	trans.Status, trans.TxHash = status, txHash
+	// the status is stored even when the monitor timed out
+	if dbErr := monitor.UpdateTransStatus(context.Background(), db, trans); dbErr != nil {
+		return errors.Join(ErrUpdateTrans, dbErr)
+	}
+	if err != nil {
 		return errors.Join(ErrMonitor, err)
-	case vLog := <-data:
-		if len(vLog.Topics) != 3 {
-			return errors.Join(ErrInvalidEvent, errors.New("the event topics error"))
-		}
-		trans.Status = protos.StatusPaid
-		if err := monitor.UpdateTransStatus(ctx, db, trans); err != nil {
-			return errors.Join(ErrUpdateTrans, err)
-		}
-		return nil
 	}
+	return nil
 }
-func main() {
-	godotenv.Load()
-	client, err := ethclient.Dial(os.Getenv("RPC"))
+
+// handleAuthorization - authorizations are monitored by the nonce, the
+// transaction which used it is stored as the payment.
+func handleAuthorization(ctx context.Context, client chain.Client, request *protos.CreateMonitorRequest, trans *protos.UpdateTrans) error {
+	status, txHash, err := monitor.MonitorAuthorization(ctx, client, request)
+	trans.Status, trans.TxHash = status, txHash
+	if dbErr := monitor.UpdateTransStatus(context.Background(), db, trans); dbErr != nil {
+		return errors.Join(ErrUpdateTrans, dbErr)
+	}
 	if err != nil {
-		panic(err)
+		return errors.Join(ErrMonitor, err)
 	}
-	Ether = client
+	return nil
+}
+
+// handleRefund - refunds are monitored by the receipt, the recipient is the
+// buyer of the order. A refund which is not mined in time stays pending and
+// the request is retried by sqs.
+func handleRefund(ctx context.Context, client chain.Client, request *protos.CreateMonitorRequest) error {
+	status, txHash, err := monitor.MonitorRefund(ctx, client, request)
+	if status == protos.RefundUnknow {
+		return errors.Join(ErrMonitor, err)
+	}
+	request.TxHash = txHash
+	if dbErr := monitor.UpdateRefundStatus(context.Background(), db, request, request.To, status); dbErr != nil {
+		return errors.Join(ErrUpdateTrans, dbErr)
+	}
+	if err != nil {
+		return errors.Join(ErrMonitor, err)
+	}
+	return nil
+}
+
+// getClient - connect the rpc of the chain from the RPC_<chain id> env, the
+// clients are kept between the invocations.
+func getClient(ctx context.Context, chainId uint64) (chain.Client, error) {
+	if client, ok := Ethers[chainId]; ok {
+		return client, nil
+	}
+	url := os.Getenv("RPC")
+	if chainId != 0 {
+		url = os.Getenv(fmt.Sprintf("RPC_%d", chainId))
+	}
+	if url == "" {
+		return nil, fmt.Errorf("rpc of chain %d not found", chainId)
+	}
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	Ethers[chainId] = client
+	return client, nil
+}
+
+func main() {
+	godotenv.Load()
+	var err error
 	var cfg aws.Config
 	if os.Getenv("ENV") == "dev" {
 		cfg, _ = config.LoadDefaultConfig(context.TODO(),
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/app"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/migrate"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the pending migrations without applying them")
+	flag.Parse()
+
+	godotenv.Load()
+	path := os.Getenv("CONFIG")
+	cfg := new(config.AppConfig)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("read yaml error", err)
+		return
+	}
+	err = yaml.Unmarshal(data, &cfg)
+	if err != nil {
+		log.Fatal("unmarshal yaml error", err)
+		return
+	}
+	dynamo, err := app.NewDynamo(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to create dynamo client: %s", err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	applied, err := migrate.Up(ctx, dynamo, migrate.Migrations, *dryRun)
+	for _, m := range applied {
+		if *dryRun {
+			log.Printf("pending migration %d %s", m.Version, m.Name)
+		} else {
+			log.Printf("applied migration %d %s", m.Version, m.Name)
+		}
+	}
+	if err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to migrate table %s: %s", dynamo.Table, err))
+	}
+	log.Printf("table %s is up to date", dynamo.Table)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/app"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/reconcile"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/model"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the settlements without updating the orders")
+	interval := flag.Duration("interval", 0, "run as a worker with the interval, run once when it is 0")
+	stale := flag.Duration("stale", reconcile.StaleAfter, "settle the orders not updated within the duration, longer than the monitor timeout")
+	flag.Parse()
+
+	godotenv.Load()
+	path := os.Getenv("CONFIG")
+	cfg := new(config.AppConfig)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("read yaml error", err)
+		return
+	}
+	err = yaml.Unmarshal(data, &cfg)
+	if err != nil {
+		log.Fatal("unmarshal yaml error", err)
+		return
+	}
+	if err := errors.Join(cfg.ValidateChains(), cfg.ValidateTreasuries()); err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to validate config: %s", err))
+	}
+	dynamo, err := app.NewDynamo(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to create dynamo client: %s", err))
+	}
+
+	chains, err := registry.Build(context.Background(), cfg, registry.DialRPC)
+	if err != nil {
+		log.Fatalf(fmt.Sprintf("Failed to connect chains: %s", err))
+	}
+	reconciler := reconcile.NewReconciler(model.NewOrderRepository(dynamo), chains, *stale)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if *interval <= 0 {
+		if err := run(ctx, reconciler, *dryRun); err != nil {
+			log.Fatalf(fmt.Sprintf("Failed to reconcile orders: %s", err))
+		}
+		return
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := run(ctx, reconciler, *dryRun); err != nil {
+			log.Printf("Failed to reconcile orders: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func run(ctx context.Context, reconciler *reconcile.Reconciler, dryRun bool) error {
+	report, err := reconciler.Run(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidETag = errors.New("invalid etag")
+
+// setETag - the version of the item is its ETag.
+func setETag(ctx *gin.Context, version uint64) {
+	ctx.Header("ETag", fmt.Sprintf("%q", strconv.FormatUint(version, 10)))
+}
+
+// ifMatch - the version of the If-Match header, zero when the header is
+// missing or matches any version.
+func ifMatch(ctx *gin.Context) (uint64, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil {
+		return 0, errors.Join(ErrInvalidETag, fmt.Errorf("if-match %s", header))
+	}
+	return version, nil
+}
+
+// operationFailed - an invalid update mask is a wrong parameter and a
+// failed condition of the write is a conflict with another write, the other
+// errors are internal.
+func operationFailed(ctx *gin.Context, err error) {
+	if errors.Is(err, storage.ErrInvalidUpdateMask) {
+		utils.InvalidParamErr.Message = fmt.Sprintf("Please enter correct update mask, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	if storage.IsConditionalCheckFailed(err) {
+		utils.ConflictErr.Message = "The resource is modified by another request, please get it and try again."
+		utils.Response(ctx, http.StatusConflict, utils.ConflictErr, nil)
+		return
+	}
+	utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+	utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIfMatch(t *testing.T) {
+	for header, want := range map[string]uint64{"": 0, "*": 0, `"3"`: 3, `W/"4"`: 4, "5": 5} {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPatch, "/", nil)
+		ctx.Request.Header.Set("If-Match", header)
+		got, err := ifMatch(ctx)
+		if err != nil || got != want {
+			t.Fatalf("if-match %s is %d %v, not %d", header, got, err, want)
+		}
+	}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPatch, "/", nil)
+	ctx.Request.Header.Set("If-Match", `"abc"`)
+	if _, err := ifMatch(ctx); !errors.Is(err, ErrInvalidETag) {
+		t.Fatalf("expected an invalid etag, got %v", err)
+	}
+}
+
+func TestUpdateProductConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	products := memory.NewProductRepository()
+	if err := products.PutProduct(context.Background(), protos.Product{Id: "p1", Name: "coffee", Price: 4.5}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewProductApi(products, time.Minute, "USD")
+	engine := gin.New()
+	engine.GET("/product/:productId", h.GetProduct)
+	engine.PATCH("/product/:productId", h.UpdateProduct)
+
+	patch := func(etag string, mask ...string) *httptest.ResponseRecorder {
+		if len(mask) == 0 {
+			mask = []string{`"price"`}
+		}
+		body := `{"product_id":"p1","product":{"price":3,"created_at":1},"update_mask":[` + strings.Join(mask, ",") + `]}`
+		req := httptest.NewRequest(http.MethodPatch, "/product/p1", strings.NewReader(body))
+		req.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/product/p1", nil))
+	etag := w.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("unexpected etag %s", etag)
+	}
+	if w = patch(etag); w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("unexpected response %d %s %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+	// the etag of the first read is stale
+	if w = patch(etag); w.Code != http.StatusConflict {
+		t.Fatalf("expected a conflict, got %d %s", w.Code, w.Body)
+	}
+
+	// the creation time is not a mutable field
+	if w = patch(`"2"`, `"created_at"`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), fmt.Sprintf(`"code":%d`, utils.ErrorCodeOfInvalidParams)) {
+		t.Fatalf("expected an invalid update mask, got %d %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/product/p1", nil))
+	if w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("the cached product is stale, etag %s", w.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateIgnoresBodyVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	buyer := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+	users := memory.NewUserRepository()
+	if err := users.PutUser(ctx, protos.User{PublicAddress: buyer, Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	products := memory.NewProductRepository()
+	if err := products.PutProduct(ctx, protos.Product{Id: "p1", Name: "coffee", Price: 4.5}); err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	engine.PATCH("/user", func(ctx *gin.Context) {
+		ctx.Set("access_token", &protos.UserToken{PublicAddress: buyer})
+	}, NewUserApi(nil, users).UpdateUser)
+	engine.PATCH("/product/:productId", NewProductApi(products, time.Minute, "USD").UpdateProduct)
+
+	patch := func(path, body, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		if etag != "" {
+			req.Header.Set("If-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	user := `{"publicAddress":"` + buyer + `","user":{"name":"bob","version":9},"updateMask":["name"]}`
+	product := `{"product_id":"p1","product":{"price":3,"version":9},"update_mask":["price"]}`
+
+	// without If-Match the item is written at the version it was read
+	for path, body := range map[string]string{"/user": user, "/product/p1": product} {
+		if w := patch(path, body, ""); w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+			t.Fatalf("%s: unexpected response %d %s %s", path, w.Code, w.Header().Get("ETag"), w.Body)
+		}
+		// the If-Match version wins over the version of the body
+		if w := patch(path, body, `"1"`); w.Code != http.StatusConflict {
+			t.Fatalf("%s: expected a conflict, got %d %s", path, w.Code, w.Body)
+		}
+	}
+}
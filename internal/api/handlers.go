@@ -0,0 +1,11 @@
+package api
+
+// Handlers - the handlers of the routes of one server, they are built by
+// the app and share nothing with the handlers of another server.
+type Handlers struct {
+	User    *userApi
+	Product *productApi
+	Order   *orderApi
+	Payment *paymentApi
+	Refund  *refundApi
+}
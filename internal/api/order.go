@@ -1,28 +1,93 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api/services"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/hdwallet"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/oracle"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
 	"github.com/gin-gonic/gin"
 )
 
-var OrderApi *orderApi
-
 type orderApi struct {
 	srv     services.OrderService
 	product services.ProductService
+	chains  *registry.Chains
+	quoter  *oracle.Quoter
+	// currency - the currency of the products without one
+	currency string
+	// deposit - the xpub of the deposit addresses, nil when the mode is off
+	deposit *hdwallet.ExtendedKey
+}
+
+func NewOrderApi(orders storage.OrderRepository, users storage.UserRepository, products storage.ProductRepository, chains *registry.Chains, quoter *oracle.Quoter, currency string, deposit *hdwallet.ExtendedKey) *orderApi {
+	return &orderApi{
+		srv:      services.NewOrderService(orders, users),
+		product:  services.NewProductService(products),
+		chains:   chains,
+		quoter:   quoter,
+		currency: currency,
+		deposit:  deposit,
+	}
 }
 
-func NewOrderApi() *orderApi {
-	OrderApi = &orderApi{
-		srv:     services.NewOrderService(),
-		product: services.NewProductService(),
+// depositAddress - derive the unused deposit address of the order, the
+// transfers to it are watched from the current block.
+func (o *orderApi) depositAddress(ctx *gin.Context, c *registry.Chain, order *protos.Order) error {
+	block, err := c.Client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		index, err := o.srv.NextDepositIndex(ctx)
+		if err != nil {
+			return err
+		}
+		child, err := o.deposit.Child(index)
+		if errors.Is(err, hdwallet.ErrDerive) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		address, err := child.Address()
+		if err != nil {
+			return err
+		}
+		order.DepositAddress = address.Hex()
+		order.DepositIndex = index
+		order.DepositBlock = block
+		return nil
 	}
-	return OrderApi
+}
+
+// quote - lock the amount of the token for the fiat total of the order.
+func (o *orderApi) quote(ctx *gin.Context, order *protos.Order) error {
+	chain, err := o.chains.Get(order.ChainId)
+	if err != nil {
+		return err
+	}
+	decimals := chain.Native.Decimals
+	if !chain.IsNative(order.Token) {
+		payToken, err := chain.Tokens.Get(order.Token)
+		if err != nil {
+			return err
+		}
+		decimals = payToken.Decimals
+	}
+	quote, err := o.quoter.Quote(ctx, order.Token, decimals, order.Currency, order.Total)
+	if err != nil {
+		return err
+	}
+	order.Amount = quote.Amount
+	order.Quote = quote
+	return nil
 }
 
 func (o *orderApi) GetOrder(ctx *gin.Context) {
@@ -45,6 +110,7 @@ func (o *orderApi) GetOrder(ctx *gin.Context) {
 		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
 		return
 	}
+	setETag(ctx, data.Version)
 	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
 }
 
@@ -98,7 +164,34 @@ func (o *orderApi) CreateOrder(ctx *gin.Context) {
 		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
 		return
 	}
-	var total float64
+
+	// the order is paid with the default chain and token when they are not chosen
+	chain, err := o.chains.Get(order.ChainId)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please enter accepted chain."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	order.ChainId = chain.Id.Uint64()
+	if chain.IsNative(order.Token) {
+		order.Token = chain.Native.Symbol
+	} else {
+		payToken, err := chain.Tokens.Get(order.Token)
+		if err != nil {
+			utils.InvalidParamErr.Message = "Please enter accepted token."
+			utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+			return
+		}
+		order.Token = payToken.Symbol
+	}
+	// legacy clients send the total of the catalog as the amount
+	if order.Total == 0 {
+		order.Total = order.Amount
+	}
+	var (
+		total    float64
+		currency string
+	)
 	for _, product := range order.ProductIds {
 		info, err := o.product.GetProduct(ctx, product.Id)
 		if err != nil {
@@ -106,13 +199,43 @@ func (o *orderApi) CreateOrder(ctx *gin.Context) {
 			utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
 			return
 		}
+		if info.Currency == "" {
+			info.Currency = o.currency
+		}
+		if currency != "" && currency != info.Currency {
+			utils.InvalidParamErr.Message = "Please enter products of the same currency."
+			utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+			return
+		}
+		currency = info.Currency
 		total += (info.Price * float64(product.Quantity))
 	}
-	if total != order.Amount {
+	if total != order.Total {
 		utils.InvalidParamErr.Message = "Please enter correct total."
 		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
 		return
 	}
+	order.Currency = currency
+	if err := o.quote(ctx, order); err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+
+	order.DepositAddress = ""
+	if order.Deposit {
+		// the deposits are matched by the transfer logs, the native currency has none
+		if o.deposit == nil || chain.IsNative(order.Token) {
+			utils.InvalidParamErr.Message = "Deposit is not accepted for the token."
+			utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+			return
+		}
+		if err := o.depositAddress(ctx, chain, order); err != nil {
+			utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+			utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+			return
+		}
+	}
 
 	order, err = o.srv.CreateOrder(ctx, order)
 	if err != nil {
@@ -123,6 +246,58 @@ func (o *orderApi) CreateOrder(ctx *gin.Context) {
 	utils.Response(ctx, utils.SuccessCode, utils.Success, order.Id)
 }
 
+// Requote - lock a new rate for an unpaid order whose quote is expired, the
+// prepared transaction of the old amount is dropped.
+func (o *orderApi) Requote(ctx *gin.Context) {
+	token, err := getToken(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please carry token."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	var orderId = ctx.Param("orderId")
+	if utils.IsEmpty(orderId) {
+		utils.InvalidParamErr.Message = "Please enter correct orderId."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	order, err := o.srv.GetOrder(ctx, token.PublicAddress, orderId)
+	if err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+	if order.Status != protos.StatusCreated && order.Status != protos.StatusPaidFailed {
+		utils.InvalidParamErr.Message = "Order is already paid."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	// orders created before the quotes have no fiat total
+	if order.Total == 0 {
+		utils.InvalidParamErr.Message = "Order has no total to quote."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	if order.Currency == "" {
+		order.Currency = o.currency
+	}
+	if err := o.quote(ctx, order); err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+
+	order.Id = orderId
+	order.PreparedTx = ""
+	order.UpdatedAt = time.Now().Unix()
+	mask := []string{"amount", "currency", "quote", "prepared_tx", "updated_at"}
+	if err := o.srv.UpdateOrder(ctx, token.PublicAddress, orderId, order, mask); err != nil {
+		operationFailed(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, order.Quote)
+}
+
 func (o *orderApi) CancelOrder(ctx *gin.Context) {
 	token, err := getToken(ctx)
 	if err != nil {
@@ -143,6 +318,19 @@ func (o *orderApi) CancelOrder(ctx *gin.Context) {
 		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
 		return
 	}
+	// only the orders without a payment on chain are cancelled, a paid order
+	// is cancelled by a refund and a monitor_failed one may be paid
+	if order.Status != protos.StatusCreated && order.Status != protos.StatusPaidFailed {
+		utils.InvalidParamErr.Message = fmt.Sprintf("Order is %s, it cannot be cancelled.", order.Status.String())
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	if order.Deposit {
+		// a deposit sent to the address later would never be swept
+		utils.InvalidParamErr.Message = "Deposit order cannot be cancelled."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
 
 	order.Id = orderId
 	order.Status = protos.StatusCancelled
@@ -151,8 +339,7 @@ func (o *orderApi) CancelOrder(ctx *gin.Context) {
 	order.StatusCreatedAt = fmt.Sprintf("%s#%d", protos.StatusCancelled.String(), order.CreatedAt)
 	mask := []string{"updated_at", "status", "status_created_at"}
 	if err := o.srv.UpdateOrder(ctx, token.PublicAddress, orderId, order, mask); err != nil {
-		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		operationFailed(ctx, err)
 		return
 	}
 	utils.Response(ctx, utils.SuccessCode, utils.Success, nil)
@@ -178,20 +365,30 @@ func (o *orderApi) UpdateOrderStatus(ctx *gin.Context) {
 		return
 	}
 
+	version, err := ifMatch(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct If-Match."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
 	order, err := o.srv.GetOrder(ctx, token.PublicAddress, param.OrderId)
 	if err != nil {
 		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
 		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
 		return
 	}
+	// the order is updated at the version it was read, or the If-Match one
+	if version > 0 {
+		order.Version = version
+	}
 	order.Id = param.OrderId
 	order.Status = param.Status
 	order.UpdatedAt = time.Now().Unix()
 	order.StatusCreatedAt = fmt.Sprintf("%s#%d", param.Status.String(), order.CreatedAt)
 	mask := []string{"updated_at", "status", "status_created_at"}
 	if err := o.srv.UpdateOrder(ctx, token.PublicAddress, param.OrderId, order, mask); err != nil {
-		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		operationFailed(ctx, err)
 		return
 	}
 	utils.Response(ctx, utils.SuccessCode, utils.Success, nil)
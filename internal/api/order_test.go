@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCancelOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buyer := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+	orders := memory.NewOrderRepository()
+	h := NewOrderApi(orders, memory.NewUserRepository(), memory.NewProductRepository(), nil, nil, "USD", nil)
+	engine := gin.New()
+	engine.DELETE("/order/:orderId", func(ctx *gin.Context) {
+		ctx.Set("access_token", &protos.UserToken{PublicAddress: buyer})
+	}, h.CancelOrder)
+
+	cases := []struct {
+		name      string
+		order     protos.Order
+		cancelled bool
+	}{
+		{"created", protos.Order{Status: protos.StatusCreated}, true},
+		{"paid_failed", protos.Order{Status: protos.StatusPaidFailed}, true},
+		{"pending", protos.Order{Status: protos.StatusPending}, false},
+		{"paid", protos.Order{Status: protos.StatusPaid}, false},
+		{"monitor_failed", protos.Order{Status: protos.StatusMonitorFailed}, false},
+		{"refund_pending", protos.Order{Status: protos.StatusRefundPending}, false},
+		{"refunded", protos.Order{Status: protos.StatusRefunded}, false},
+		{"partially_refunded", protos.Order{Status: protos.StatusPartiallyRefunded}, false},
+		{"cancelled", protos.Order{Status: protos.StatusCancelled}, false},
+		{"deposit", protos.Order{Status: protos.StatusCreated, Deposit: true, DepositAddress: buyer}, false},
+	}
+	for i, c := range cases {
+		c.order.Id, c.order.From = fmt.Sprintf("order-%d", i), buyer
+		if err := orders.PutOrder(context.Background(), c.order); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/order/"+c.order.Id, nil))
+		rejected := strings.Contains(w.Body.String(), fmt.Sprintf(`"code":%d`, utils.ErrorCodeOfInvalidParams))
+		if rejected == c.cancelled {
+			t.Fatalf("%s: unexpected response %s", c.name, w.Body)
+		}
+		order, err := orders.GetOrder(context.Background(), buyer, c.order.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := c.order.Status
+		if c.cancelled {
+			want = protos.StatusCancelled
+		}
+		if order.Status != want {
+			t.Fatalf("%s: unexpected status %s", c.name, order.Status)
+		}
+	}
+}
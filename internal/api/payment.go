@@ -2,30 +2,26 @@ package api
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api/services"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/client"
-	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-gonic/gin"
 )
 
-var PaymentApi *paymentApi
+const IdempotencyKeyHeader = "Idempotency-Key"
 
 type paymentApi struct {
-	srv    services.PaymentService
-	client *ethclient.Client
+	srv services.PaymentService
 }
 
-func NewPaymentApi(serv erc20.ERC20Service, ethClient *ethclient.Client, sqs *client.SQSClient, contract string) *paymentApi {
-	PaymentApi = &paymentApi{
-		srv:    services.NewPaymentService(serv, ethClient, sqs, contract),
-		client: ethClient,
+func NewPaymentApi(chains *registry.Chains, orders storage.OrderRepository, table string) *paymentApi {
+	return &paymentApi{
+		srv: services.NewPaymentService(chains, orders, table),
 	}
-	return PaymentApi
 }
 
 func (p *paymentApi) Pay(ctx *gin.Context) {
@@ -47,17 +43,143 @@ func (p *paymentApi) Pay(ctx *gin.Context) {
 		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
 		return
 	}
-	nonce, err := p.client.PendingNonceAt(ctx, common.HexToAddress(token.PublicAddress))
+	if pay.Pay == nil && utils.IsEmpty(pay.Signature) && utils.IsEmpty(pay.SignedTx) &&
+		pay.Permit == nil && pay.Authorization == nil {
+		utils.InvalidParamErr.Message = "Please enter pay info, signature, signed_tx, permit or authorization."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	tx, err := p.srv.PayToken(ctx, token.PublicAddress, ctx.GetHeader(IdempotencyKeyHeader), pay)
+	if err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, tx)
+}
+
+func (p *paymentApi) Prepare(ctx *gin.Context) {
+	token, err := getToken(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please carry token."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	var param protos.PreparePaymentRequest
+	if err := ctx.ShouldBindJSON(&param); err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct data."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	if utils.IsEmpty(param.OrderId) {
+		utils.InvalidParamErr.Message = "Please enter order id."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	data, err := p.srv.PreparePayment(ctx, token.PublicAddress, param.OrderId)
 	if err != nil {
 		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
 		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
 		return
 	}
-	tx, err := p.srv.PayToken(ctx, token.PublicAddress, pay.OrderId, nonce, pay.Pay)
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+func (p *paymentApi) GetFees(ctx *gin.Context) {
+	token, err := getToken(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please carry token."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	orderId := ctx.Query("order_id")
+	if utils.IsEmpty(orderId) {
+		utils.InvalidParamErr.Message = "Please enter order id."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	data, err := p.srv.GetFees(ctx, token.PublicAddress, orderId)
 	if err != nil {
 		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
 		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
 		return
 	}
-	utils.Response(ctx, utils.SuccessCode, utils.Success, tx)
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+func (p *paymentApi) GetBalance(ctx *gin.Context) {
+	token, err := getToken(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please carry token."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	chainId, symbol, ok := tokenQuery(ctx)
+	if !ok {
+		return
+	}
+
+	data, err := p.srv.GetBalance(ctx, token.PublicAddress, chainId, symbol)
+	if err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+func (p *paymentApi) GetAllowance(ctx *gin.Context) {
+	token, err := getToken(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please carry token."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	chainId, symbol, ok := tokenQuery(ctx)
+	if !ok {
+		return
+	}
+	spender := ctx.Query("spender")
+	if !utils.IsEmpty(spender) && !utils.IsValidAddress(spender) {
+		utils.InvalidParamErr.Message = "Please enter correct spender."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	data, err := p.srv.GetAllowance(ctx, token.PublicAddress, chainId, symbol, spender)
+	if err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+// tokenQuery - the chain id and the token of the query, the chain id is
+// optional and 0 is the default chain.
+func tokenQuery(ctx *gin.Context) (uint64, string, bool) {
+	symbol := ctx.Query("token")
+	if utils.IsEmpty(symbol) {
+		utils.InvalidParamErr.Message = "Please enter token."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return 0, "", false
+	}
+	var chainId uint64
+	if val := ctx.Query("chain_id"); !utils.IsEmpty(val) {
+		id, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			utils.InvalidParamErr.Message = "Please enter correct chain id."
+			utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+			return 0, "", false
+		}
+		chainId = id
+	}
+	return chainId, symbol, true
 }
@@ -4,31 +4,45 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api/services"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/patrickmn/go-cache"
 )
 
-var (
-	ProductApi  *productApi
-	PRODUCTLIST = "productlist"
-)
+var PRODUCTLIST = "productlist"
 
 type productApi struct {
 	srv  services.ProductService
 	info *cache.Cache
+	// currency - the currency of the products created without one
+	currency string
 }
 
-func NewProductApi(expire time.Duration) *productApi {
-	ProductApi = &productApi{
-		srv:  services.NewProductService(),
-		info: cache.New(expire, expire*2),
+func NewProductApi(products storage.ProductRepository, expire time.Duration, currency string) *productApi {
+	return &productApi{
+		srv:      services.NewProductService(products),
+		info:     cache.New(expire, expire*2),
+		currency: currency,
 	}
-	return ProductApi
+}
+
+// isCurrency - a currency is a three letters code, e.g. USD.
+func isCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
 }
 
 func (p *productApi) GetProduct(ctx *gin.Context) {
@@ -39,6 +53,7 @@ func (p *productApi) GetProduct(ctx *gin.Context) {
 		return
 	}
 	if PRODUCT, found := p.info.Get(productId); found {
+		setETag(ctx, PRODUCT.(*protos.Product).Version)
 		utils.Response(ctx, utils.SuccessCode, utils.Success, PRODUCT)
 		return
 	}
@@ -49,6 +64,7 @@ func (p *productApi) GetProduct(ctx *gin.Context) {
 		return
 	}
 	p.info.Set(productId, PRODUCT, cache.DefaultExpiration)
+	setETag(ctx, PRODUCT.Version)
 	utils.Response(ctx, http.StatusOK, utils.Success, PRODUCT)
 }
 
@@ -59,6 +75,15 @@ func (p *productApi) CreateProduct(ctx *gin.Context) {
 		utils.Response(ctx, http.StatusOK, utils.InvalidParamErr, nil)
 		return
 	}
+	if request.Currency == "" {
+		request.Currency = p.currency
+	}
+	request.Currency = strings.ToUpper(request.Currency)
+	if !isCurrency(request.Currency) {
+		utils.InvalidParamErr.Message = "Please enter correct currency."
+		utils.Response(ctx, http.StatusOK, utils.InvalidParamErr, nil)
+		return
+	}
 
 	PRODUCT, err := p.srv.CreateProduct(ctx, &request)
 	if err != nil {
@@ -101,13 +126,41 @@ func (p *productApi) UpdateProduct(ctx *gin.Context) {
 		return
 	}
 	request.Product.Id = productId
+	request.Product.Currency = strings.ToUpper(request.Product.Currency)
+	for _, key := range request.UpdateMask {
+		if key == "currency" && !isCurrency(request.Product.Currency) {
+			utils.InvalidParamErr.Message = "Please enter correct currency."
+			utils.Response(ctx, http.StatusOK, utils.InvalidParamErr, nil)
+			return
+		}
+	}
+
+	version, err := ifMatch(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct If-Match."
+		utils.Response(ctx, http.StatusOK, utils.InvalidParamErr, nil)
+		return
+	}
+	// the body never sets the version, the product is updated at the
+	// version it was read, or the If-Match one
+	if version == 0 {
+		current, err := p.srv.GetProduct(ctx, productId)
+		if err != nil {
+			operationFailed(ctx, err)
+			return
+		}
+		version = current.Version
+	}
+	request.Product.Version = version
 
 	PRODUCT, err := p.srv.UpdateProduct(ctx, productId, request.Product, request.UpdateMask)
 	if err != nil {
-		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		operationFailed(ctx, err)
 		return
 	}
+	// the cached product carries the old version
+	p.info.Set(productId, PRODUCT, cache.DefaultExpiration)
+	setETag(ctx, PRODUCT.Version)
 	utils.Response(ctx, http.StatusOK, utils.Success, PRODUCT)
 }
 
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api/services"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/nonce"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type refundApi struct {
+	srv services.RefundService
+}
+
+func NewRefundApi(chains *registry.Chains, orders storage.OrderRepository, managers []*nonce.Manager, table string) *refundApi {
+	return &refundApi{
+		srv: services.NewRefundService(chains, orders, managers, table),
+	}
+}
+
+// Refund - the admin refunds the order of the buyer from the treasury.
+func (r *refundApi) Refund(ctx *gin.Context) {
+	var orderId = ctx.Param("orderId")
+	if utils.IsEmpty(orderId) {
+		utils.InvalidParamErr.Message = "Please enter correct orderId."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	var param protos.RefundRequest
+	if err := ctx.ShouldBindJSON(&param); err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct data."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	if utils.IsEmpty(param.From) || !utils.IsValidAddress(param.From) {
+		utils.InvalidParamErr.Message = "Please enter correct from."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	if param.Amount < 0 {
+		utils.InvalidParamErr.Message = "Please enter correct amount."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	refund, err := r.srv.Refund(ctx, orderId, &param)
+	if err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, refund)
+}
+
+func (r *refundApi) GetRefunds(ctx *gin.Context) {
+	token, err := getToken(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please carry token."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	var orderId = ctx.Param("orderId")
+	if utils.IsEmpty(orderId) {
+		utils.InvalidParamErr.Message = "Please enter correct orderId."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+
+	data, err := r.srv.GetRefunds(ctx, token.PublicAddress, orderId)
+	if err != nil {
+		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
@@ -6,44 +6,51 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(server *gin.Engine, admin string) {
-	RegisterAuthRouter(server.Group("/auth/"))
-	RegisterUserRouter(server.Group("/user/"))
-	RegisterProductRouter(server.Group("/product/"))
-	RegisterOrderRouter(server.Group("/order/"))
-	RegisterAdminRouter(server.Group("/admin/"), admin)
-	RegisterPaymentRouter(server.Group("/payment/"))
+func RegisterRoutes(server *gin.Engine, admin string, h *api.Handlers) {
+	RegisterAuthRouter(server.Group("/auth/"), h)
+	RegisterUserRouter(server.Group("/user/"), h)
+	RegisterProductRouter(server.Group("/product/"), h)
+	RegisterOrderRouter(server.Group("/order/"), h)
+	RegisterAdminRouter(server.Group("/admin/"), admin, h)
+	RegisterPaymentRouter(server.Group("/payment/"), h)
 }
-func RegisterAuthRouter(group *gin.RouterGroup) {
-	group.POST("/register", api.UserApi.Register)
-	group.POST("/token", api.UserApi.GetToken)
+func RegisterAuthRouter(group *gin.RouterGroup, h *api.Handlers) {
+	group.POST("/register", h.User.Register)
+	group.POST("/token", h.User.GetToken)
 }
-func RegisterUserRouter(group *gin.RouterGroup) {
+func RegisterUserRouter(group *gin.RouterGroup, h *api.Handlers) {
 	group.Use(middleware.UserAuthorization())
-	group.GET("/info", api.UserApi.GetUser)
-	group.PATCH("/info", api.UserApi.UpdateUser)
+	group.GET("/info", h.User.GetUser)
+	group.PATCH("/info", h.User.UpdateUser)
 }
 
-func RegisterProductRouter(group *gin.RouterGroup) {
-	group.GET("/list", api.ProductApi.GetProductList)
-	group.GET(":productId", api.ProductApi.GetProduct)
+func RegisterProductRouter(group *gin.RouterGroup, h *api.Handlers) {
+	group.GET("/list", h.Product.GetProductList)
+	group.GET(":productId", h.Product.GetProduct)
 }
-func RegisterOrderRouter(group *gin.RouterGroup) {
+func RegisterOrderRouter(group *gin.RouterGroup, h *api.Handlers) {
 	group.Use(middleware.UserAuthorization())
-	group.POST("/create", api.OrderApi.CreateOrder)
-	group.GET("/list", api.OrderApi.GetOrders)
-	group.GET("/:orderId", api.OrderApi.GetOrder)
-	group.GET("/cancel/:orderId", api.OrderApi.CancelOrder)
+	group.POST("/create", h.Order.CreateOrder)
+	group.GET("/list", h.Order.GetOrders)
+	group.GET("/:orderId", h.Order.GetOrder)
+	group.GET("/cancel/:orderId", h.Order.CancelOrder)
+	group.POST("/quote/:orderId", h.Order.Requote)
+	group.GET("/refund/:orderId", h.Refund.GetRefunds)
 }
 
-func RegisterPaymentRouter(group *gin.RouterGroup) {
+func RegisterPaymentRouter(group *gin.RouterGroup, h *api.Handlers) {
 	group.Use(middleware.UserAuthorization())
-	group.POST("/pay", api.PaymentApi.Pay)
+	group.POST("/pay", h.Payment.Pay)
+	group.POST("/prepare", h.Payment.Prepare)
+	group.GET("/fees", h.Payment.GetFees)
+	group.GET("/balance", h.Payment.GetBalance)
+	group.GET("/allowance", h.Payment.GetAllowance)
 }
 
-func RegisterAdminRouter(group *gin.RouterGroup, admin string) {
+func RegisterAdminRouter(group *gin.RouterGroup, admin string, h *api.Handlers) {
 	group.Use(middleware.AdminAuthorization(admin))
-	group.POST("/product/create", api.ProductApi.CreateProduct)
-	group.PATCH("/product/:productId", api.ProductApi.UpdateProduct)
-	group.PATCH("/order/:orderId", api.OrderApi.UpdateOrderStatus)
+	group.POST("/product/create", h.Product.CreateProduct)
+	group.PATCH("/product/:productId", h.Product.UpdateProduct)
+	group.PATCH("/order/:orderId", h.Order.UpdateOrderStatus)
+	group.POST("/order/refund/:orderId", h.Refund.Refund)
 }
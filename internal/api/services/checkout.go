@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/native"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// checkout - how an order is paid, either with an erc20 token or with the
+// native currency of the chain, and the treasury receiving it.
+type checkout struct {
+	chain    *registry.Chain
+	treasury string
+	token    *erc20.Token
+	native   *native.Currency
+}
+
+// value - the value in wei of an order paid with the native currency.
+func (c *checkout) value(order *protos.Order) (*big.Int, error) {
+	if order.Quote == nil {
+		return nil, errors.Join(ErrInvalidQuote, fmt.Errorf("order %s has no quote", order.Id))
+	}
+	value, ok := new(big.Int).SetString(order.Quote.Value, 10)
+	if !ok || value.Sign() <= 0 {
+		return nil, errors.Join(ErrInvalidQuote, fmt.Errorf("quote value %s is invalid", order.Quote.Value))
+	}
+	return value, nil
+}
+
+// permit - the token supports EIP-2612 and the chain has a relayer.
+func (c *checkout) permit() bool {
+	return c.token != nil && c.token.Permit && c.chain.Relayer != nil
+}
+
+// authorization - the token supports EIP-3009 and the chain has a relayer.
+func (c *checkout) authorization() bool {
+	return c.token != nil && c.token.Authorization && c.chain.Relayer != nil
+}
+
+func (c *checkout) prepare(ctx context.Context, from string, order *protos.Order) (*types.Transaction, error) {
+	if c.token != nil {
+		return c.token.Service.PrepareTransfer(ctx, from, c.treasury, order.Amount)
+	}
+	value, err := c.value(order)
+	if err != nil {
+		return nil, err
+	}
+	return c.native.Service.PrepareTransfer(ctx, from, c.treasury, value)
+}
+
+// estimate - the gas of the payment transfer of the order.
+func (c *checkout) estimate(ctx context.Context, from string, order *protos.Order) (uint64, error) {
+	if c.token != nil {
+		return c.token.Service.EstimateTransfer(ctx, from, c.treasury, order.Amount)
+	}
+	value, err := c.value(order)
+	if err != nil {
+		return 0, err
+	}
+	return c.native.Service.EstimateTransfer(ctx, from, c.treasury, value)
+}
+
+func (c *checkout) signPrepared(prepared *types.Transaction, from string, signature []byte) (*types.Transaction, error) {
+	if c.token != nil {
+		return c.token.Service.SignPrepared(prepared, from, signature)
+	}
+	return c.native.Service.SignPrepared(prepared, from, signature)
+}
+
+func (c *checkout) checkSigned(tx *types.Transaction, from string, order *protos.Order) (*types.Transaction, error) {
+	if c.token != nil {
+		return c.token.Service.CheckSignedTransfer(tx, from, c.treasury, order.Amount)
+	}
+	value, err := c.value(order)
+	if err != nil {
+		return nil, err
+	}
+	return c.native.Service.CheckSignedTransfer(tx, from, c.treasury, value)
+}
+
+func (c *checkout) send(ctx context.Context, tx *types.Transaction) error {
+	if c.token != nil {
+		return c.token.Service.SendTransaction(ctx, tx)
+	}
+	return c.native.Service.SendTransaction(ctx, tx)
+}
+
+// monitorRequest - erc20 payments are monitored by the Transfer log, the
+// authorizations by the AuthorizationUsed log and native payments by the
+// receipt and the value of the transaction. The sender and the nonce of the
+// transaction find the transaction replacing it.
+func (c *checkout) monitorRequest(order *protos.Order, table string, fromBlock uint64, tx *types.Transaction) (*protos.CreateMonitorRequest, error) {
+	req := &protos.CreateMonitorRequest{
+		OrderId:   order.Id,
+		Table:     table,
+		ChainId:   c.chain.Id.Uint64(),
+		From:      order.From,
+		To:        c.treasury,
+		FromBlock: fromBlock,
+		TxHash:    tx.Hash().Hex(),
+	}
+	if err := withSender(req, tx); err != nil {
+		return nil, err
+	}
+	if c.token != nil && order.AuthorizationNonce != "" {
+		req.Contract = c.token.Address.Hex()
+		req.Topics = []string{erc20.AuthorizationUsedTopic().Hex()}
+		req.Nonce = order.AuthorizationNonce
+		req.Value = contract.ToWei(order.Amount, c.token.Decimals).String()
+		return req, nil
+	}
+	if c.token != nil {
+		req.Contract = c.token.Address.Hex()
+		req.Topics = []string{c.token.Service.GetABI().Events[erc20.EVENT_TRANSFER].ID.Hex()}
+		req.Value = contract.ToWei(order.Amount, c.token.Decimals).String()
+		return req, nil
+	}
+	value, err := c.value(order)
+	if err != nil {
+		return nil, err
+	}
+	req.Native = true
+	req.Value = value.String()
+	return req, nil
+}
+
+// refundMonitorRequest - a refund is sent from the treasury to the buyer,
+// erc20 refunds are monitored by the Transfer log and native refunds by the
+// value of the transaction.
+func (c *checkout) refundMonitorRequest(order *protos.Order, table string, fromBlock uint64, tx *types.Transaction, value *big.Int) (*protos.CreateMonitorRequest, error) {
+	req := &protos.CreateMonitorRequest{
+		OrderId:   order.Id,
+		Table:     table,
+		ChainId:   c.chain.Id.Uint64(),
+		From:      c.treasury,
+		To:        order.From,
+		FromBlock: fromBlock,
+		TxHash:    tx.Hash().Hex(),
+		Value:     value.String(),
+	}
+	if err := withSender(req, tx); err != nil {
+		return nil, err
+	}
+	if c.token != nil {
+		req.Contract = c.token.Address.Hex()
+		req.Topics = []string{c.token.Service.GetABI().Events[erc20.EVENT_TRANSFER].ID.Hex()}
+		return req, nil
+	}
+	req.Native = true
+	return req, nil
+}
+
+// withSender - the signer and the nonce of the signed transaction, the buyer
+// for the transfers and the relayer or the treasury for the others.
+func withSender(req *protos.CreateMonitorRequest, tx *types.Transaction) error {
+	sender, err := types.LatestSignerForChainID(tx.ChainId()).Sender(tx)
+	if err != nil {
+		return errors.Join(ErrInvalidSignature, err)
+	}
+	nonce := tx.Nonce()
+	req.Sender, req.SenderNonce = sender.Hex(), &nonce
+	return nil
+}
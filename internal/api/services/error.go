@@ -3,13 +3,30 @@ package services
 import "errors"
 
 var (
-	ErrDynamodbClientNotFound = errors.New("dynamodb client not found")
-	ErrAlreadyPaid            = errors.New("already paid")
-	ErrInvalidAmount          = errors.New("invalid amount")
-	ErrTransactionFailed      = errors.New("transaction failed")
-	ErrDynamodb               = errors.New("dynamodb operation failed")
-	ErrInvalidSignature       = errors.New("invalid signature")
-	ErrGenerateToken          = errors.New("generate token failed")
-	ErrSQS                    = errors.New("sqs operation failed")
-	ErrEthereum               = errors.New("ethereum operation failed")
+	ErrAlreadyPaid               = errors.New("already paid")
+	ErrInvalidAmount             = errors.New("invalid amount")
+	ErrTransactionFailed         = errors.New("transaction failed")
+	ErrDynamodb                  = errors.New("dynamodb operation failed")
+	ErrInvalidSignature          = errors.New("invalid signature")
+	ErrGenerateToken             = errors.New("generate token failed")
+	ErrSQS                       = errors.New("sqs operation failed")
+	ErrEthereum                  = errors.New("ethereum operation failed")
+	ErrIdempotencyKeyReused      = errors.New("idempotency key is used by another order")
+	ErrPaymentInProgress         = errors.New("payment is in progress")
+	ErrPaymentNotPrepared        = errors.New("payment is not prepared")
+	ErrInvalidTransaction        = errors.New("invalid transaction")
+	ErrInvalidRecipient          = errors.New("recipient is not the treasury")
+	ErrInvalidToken              = errors.New("token is not accepted")
+	ErrInvalidChain              = errors.New("chain is not accepted")
+	ErrInvalidQuote              = errors.New("invalid quote")
+	ErrQuoteExpired              = errors.New("quote is expired")
+	ErrPermitNotSupported        = errors.New("permit is not supported")
+	ErrInvalidPermit             = errors.New("invalid permit")
+	ErrAuthorizationNotSupported = errors.New("authorization is not supported")
+	ErrInvalidAuthorization      = errors.New("invalid authorization")
+	ErrDepositExhausted          = errors.New("deposit addresses are exhausted")
+	ErrDepositOrder              = errors.New("order is paid to its deposit address")
+	ErrNotRefundable             = errors.New("order is not refundable")
+	ErrRefundNotSupported        = errors.New("refund is not supported")
+	ErrRefundInProgress          = errors.New("refund is in progress")
 )
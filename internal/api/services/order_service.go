@@ -2,11 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/model"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/hdwallet"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/google/uuid"
 )
@@ -16,21 +17,26 @@ type OrderService interface {
 	GetOrder(ctx context.Context, publicAddress, id string) (*protos.Order, error)
 	GetUserOrder(ctx context.Context, publicAddress string) ([]protos.Order, error)
 	UpdateOrder(ctx context.Context, publicAddress, id string, order *protos.Order, updateMask []string) error
+	NextDepositIndex(ctx context.Context) (uint32, error)
 }
 
+// DepositCounter - the counter of the indexes of the deposit addresses.
+const DepositCounter = "deposit"
+
 type orderService struct {
+	orders storage.OrderRepository
+	users  storage.UserRepository
 }
 
-func NewOrderService() OrderService {
-	return &orderService{}
+func NewOrderService(orders storage.OrderRepository, users storage.UserRepository) OrderService {
+	return &orderService{
+		orders: orders,
+		users:  users,
+	}
 }
 
 func (s *orderService) CreateOrder(ctx context.Context, order *protos.Order) (*protos.Order, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	_, err := model.GetUserInfo(ctx, dynamo, order.From)
+	_, err := s.users.GetUser(ctx, order.From)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +47,7 @@ func (s *orderService) CreateOrder(ctx context.Context, order *protos.Order) (*p
 	order.CreatedAt = time.Now().Unix()
 	order.UpdatedAt = time.Now().Unix()
 	order.StatusCreatedAt = fmt.Sprintf("%s#%d", order.Status, order.CreatedAt)
-	err = model.PutOrder(ctx, dynamo, *order)
+	err = s.orders.PutOrder(ctx, *order)
 	if err != nil {
 		return nil, err
 	}
@@ -49,11 +55,7 @@ func (s *orderService) CreateOrder(ctx context.Context, order *protos.Order) (*p
 }
 
 func (s *orderService) GetOrder(ctx context.Context, publicAddress, id string) (*protos.Order, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	order, err := model.GetOrder(ctx, dynamo, publicAddress, id)
+	order, err := s.orders.GetOrder(ctx, publicAddress, id)
 	if err != nil {
 		return nil, err
 	}
@@ -61,11 +63,7 @@ func (s *orderService) GetOrder(ctx context.Context, publicAddress, id string) (
 }
 
 func (s *orderService) GetUserOrder(ctx context.Context, publicAddress string) ([]protos.Order, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	orders, err := model.GetUserOrders(ctx, dynamo, publicAddress)
+	orders, err := s.orders.GetUserOrders(ctx, publicAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -73,13 +71,21 @@ func (s *orderService) GetUserOrder(ctx context.Context, publicAddress string) (
 }
 
 func (s *orderService) UpdateOrder(ctx context.Context, publicAddress, id string, order *protos.Order, updateMask []string) error {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return ErrDynamodbClientNotFound
-	}
-	_, err := model.UpdateOrder(ctx, dynamo, publicAddress, id, *order, updateMask)
+	_, err := s.orders.UpdateOrder(ctx, publicAddress, id, *order, updateMask)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// NextDepositIndex - the index of a new deposit address, it is never reused.
+func (s *orderService) NextDepositIndex(ctx context.Context) (uint32, error) {
+	index, err := s.orders.NextCounter(ctx, DepositCounter)
+	if err != nil {
+		return 0, errors.Join(ErrDynamodb, err)
+	}
+	if index >= uint64(hdwallet.HardenedOffset) {
+		return 0, errors.Join(ErrDepositExhausted, fmt.Errorf("index %d", index))
+	}
+	return uint32(index), nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+)
+
+func TestCreateOrder(t *testing.T) {
+	ctx := context.Background()
+	users, orders := memory.NewUserRepository(), memory.NewOrderRepository()
+	srv := NewOrderService(orders, users)
+
+	if _, err := srv.CreateOrder(ctx, &protos.Order{From: "0x01", Amount: 1}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the order of an unknown user to fail, got %v", err)
+	}
+
+	if err := users.PutUser(ctx, protos.User{PublicAddress: "0x01"}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := srv.CreateOrder(ctx, &protos.Order{From: "0x01", Amount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Id == "" || order.Status != protos.StatusCreated || order.StatusCreatedAt == "" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+	list, err := srv.GetUserOrder(ctx, "0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Id != order.Id {
+		t.Fatalf("unexpected orders %+v", list)
+	}
+	if _, err := srv.GetOrder(ctx, "0x02", order.Id); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the order of another user not to be found, got %v", err)
+	}
+}
+
+func TestNextDepositIndex(t *testing.T) {
+	srv := NewOrderService(memory.NewOrderRepository(), memory.NewUserRepository())
+	for want := uint32(1); want <= 3; want++ {
+		index, err := srv.NextDepositIndex(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index != want {
+			t.Fatalf("index is %d, not %d", index, want)
+		}
+	}
+}
@@ -2,97 +2,629 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"math/big"
 	"time"
 
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/client"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/model"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
 	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/fees"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/relayer"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type PaymentService interface {
-	PayToken(ctx context.Context, publicAddress, orderId string, nonce uint64, in *protos.CommonRequest) (string, error)
+	PayToken(ctx context.Context, publicAddress, idempotencyKey string, pay *protos.PayRequest) (string, error)
+	PreparePayment(ctx context.Context, publicAddress, orderId string) (*protos.PreparePaymentResponse, error)
+	GetFees(ctx context.Context, publicAddress, orderId string) (*protos.FeesResponse, error)
+	GetBalance(ctx context.Context, publicAddress string, chainId uint64, symbol string) (*protos.BalanceResponse, error)
+	GetAllowance(ctx context.Context, publicAddress string, chainId uint64, symbol, spender string) (*protos.AllowanceResponse, error)
 }
 
 var (
-	rollback uint64 = 5
+	rollback          uint64 = 5
+	IdempotencyExpire        = 24 * time.Hour
+	// PermitExpire - the deadline of the permits and the authorizations of
+	// orders without a quote
+	PermitExpire = 30 * time.Minute
 )
 
 type payment struct {
-	token     erc20.ERC20Service
-	sqsClient *client.SQSClient
-	ether     *ethclient.Client
-	contract  string
+	chains *registry.Chains
+	orders storage.OrderRepository
+	// table - the table the monitor updates the orders in
+	table string
 }
 
-func NewPaymentService(token erc20.ERC20Service, ethClient *ethclient.Client, sqs *client.SQSClient, contract string) PaymentService {
+func NewPaymentService(chains *registry.Chains, orders storage.OrderRepository, table string) PaymentService {
 	return &payment{
-		token:     token,
-		ether:     ethClient,
-		sqsClient: sqs,
-		contract:  contract,
+		chains: chains,
+		orders: orders,
+		table:  table,
 	}
 }
 
-func (p *payment) PayToken(ctx context.Context, publicAddress, orderId string, nonce uint64, in *protos.CommonRequest) (string, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return "", ErrDynamodbClientNotFound
+// orderCheckout - the chain and the token or the native currency the order
+// is paid with.
+func (p *payment) orderCheckout(order *protos.Order) (*checkout, error) {
+	ch, err := p.chains.Get(order.ChainId)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidChain, err)
+	}
+	c := &checkout{chain: ch}
+	symbol := order.Token
+	if ch.IsNative(order.Token) {
+		c.native = ch.Native
+	} else {
+		token, err := ch.Tokens.Get(order.Token)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidToken, err)
+		}
+		c.token, symbol = token, token.Symbol
+	}
+	treasury, ok := ch.Treasuries[symbol]
+	if !ok {
+		return nil, errors.Join(ErrInvalidRecipient, fmt.Errorf("treasury of %s not found", symbol))
 	}
+	c.treasury = treasury
+	return c, nil
+}
 
-	order, err := model.GetOrder(ctx, dynamo, publicAddress, orderId)
+func (p *payment) PayToken(ctx context.Context, publicAddress, idempotencyKey string, pay *protos.PayRequest) (string, error) {
+	if idempotencyKey == "" {
+		return p.payToken(ctx, publicAddress, pay)
+	}
 
+	txHash, done, err := p.claimIdempotencyKey(ctx, publicAddress, pay.OrderId, idempotencyKey)
+	if err != nil || done {
+		return txHash, err
+	}
+	txHash, err = p.payToken(ctx, publicAddress, pay)
 	if err != nil {
+		// release the key, so the request can be retried
+		if dbErr := p.orders.DeleteIdempotency(ctx, publicAddress, idempotencyKey); dbErr != nil {
+			return "", errors.Join(err, ErrDynamodb, dbErr)
+		}
 		return "", err
 	}
+	if err := p.orders.CompleteIdempotency(ctx, publicAddress, idempotencyKey, txHash); err != nil {
+		// the payment is already sent, retries will wait until the key expires
+		log.Printf("complete idempotency key %s failed: %s", idempotencyKey, err)
+	}
+	return txHash, nil
+}
 
-	if order.Amount != in.Amount {
-		return "", ErrInvalidAmount
+// claimIdempotencyKey - claim the key for the order. When the key was used
+// before, done is true and the original tx hash is returned.
+func (p *payment) claimIdempotencyKey(ctx context.Context, publicAddress, orderId, key string) (string, bool, error) {
+	now := time.Now()
+	err := p.orders.PutIdempotency(ctx, protos.Idempotency{
+		Key:           key,
+		PublicAddress: publicAddress,
+		OrderId:       orderId,
+		Status:        protos.IdempotencyInProgress,
+		CreatedAt:     now.Unix(),
+		UpdatedAt:     now.Unix(),
+		ExpireAt:      now.Add(IdempotencyExpire).Unix(),
+	})
+	if err == nil {
+		return "", false, nil
+	}
+	if !storage.IsConditionalCheckFailed(err) {
+		return "", false, errors.Join(ErrDynamodb, err)
 	}
 
-	if nonce != in.Nonce {
-		return "", erc20.ErrInvalidNonce
+	info, err := p.orders.GetIdempotency(ctx, publicAddress, key)
+	if err != nil {
+		return "", false, errors.Join(ErrDynamodb, err)
+	}
+	if info.OrderId != orderId {
+		return "", true, ErrIdempotencyKeyReused
+	}
+	if info.Status != protos.IdempotencyCompleted {
+		return "", true, ErrPaymentInProgress
 	}
+	return info.Response, true, nil
+}
 
-	if order.Status == protos.StatusCreated || order.Status == protos.StatusPaidFailed {
-		tx, err := p.token.TransferWithSign(ctx, *in)
-		if err != nil {
-			return "", errors.Join(ErrTransactionFailed, err)
+func (p *payment) PreparePayment(ctx context.Context, publicAddress, orderId string) (*protos.PreparePaymentResponse, error) {
+	order, err := p.orders.GetOrder(ctx, publicAddress, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != protos.StatusCreated && order.Status != protos.StatusPaidFailed {
+		return nil, ErrAlreadyPaid
+	}
+	if order.Deposit {
+		return nil, ErrDepositOrder
+	}
+	if order.Quote != nil && order.Quote.Expired(time.Now().Unix()) {
+		return nil, ErrQuoteExpired
+	}
+	c, err := p.orderCheckout(order)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.prepare(ctx, publicAddress, order)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	bin, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	payload, err := erc20.UnsignedPayload(tx)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+
+	resp := &protos.PreparePaymentResponse{
+		OrderId:     orderId,
+		Raw:         hexutil.Encode(payload),
+		SigningHash: crypto.Keccak256Hash(payload).Hex(),
+		Tx:          erc20.ToTransactionArgs(tx, publicAddress),
+	}
+	if c.permit() {
+		if resp.Permit, err = p.preparePermit(ctx, c, order); err != nil {
+			return nil, err
 		}
-		order.Status = protos.StatusPending
-		order.PaymentHash = tx.Hash().Hex()
-		order.UpdatedAt = time.Now().Unix()
-		_, err = model.UpdateOrder(ctx, dynamo, publicAddress, orderId,
-			*order, []string{"status", "payment_hash", "updated_at"})
-		if err != nil {
-			// because transaction already done
-			// here need to keep the error and update the order
-			return "", errors.Join(ErrDynamodb, err)
+	}
+	if c.authorization() {
+		if resp.Authorization, err = p.prepareAuthorization(c, order); err != nil {
+			return nil, err
 		}
-		// // put tx to sqs
-		block, err := p.ether.BlockByNumber(ctx, nil)
-		if err != nil {
-			// because transaction already done
-			return "", errors.Join(ErrEthereum, err)
+	}
+
+	order.PreparedTx = hexutil.Encode(bin)
+	order.UpdatedAt = time.Now().Unix()
+	if _, err := p.orders.UpdateOrder(ctx, publicAddress, orderId, *order,
+		[]string{"prepared_tx", "updated_at"}); err != nil {
+		return nil, errors.Join(ErrDynamodb, err)
+	}
+	return resp, nil
+}
+
+// GetFees - the gas of the payment transfer of the order and the fees by
+// speed, the wallet signs the transfer with them.
+func (p *payment) GetFees(ctx context.Context, publicAddress, orderId string) (*protos.FeesResponse, error) {
+	order, err := p.orders.GetOrder(ctx, publicAddress, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != protos.StatusCreated && order.Status != protos.StatusPaidFailed {
+		return nil, ErrAlreadyPaid
+	}
+	if order.Deposit {
+		return nil, ErrDepositOrder
+	}
+	c, err := p.orderCheckout(order)
+	if err != nil {
+		return nil, err
+	}
+	if c.chain.Fees == nil {
+		return nil, errors.Join(ErrEthereum, fmt.Errorf("fees of chain %s not found", c.chain.Id))
+	}
+
+	gas, err := c.estimate(ctx, publicAddress, order)
+	if err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	suggestion, err := c.chain.Fees.Suggest(ctx)
+	if err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	fee := func(f fees.Fee) protos.Fee {
+		return protos.Fee{GasTipCap: f.GasTipCap.String(), GasFeeCap: f.GasFeeCap.String()}
+	}
+	return &protos.FeesResponse{
+		OrderId:  orderId,
+		ChainId:  c.chain.Id.Uint64(),
+		Gas:      gas,
+		BaseFee:  suggestion.BaseFee.String(),
+		Slow:     fee(suggestion.Slow),
+		Standard: fee(suggestion.Standard),
+		Fast:     fee(suggestion.Fast),
+	}, nil
+}
+
+// GetBalance - the balance of the address in the token or the native
+// currency of the chain.
+func (p *payment) GetBalance(ctx context.Context, publicAddress string, chainId uint64, symbol string) (*protos.BalanceResponse, error) {
+	ch, err := p.chains.Get(chainId)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidChain, err)
+	}
+	var (
+		balance  *big.Int
+		decimals int
+	)
+	if ch.IsNative(symbol) {
+		symbol, decimals = ch.Native.Symbol, ch.Native.Decimals
+		balance, err = ch.Native.Service.BalanceOf(ctx, publicAddress)
+	} else {
+		token, tokenErr := ch.Tokens.Get(symbol)
+		if tokenErr != nil {
+			return nil, errors.Join(ErrInvalidToken, tokenErr)
 		}
+		symbol, decimals = token.Symbol, token.Decimals
+		balance, err = token.Service.BalanceOf(ctx, publicAddress)
+	}
+	if err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	return &protos.BalanceResponse{
+		ChainId: ch.Id.Uint64(),
+		Token:   symbol,
+		Address: publicAddress,
+		Value:   balance.String(),
+		Amount:  decimal.NewFromBigInt(balance, -int32(decimals)).String(),
+	}, nil
+}
 
-		sqsData := new(protos.CreateMonitorRequest)
-		sqsData.OrderId = orderId
-		sqsData.TxHash = tx.Hash().Hex()
-		sqsData.Contract = p.contract
-		sqsData.From = publicAddress
-		sqsData.FromBlock = block.NumberU64() - rollback
-		err = client.Send(ctx, p.sqsClient, sqsData)
-		if err != nil {
-			// because transaction already done
-			// here need to keep the error and send to sqs
-			return "", errors.Join(ErrSQS, err)
+// GetAllowance - the allowance of the address for the spender, the relayer
+// of the chain is the spender by default.
+func (p *payment) GetAllowance(ctx context.Context, publicAddress string, chainId uint64, symbol, spender string) (*protos.AllowanceResponse, error) {
+	ch, err := p.chains.Get(chainId)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidChain, err)
+	}
+	token, err := ch.Tokens.Get(symbol)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+	if spender == "" {
+		if ch.Relayer == nil {
+			return nil, errors.Join(ErrPermitNotSupported, fmt.Errorf("relayer of chain %s not found, please enter the spender", ch.Id))
 		}
-		return tx.Hash().Hex(), nil
-	} else {
+		spender = ch.Relayer.Address().Hex()
+	}
+	allowance, err := token.Service.CheckAllowance(ctx, protos.CheckAllowanceRequest{From: publicAddress, To: spender})
+	if err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	return &protos.AllowanceResponse{
+		ChainId: ch.Id.Uint64(),
+		Token:   token.Symbol,
+		Owner:   publicAddress,
+		Spender: spender,
+		Value:   allowance.String(),
+		Amount:  decimal.NewFromBigInt(allowance, -int32(token.Decimals)).String(),
+	}, nil
+}
+
+// deadline - the permits and the authorizations expire with the quote of the order.
+func deadline(order *protos.Order) int64 {
+	if order.Quote != nil && order.Quote.ExpireAt > 0 {
+		return order.Quote.ExpireAt
+	}
+	return time.Now().Add(PermitExpire).Unix()
+}
+
+// preparePermit - the permit of the order amount for the relayer.
+func (p *payment) preparePermit(ctx context.Context, c *checkout, order *protos.Order) (*protos.PermitData, error) {
+	deadline := deadline(order)
+	spender := c.chain.Relayer.Address().Hex()
+	typedData, err := c.token.Service.PreparePermit(ctx, *c.token.Domain, order.From, spender, order.Amount, deadline)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	data, err := json.Marshal(typedData)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	return &protos.PermitData{
+		Spender:   spender,
+		Deadline:  deadline,
+		TypedData: data,
+	}, nil
+}
+
+// prepareAuthorization - the authorization of the order amount to the treasury.
+func (p *payment) prepareAuthorization(c *checkout, order *protos.Order) (*protos.AuthorizationData, error) {
+	typedData, err := c.token.Service.PrepareAuthorization(*c.token.Domain, order.From, c.treasury, order.Amount, 0, deadline(order))
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	data, err := json.Marshal(typedData)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	return &protos.AuthorizationData{TypedData: data}, nil
+}
+
+func (p *payment) payToken(ctx context.Context, publicAddress string, pay *protos.PayRequest) (string, error) {
+	order, err := p.orders.GetOrder(ctx, publicAddress, pay.OrderId)
+	if err != nil {
+		return "", err
+	}
+	if order.Status != protos.StatusCreated && order.Status != protos.StatusPaidFailed {
 		return "", ErrAlreadyPaid
 	}
+	if order.Deposit {
+		return "", ErrDepositOrder
+	}
+	if order.Quote != nil && order.Quote.Expired(time.Now().Unix()) {
+		return "", ErrQuoteExpired
+	}
+	c, err := p.orderCheckout(order)
+	if err != nil {
+		return "", err
+	}
+	if pay.Permit != nil {
+		return p.payPermit(ctx, c, order, pay.Permit)
+	}
+	if pay.Authorization != nil {
+		return p.payAuthorization(ctx, c, order, pay.Authorization)
+	}
+
+	var tx *types.Transaction
+	switch {
+	case pay.Pay != nil:
+		tx, err = p.signTransfer(ctx, c, order, pay.Pay)
+	case pay.SignedTx != "":
+		tx, err = p.checkSignedTx(c, order, publicAddress, pay.SignedTx)
+	default:
+		tx, err = p.signPrepared(c, order, publicAddress, pay.Signature)
+	}
+	if err != nil {
+		return "", err
+	}
+	return p.submit(ctx, c, order, tx)
+}
+
+// signTransfer - rebuild the transfer from the request and attach its signature.
+func (p *payment) signTransfer(ctx context.Context, c *checkout, order *protos.Order, in *protos.CommonRequest) (*types.Transaction, error) {
+	if c.token == nil {
+		return nil, errors.Join(ErrInvalidTransaction, fmt.Errorf("native payments must be prepared or signed"))
+	}
+	if order.Amount != in.Amount {
+		return nil, ErrInvalidAmount
+	}
+	if !common.IsHexAddress(in.To) || common.HexToAddress(in.To) != common.HexToAddress(c.treasury) {
+		return nil, ErrInvalidRecipient
+	}
+	nonce, err := c.chain.Client.PendingNonceAt(ctx, common.HexToAddress(order.From))
+	if err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	if nonce != in.Nonce {
+		return nil, erc20.ErrInvalidNonce
+	}
+	tx, err := c.token.Service.SignTransfer(ctx, *in)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	return tx, nil
+}
+
+// signPrepared - attach the signature to the prepared transaction of the order.
+func (p *payment) signPrepared(c *checkout, order *protos.Order, publicAddress, sig string) (*types.Transaction, error) {
+	if order.PreparedTx == "" {
+		return nil, ErrPaymentNotPrepared
+	}
+	bin, err := hexutil.Decode(order.PreparedTx)
+	if err != nil {
+		return nil, errors.Join(ErrPaymentNotPrepared, err)
+	}
+	prepared := new(types.Transaction)
+	if err := prepared.UnmarshalBinary(bin); err != nil {
+		return nil, errors.Join(ErrPaymentNotPrepared, err)
+	}
+
+	signature, err := hexutil.Decode(sig)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidSignature, err)
+	}
+	tx, err := c.signPrepared(prepared, publicAddress, signature)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	return tx, nil
+}
+
+// checkSignedTx - decode the signed raw transaction of the wallet and make
+// sure it pays the order to the treasury.
+func (p *payment) checkSignedTx(c *checkout, order *protos.Order, publicAddress, signedTx string) (*types.Transaction, error) {
+	raw, err := hexutil.Decode(signedTx)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidTransaction, err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, errors.Join(ErrInvalidTransaction, err)
+	}
+	tx, err = c.checkSigned(tx, publicAddress, order)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	return tx, nil
+}
+
+// payPermit - the relayer submits the permit of the buyer and pulls the
+// amount of the order to the treasury. Tokens without permit are paid with
+// the prepared or the signed transfer.
+func (p *payment) payPermit(ctx context.Context, c *checkout, order *protos.Order, in *protos.PermitRequest) (string, error) {
+	if !c.permit() {
+		return "", ErrPermitNotSupported
+	}
+	if in.Deadline <= time.Now().Unix() {
+		return "", errors.Join(ErrInvalidPermit, fmt.Errorf("permit is expired"))
+	}
+	signature, err := hexutil.Decode(in.Signature)
+	if err != nil {
+		return "", errors.Join(ErrInvalidSignature, err)
+	}
+	rl := c.chain.Relayer
+	calls, err := c.token.Service.CheckPermit(ctx, *c.token.Domain, order.From, rl.Address().Hex(),
+		c.treasury, order.Amount, in.Deadline, signature)
+	if err != nil {
+		return "", errors.Join(ErrInvalidPermit, err)
+	}
+	txs, err := rl.Sign(ctx,
+		relayer.Call{To: c.token.Address, Data: calls.Permit},
+		relayer.Call{To: c.token.Address, Data: calls.TransferFrom, Gas: erc20.TRANSFER_FROM_GAS})
+	if err != nil {
+		return "", errors.Join(ErrTransactionFailed, err)
+	}
+
+	// the transferFrom is the payment, its Transfer log is monitored
+	sent := false
+	txHash, err := p.submitWith(ctx, c, order, txs[1], func(ctx context.Context) error {
+		sent = true
+		return rl.Send(ctx, txs...)
+	})
+	if err != nil && !sent {
+		// the payment is lost before the send, the nonces are released
+		err = errors.Join(err, rl.Abandon(ctx, txs...))
+	}
+	return txHash, err
+}
+
+// payAuthorization - the relayer submits the transfer authorization of the
+// buyer, the authorization must send the amount of the order from the buyer
+// to the treasury.
+func (p *payment) payAuthorization(ctx context.Context, c *checkout, order *protos.Order, in *protos.AuthorizationRequest) (string, error) {
+	if !c.authorization() {
+		return "", ErrAuthorizationNotSupported
+	}
+	if !common.IsHexAddress(in.From) || common.HexToAddress(in.From) != common.HexToAddress(order.From) {
+		return "", errors.Join(ErrInvalidAuthorization, fmt.Errorf("authorization is not from the buyer"))
+	}
+	value, ok := new(big.Int).SetString(in.Value, 10)
+	if !ok {
+		return "", errors.Join(ErrInvalidAuthorization, fmt.Errorf("value %s is invalid", in.Value))
+	}
+	nonce, err := erc20.ParseNonce(in.Nonce)
+	if err != nil {
+		return "", errors.Join(ErrInvalidAuthorization, err)
+	}
+	signature, err := hexutil.Decode(in.Signature)
+	if err != nil {
+		return "", errors.Join(ErrInvalidSignature, err)
+	}
+	auth := &erc20.Authorization{
+		From:        common.HexToAddress(in.From),
+		To:          common.HexToAddress(in.To),
+		Value:       value,
+		ValidAfter:  big.NewInt(in.ValidAfter),
+		ValidBefore: big.NewInt(in.ValidBefore),
+		Nonce:       nonce,
+	}
+	input, err := c.token.Service.CheckAuthorization(ctx, *c.token.Domain, auth, c.treasury, order.Amount, signature)
+	if err != nil {
+		return "", errors.Join(ErrInvalidAuthorization, err)
+	}
+	rl := c.chain.Relayer
+	txs, err := rl.Sign(ctx, relayer.Call{To: c.token.Address, Data: input})
+	if err != nil {
+		return "", errors.Join(ErrTransactionFailed, err)
+	}
+
+	order.AuthorizationNonce = nonce.Hex()
+	sent := false
+	txHash, err := p.submitWith(ctx, c, order, txs[0], func(ctx context.Context) error {
+		sent = true
+		return rl.Send(ctx, txs...)
+	})
+	if err != nil && !sent {
+		// the payment is lost before the send, the nonce is released
+		err = errors.Join(err, rl.Abandon(ctx, txs...))
+	}
+	return txHash, err
+}
+
+// submit - store the payment with its monitor request, then broadcast it.
+func (p *payment) submit(ctx context.Context, c *checkout, order *protos.Order, tx *types.Transaction) (string, error) {
+	return p.submitWith(ctx, c, order, tx, func(ctx context.Context) error {
+		return c.send(ctx, tx)
+	})
+}
+
+// submitWith - store the payment of the tx, then broadcast it with send.
+func (p *payment) submitWith(ctx context.Context, c *checkout, order *protos.Order, tx *types.Transaction, send func(ctx context.Context) error) (string, error) {
+	publicAddress, orderId := order.From, order.Id
+
+	// the monitor starts a few blocks before the transaction is sent
+	block, err := c.chain.Client.BlockNumber(ctx)
+	if err != nil {
+		return "", errors.Join(ErrEthereum, err)
+	}
+	var fromBlock uint64
+	if block > rollback {
+		fromBlock = block - rollback
+	}
+
+	message, err := c.monitorRequest(order, p.table, fromBlock, tx)
+	if err != nil {
+		return "", err
+	}
+
+	// the order and its monitor request are stored before the transaction
+	// is sent, so a broadcast payment is never left unmonitored.
+	now := time.Now().Unix()
+	outbox := protos.Outbox{
+		Id:        uuid.NewString(),
+		Message:   message,
+		Status:    protos.OutboxPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	order.Status = protos.StatusPending
+	order.PaymentHash = tx.Hash().Hex()
+	order.PaymentSender, order.PaymentNonce = message.Sender, message.SenderNonce
+	order.PaymentBlock = fromBlock
+	order.UpdatedAt = now
+	order.StatusCreatedAt = fmt.Sprintf("%s#%d", order.Status.String(), order.CreatedAt)
+	mask := []string{"status", "payment_hash", "payment_sender", "payment_nonce", "payment_block", "updated_at", "status_created_at"}
+	if order.AuthorizationNonce != "" {
+		mask = append(mask, "authorization_nonce")
+	}
+	if err := p.orders.PayOrder(ctx, publicAddress, orderId, *order, mask, outbox); err != nil {
+		if storage.IsConditionalCheckFailed(err) {
+			// another request moved the order to pending first
+			return "", ErrAlreadyPaid
+		}
+		return "", errors.Join(ErrDynamodb, err)
+	}
+
+	if err := send(ctx); err != nil {
+		if !chain.IsRejected(err) {
+			// the transaction may be broadcast, the monitor settles the
+			// order from the pending message
+			log.Printf("send payment of order %s: %s", orderId, err)
+			return tx.Hash().Hex(), nil
+		}
+		order.Status = protos.StatusPaidFailed
+		order.UpdatedAt = time.Now().Unix()
+		order.StatusCreatedAt = fmt.Sprintf("%s#%d", order.Status.String(), order.CreatedAt)
+		if dbErr := p.orders.CancelPayment(ctx, publicAddress, orderId, *order,
+			[]string{"status", "updated_at", "status_created_at"}); dbErr != nil {
+			// the monitor will time out and mark the order monitor_failed
+			return "", errors.Join(ErrTransactionFailed, err, ErrDynamodb, dbErr)
+		}
+		cancelOutbox(ctx, p.orders, outbox.Id)
+		return "", errors.Join(ErrTransactionFailed, err)
+	}
+	return tx.Hash().Hex(), nil
+}
+
+// cancelOutbox - cancel the monitor request of a rejected transaction. The
+// relay may have published it already, the monitor then finds the order
+// settled and leaves it as it is.
+func cancelOutbox(ctx context.Context, orders storage.OrderRepository, id string) {
+	if err := orders.CancelOutbox(ctx, id); err != nil && !storage.IsConditionalCheckFailed(err) {
+		log.Printf("cancel outbox %s: %s", id, err)
+	}
 }
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/native"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestPayTokenIdempotency(t *testing.T) {
+	ctx := context.Background()
+	orders := memory.NewOrderRepository()
+	srv := NewPaymentService(nil, orders, "ECOMMERCE")
+	claim := func(key, orderId string, status protos.IdempotencyStatus, response string) {
+		t.Helper()
+		now := time.Now().Unix()
+		err := orders.PutIdempotency(ctx, protos.Idempotency{
+			Key: key, PublicAddress: "0x01", OrderId: orderId, Status: status,
+			Response: response, CreatedAt: now, UpdatedAt: now, ExpireAt: now + 60,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	claim("reused", "order-2", protos.IdempotencyCompleted, "0xaa")
+	claim("running", "order-1", protos.IdempotencyInProgress, "")
+	claim("done", "order-1", protos.IdempotencyCompleted, "0xbb")
+
+	pay := &protos.PayRequest{OrderId: "order-1"}
+	if _, err := srv.PayToken(ctx, "0x01", "reused", pay); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("expected the key of another order to be rejected, got %v", err)
+	}
+	if _, err := srv.PayToken(ctx, "0x01", "running", pay); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("expected the payment to be in progress, got %v", err)
+	}
+	txHash, err := srv.PayToken(ctx, "0x01", "done", pay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txHash != "0xbb" {
+		t.Fatalf("expected the hash of the completed payment, got %s", txHash)
+	}
+
+	// the order does not exist, the key is released for the retry
+	if _, err := srv.PayToken(ctx, "0x01", "new", pay); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the missing order not to be found, got %v", err)
+	}
+	if _, err := orders.GetIdempotency(ctx, "0x01", "new"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the key to be released, got %v", err)
+	}
+}
+
+func TestPayTokenAlreadyPaid(t *testing.T) {
+	ctx := context.Background()
+	orders := memory.NewOrderRepository()
+	srv := NewPaymentService(nil, orders, "ECOMMERCE")
+	if err := orders.PutOrder(ctx, protos.Order{Id: "order-1", From: "0x01", Status: protos.StatusPaid}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.PayToken(ctx, "0x01", "", &protos.PayRequest{OrderId: "order-1"}); !errors.Is(err, ErrAlreadyPaid) {
+		t.Fatalf("expected the paid order to be rejected, got %v", err)
+	}
+	if _, err := srv.PreparePayment(ctx, "0x01", "order-1"); !errors.Is(err, ErrAlreadyPaid) {
+		t.Fatalf("expected the paid order not to be prepared, got %v", err)
+	}
+}
+
+func TestSubmitRejectedAfterRelay(t *testing.T) {
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainId := big.NewInt(11155111)
+	orders := memory.NewOrderRepository()
+	p := &payment{orders: orders, table: "ECOMMERCE"}
+	c := &checkout{
+		chain:    &registry.Chain{Id: chainId, Client: &refundClient{}},
+		treasury: "0x8ba1f109551bD432803012645Ac136ddd64DBA72",
+		native:   &native.Currency{Symbol: "ETH", Decimals: 18},
+	}
+	buyer := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	order := &protos.Order{Id: "order-1", From: buyer, Status: protos.StatusCreated, Quote: &protos.Quote{Value: "1000"}}
+	if err := orders.PutOrder(ctx, *order); err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress(c.treasury)
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{ChainID: chainId, Gas: 21000, To: &to, Value: big.NewInt(1000)}),
+		types.LatestSignerForChainID(chainId), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the relay publishes the monitor request before the node rejects the send
+	_, err = p.submitWith(ctx, c, order, tx, func(ctx context.Context) error {
+		items, err := orders.GetPendingOutbox(ctx)
+		if err != nil || len(items) != 1 {
+			t.Fatalf("expected one pending message, got %d %v", len(items), err)
+		}
+		if err := orders.MarkOutboxSent(ctx, items[0].Id); err != nil {
+			t.Fatal(err)
+		}
+		return errors.New("nonce too low")
+	})
+	if !errors.Is(err, ErrTransactionFailed) || errors.Is(err, ErrDynamodb) {
+		t.Fatalf("expected the payment to fail without a storage error, got %v", err)
+	}
+	got, err := orders.GetOrder(ctx, buyer, order.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != protos.StatusPaidFailed {
+		t.Fatalf("expected the order paid_failed, got %s", got.Status)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/model"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/google/uuid"
 )
@@ -17,17 +16,17 @@ type ProductService interface {
 	GetProducts(ctx context.Context) ([]*protos.Product, error)
 }
 
-type productService struct{}
+type productService struct {
+	products storage.ProductRepository
+}
 
-func NewProductService() ProductService {
-	return &productService{}
+func NewProductService(products storage.ProductRepository) ProductService {
+	return &productService{
+		products: products,
+	}
 }
 func (p *productService) GetProduct(ctx context.Context, id string) (*protos.Product, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	product, err := model.GetProduct(ctx, dynamo, id)
+	product, err := p.products.GetProduct(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -35,25 +34,17 @@ func (p *productService) GetProduct(ctx context.Context, id string) (*protos.Pro
 }
 
 func (p *productService) CreateProduct(ctx context.Context, product *protos.Product) (*protos.Product, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
 	product.Id = uuid.NewString()
 	product.CreatedAt = time.Now().Unix()
 	product.UpdatedAt = time.Now().Unix()
-	err := model.PutProduct(ctx, dynamo, *product)
+	err := p.products.PutProduct(ctx, *product)
 	if err != nil {
 		return nil, err
 	}
 	return product, nil
 }
 func (p *productService) UpdateProduct(ctx context.Context, id string, product *protos.Product, updateMask []string) (*protos.Product, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	newProduct, err := model.UpdateProduct(ctx, dynamo, id, *product, updateMask)
+	newProduct, err := p.products.UpdateProduct(ctx, id, *product, updateMask)
 	if err != nil {
 		return nil, err
 	}
@@ -61,11 +52,7 @@ func (p *productService) UpdateProduct(ctx context.Context, id string, product *
 }
 
 func (p *productService) GetProducts(ctx context.Context) ([]*protos.Product, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	products, err := model.GetAllProducts(ctx, dynamo)
+	products, err := p.products.GetProducts(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/nonce"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type RefundService interface {
+	// Refund - send a refund of the order from the treasury to the buyer.
+	// @param ctx - context
+	// @param orderId - the order id
+	// @param req - the buyer of the order, the amount and the reason
+	// @return *protos.Refund - the pending refund
+	// @return error - error
+	Refund(ctx context.Context, orderId string, req *protos.RefundRequest) (*protos.Refund, error)
+	// GetRefunds - get the refunds of the order.
+	// @param ctx - context
+	// @param publicAddress - the buyer of the order
+	// @param orderId - the order id
+	// @return []protos.Refund - the refunds
+	// @return error - error
+	GetRefunds(ctx context.Context, publicAddress, orderId string) ([]protos.Refund, error)
+}
+
+// refund - the refunds are signed with the nonce managers of the treasury
+// signers, the table is where the monitor settles them.
+type refund struct {
+	payment  *payment
+	orders   storage.OrderRepository
+	managers []*nonce.Manager
+	table    string
+}
+
+func NewRefundService(chains *registry.Chains, orders storage.OrderRepository, managers []*nonce.Manager, table string) RefundService {
+	return &refund{
+		payment:  &payment{chains: chains},
+		orders:   orders,
+		managers: managers,
+		table:    table,
+	}
+}
+
+// isRefundable - the order is paid and no refund is pending, a cancelled
+// order is refundable when it was paid before it was cancelled.
+func isRefundable(order *protos.Order) bool {
+	switch order.Status {
+	case protos.StatusPaid, protos.StatusShipped, protos.StatusDelivered, protos.StatusPartiallyRefunded:
+		return true
+	case protos.StatusCancelled:
+		return order.PaymentHash != ""
+	default:
+		return false
+	}
+}
+
+// refundAmount - the token amount to refund and the status of the order
+// once it is confirmed, zero refunds what is left of the payment.
+func refundAmount(order *protos.Order, amount float64) (float64, protos.Status, error) {
+	left := decimal.NewFromFloat(order.Amount).Sub(decimal.NewFromFloat(order.Refunded))
+	if amount == 0 {
+		amount = left.InexactFloat64()
+	}
+	refund := decimal.NewFromFloat(amount)
+	if refund.Sign() <= 0 || refund.GreaterThan(left) {
+		return 0, protos.StatusUnknow, errors.Join(ErrInvalidAmount, fmt.Errorf("refund %s of %s left", refund, left))
+	}
+	if refund.Equal(left) {
+		return amount, protos.StatusRefunded, nil
+	}
+	return amount, protos.StatusPartiallyRefunded, nil
+}
+
+// Refund - send the amount from the treasury back to the buyer. The refund
+// is stored with its monitor request before it is sent, the monitor settles
+// the order as refunded or partially_refunded.
+func (r *refund) Refund(ctx context.Context, orderId string, req *protos.RefundRequest) (*protos.Refund, error) {
+	order, err := r.orders.GetOrder(ctx, req.From, orderId)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Join(ErrDynamodb, err)
+	}
+	if !isRefundable(order) {
+		return nil, errors.Join(ErrNotRefundable, fmt.Errorf("order is %s", order.Status))
+	}
+	amount, settled, err := refundAmount(order, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.payment.orderCheckout(order)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		symbol   string
+		decimals int
+	)
+	if c.token != nil {
+		symbol, decimals = c.token.Symbol, c.token.Decimals
+	} else {
+		symbol, decimals = c.native.Symbol, c.native.Decimals
+	}
+	signer, ok := c.chain.TreasurySigners[symbol]
+	if !ok {
+		return nil, errors.Join(ErrRefundNotSupported, fmt.Errorf("treasury of %s has no signer", symbol))
+	}
+	// the refunds of several instances share the nonces of the treasury
+	nonces := nonce.Find(r.managers, c.chain.Id, signer.Address())
+	if nonces == nil {
+		return nil, errors.Join(ErrRefundNotSupported, fmt.Errorf("treasury of %s has no nonce manager", symbol))
+	}
+	value := contract.ToWei(amount, decimals)
+
+	// the buyer signed in with the address of the order, the refund is sent
+	// there even when another wallet paid the deposit address
+	var tx *types.Transaction
+	if c.token != nil {
+		tx, err = c.token.Service.PrepareTransfer(ctx, c.treasury, order.From, amount)
+	} else {
+		tx, err = c.native.Service.PrepareTransfer(ctx, c.treasury, order.From, value)
+	}
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+
+	// the monitor starts a few blocks before the refund is sent
+	block, err := c.chain.Client.BlockNumber(ctx)
+	if err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	var fromBlock uint64
+	if block > rollback {
+		fromBlock = block - rollback
+	}
+
+	tx, err = nonces.Sign(ctx, tx)
+	if err != nil {
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+
+	now := time.Now().Unix()
+	item := protos.Refund{
+		Id:        uuid.NewString(),
+		From:      order.From,
+		OrderId:   order.Id,
+		To:        order.From,
+		ChainId:   c.chain.Id.Uint64(),
+		Token:     symbol,
+		Amount:    amount,
+		Value:     value.String(),
+		TxHash:    tx.Hash().Hex(),
+		Status:    protos.RefundPending,
+		Reason:    req.Reason,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	message, err := c.refundMonitorRequest(order, r.table, fromBlock, tx, value)
+	if err != nil {
+		return nil, errors.Join(err, nonces.Abandon(ctx, tx))
+	}
+	message.Refund = &protos.RefundMonitor{
+		Id:       item.Id,
+		Amount:   amount,
+		Status:   settled,
+		Previous: order.Status,
+	}
+	outbox := protos.Outbox{
+		Id:        uuid.NewString(),
+		Message:   message,
+		Status:    protos.OutboxPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	previous := order.Status
+	order.Status = protos.StatusRefundPending
+	order.UpdatedAt = now
+	order.StatusCreatedAt = fmt.Sprintf("%s#%d", order.Status.String(), order.CreatedAt)
+	mask := []string{"status", "updated_at", "status_created_at"}
+	if err := r.orders.RefundOrder(ctx, *order, previous, mask, item, outbox); err != nil {
+		abandonErr := nonces.Abandon(ctx, tx)
+		if storage.IsConditionalCheckFailed(err) {
+			// another refund moved the order first
+			return nil, errors.Join(ErrRefundInProgress, abandonErr)
+		}
+		return nil, errors.Join(ErrDynamodb, err, abandonErr)
+	}
+
+	if err := nonces.Send(ctx, tx); err != nil {
+		if !chain.IsRejected(err) {
+			// the refund may be broadcast, the monitor settles it from the
+			// pending message
+			log.Printf("send refund %s of order %s: %s", item.Id, order.Id, err)
+			return &item, nil
+		}
+		order.Status = previous
+		order.UpdatedAt = time.Now().Unix()
+		order.StatusCreatedAt = fmt.Sprintf("%s#%d", order.Status.String(), order.CreatedAt)
+		if dbErr := r.orders.FailRefund(ctx, *order, mask, item.Id); dbErr != nil {
+			return nil, errors.Join(ErrTransactionFailed, err, ErrDynamodb, dbErr)
+		}
+		cancelOutbox(ctx, r.orders, outbox.Id)
+		return nil, errors.Join(ErrTransactionFailed, err)
+	}
+	return &item, nil
+}
+
+func (r *refund) GetRefunds(ctx context.Context, publicAddress, orderId string) ([]protos.Refund, error) {
+	refunds, err := r.orders.GetRefunds(ctx, publicAddress, orderId)
+	if err != nil {
+		return nil, errors.Join(ErrDynamodb, err)
+	}
+	return refunds, nil
+}
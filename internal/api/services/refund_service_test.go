@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/nonce"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/native"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRefundAmount(t *testing.T) {
+	order := &protos.Order{Amount: 1.5, Refunded: 0.4}
+	tests := []struct {
+		amount  float64
+		want    float64
+		settled protos.Status
+		err     error
+	}{
+		{amount: 0, want: 1.1, settled: protos.StatusRefunded},
+		{amount: 1.1, want: 1.1, settled: protos.StatusRefunded},
+		{amount: 0.1, want: 0.1, settled: protos.StatusPartiallyRefunded},
+		{amount: 1.2, err: ErrInvalidAmount},
+		{amount: -1, err: ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		got, settled, err := refundAmount(order, tt.amount)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("refund %v: got error %v, want %v", tt.amount, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("refund %v: %v", tt.amount, err)
+		}
+		if got != tt.want || settled != tt.settled {
+			t.Fatalf("refund %v: got %v %s, want %v %s", tt.amount, got, settled, tt.want, tt.settled)
+		}
+	}
+
+	if _, _, err := refundAmount(&protos.Order{Amount: 1, Refunded: 1}, 0); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("refunded order: got error %v", err)
+	}
+}
+
+// refundClient - a chain which prepares the value transfers of the treasury
+// and answers the sends with the error.
+type refundClient struct {
+	chain.Client
+	fail   error
+	onSend func()
+	sent   []*types.Transaction
+}
+
+func (c *refundClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return 3, nil
+}
+
+func (c *refundClient) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return 21000, nil
+}
+
+func (c *refundClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (c *refundClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: big.NewInt(1e9)}, nil
+}
+
+func (c *refundClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return 100, nil
+}
+
+func (c *refundClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if c.onSend != nil {
+		c.onSend()
+	}
+	if c.fail != nil {
+		return c.fail
+	}
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+func TestRefund(t *testing.T) {
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := erc20.NewKeySigner(key)
+	client := &refundClient{}
+	chainId := big.NewInt(11155111)
+	chains := registry.NewChains()
+	err = chains.Register(&registry.Chain{
+		Id:              chainId,
+		Client:          client,
+		Tokens:          erc20.NewRegistry(),
+		Native:          &native.Currency{Symbol: "ETH", Decimals: 18, Service: native.NewNativeService(client, chainId)},
+		Treasuries:      map[string]string{"ETH": signer.Address().Hex()},
+		TreasurySigners: map[string]erc20.Signer{"ETH": signer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := memory.NewOrderRepository()
+	managers := []*nonce.Manager{nonce.NewManager(memory.NewNonceRepository(), client, chainId, signer, 0)}
+	srv := NewRefundService(chains, orders, managers, "ECOMMERCE")
+
+	buyer := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+	put := func(id string) {
+		t.Helper()
+		err := orders.PutOrder(ctx, protos.Order{Id: id, From: buyer, ChainId: chainId.Uint64(), Token: "ETH", Amount: 1, Status: protos.StatusPaid, PaymentHash: "0x01"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := func(id string) protos.Status {
+		t.Helper()
+		order, err := orders.GetOrder(ctx, buyer, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order.Status
+	}
+
+	put("order-1")
+	item, err := srv.Refund(ctx, "order-1", &protos.RefundRequest{From: buyer, Amount: 0.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Status != protos.RefundPending || len(client.sent) != 1 || item.TxHash != client.sent[0].Hash().Hex() {
+		t.Fatalf("unexpected refund %+v", item)
+	}
+	if status("order-1") != protos.StatusRefundPending {
+		t.Fatalf("expected the order refund_pending, got %s", status("order-1"))
+	}
+	// the order is refund_pending, another refund waits for the monitor
+	if _, err := srv.Refund(ctx, "order-1", &protos.RefundRequest{From: buyer}); !errors.Is(err, ErrNotRefundable) {
+		t.Fatalf("expected the second refund to be rejected, got %v", err)
+	}
+
+	// a rejected refund restores the order and fails the refund
+	put("order-2")
+	client.fail = errors.New("insufficient funds for gas * price + value")
+	if _, err := srv.Refund(ctx, "order-2", &protos.RefundRequest{From: buyer}); !errors.Is(err, ErrTransactionFailed) {
+		t.Fatalf("expected the refund to fail, got %v", err)
+	}
+	if status("order-2") != protos.StatusPaid {
+		t.Fatalf("expected the order paid, got %s", status("order-2"))
+	}
+	refunds, err := srv.GetRefunds(ctx, buyer, "order-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].Status != protos.RefundFailed {
+		t.Fatalf("unexpected refunds %+v", refunds)
+	}
+
+	// the relay published the monitor request before the node rejected the
+	// refund, the order is restored anyway
+	put("order-published")
+	client.onSend = func() {
+		items, err := orders.GetPendingOutbox(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			if item.Message.OrderId == "order-published" {
+				if err := orders.MarkOutboxSent(ctx, item.Id); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	_, err = srv.Refund(ctx, "order-published", &protos.RefundRequest{From: buyer})
+	if !errors.Is(err, ErrTransactionFailed) || errors.Is(err, ErrDynamodb) {
+		t.Fatalf("expected the refund to fail without a storage error, got %v", err)
+	}
+	client.onSend = nil
+	if status("order-published") != protos.StatusPaid {
+		t.Fatalf("expected the order paid, got %s", status("order-published"))
+	}
+	if refunds, err = srv.GetRefunds(ctx, buyer, "order-published"); err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].Status != protos.RefundFailed {
+		t.Fatalf("unexpected refunds %+v", refunds)
+	}
+
+	// a timeout may follow the broadcast, the monitor settles the refund
+	put("order-3")
+	client.fail = context.DeadlineExceeded
+	if _, err := srv.Refund(ctx, "order-3", &protos.RefundRequest{From: buyer}); err != nil {
+		t.Fatal(err)
+	}
+	if status("order-3") != protos.StatusRefundPending {
+		t.Fatalf("expected the order refund_pending, got %s", status("order-3"))
+	}
+
+	// the treasury has no nonce manager
+	put("order-4")
+	srv = NewRefundService(chains, orders, nil, "ECOMMERCE")
+	if _, err := srv.Refund(ctx, "order-4", &protos.RefundRequest{From: buyer}); !errors.Is(err, ErrRefundNotSupported) {
+		t.Fatalf("expected the refund not to be supported, got %v", err)
+	}
+	if _, err := srv.Refund(ctx, "order-5", &protos.RefundRequest{From: buyer}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the missing order not to be found, got %v", err)
+	}
+}
@@ -9,29 +9,32 @@ import (
 	"testing"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/helper"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// nonceClient - a client which only knows the pending nonce of the accounts.
+type nonceClient struct {
+	chain.Client
+	nonce uint64
+}
+
+func (c *nonceClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return c.nonce, nil
+}
+
 func TestGetToken(t *testing.T) {
-	os.Setenv("PRIVATE_KEY", "./private_key")
-	os.Setenv("RPC", "wss://ethereum-sepolia-rpc.publicnode.com")
 	os.Setenv("JWT_SECRET", "secret")
 	helper.JwtSecretKey = []byte(os.Getenv("JWT_SECRET"))
-	pk, err := os.ReadFile(os.Getenv("PRIVATE_KEY"))
-	if err != nil {
-		t.Fatal(errors.Join(errors.New("private key not found"), err))
-	}
-	privateKey, err := crypto.HexToECDSA(string(pk))
-	if err != nil {
-		t.Fatal(errors.Join(errors.New("invalid private key"), err))
-	}
-	client, err := ethclient.Dial(os.Getenv("RPC"))
+	privateKey, err := crypto.GenerateKey()
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("connect ethereum error"), err))
+		t.Fatal(errors.Join(errors.New("generate private key error"), err))
 	}
+	client := &nonceClient{nonce: 7}
 	publicKey := privateKey.Public()
 	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
 	if !ok {
@@ -55,7 +58,7 @@ func TestGetToken(t *testing.T) {
 	}
 	signature[64] += 27
 
-	tokenString, err := NewUserService(client).GetToken(ctx, from.Hex(), hexutil.Encode(signature))
+	tokenString, err := NewUserService(client, memory.NewUserRepository()).GetToken(ctx, from.Hex(), hexutil.Encode(signature))
 	if err != nil {
 		t.Fatal(errors.Join(errors.New("get token error"), err))
 	}
@@ -8,12 +8,11 @@ import (
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/helper"
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
-	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/model"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
 	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 type UserService interface {
@@ -29,12 +28,14 @@ var (
 )
 
 type userService struct {
-	client *ethclient.Client
+	client chain.Client
+	users  storage.UserRepository
 }
 
-func NewUserService(client *ethclient.Client) UserService {
+func NewUserService(client chain.Client, users storage.UserRepository) UserService {
 	return &userService{
 		client: client,
+		users:  users,
 	}
 }
 
@@ -56,11 +57,7 @@ func (s *userService) GetToken(ctx context.Context, publicAddress string, signat
 }
 
 func (s *userService) GetUserInfo(ctx context.Context, publicAddress string) (*protos.User, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	user, err := model.GetUserInfo(ctx, dynamo, publicAddress)
+	user, err := s.users.GetUser(ctx, publicAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -68,11 +65,7 @@ func (s *userService) GetUserInfo(ctx context.Context, publicAddress string) (*p
 }
 
 func (s *userService) UpdateUserInfo(ctx context.Context, publicAddress string, user *protos.User, updateMask []string) (*protos.User, error) {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return nil, ErrDynamodbClientNotFound
-	}
-	user, err := model.UpdateUserInfo(ctx, dynamo, publicAddress, *user, updateMask)
+	user, err := s.users.UpdateUser(ctx, publicAddress, *user, updateMask)
 	if err != nil {
 		return nil, err
 	}
@@ -81,13 +74,9 @@ func (s *userService) UpdateUserInfo(ctx context.Context, publicAddress string,
 }
 
 func (s *userService) CreateUser(ctx context.Context, user *protos.User) error {
-	dynamo := storage.GetDynamoClient()
-	if dynamo == nil {
-		return ErrDynamodbClientNotFound
-	}
 	user.CreatedAt = time.Now().Unix()
 	user.UpdatedAt = time.Now().Unix()
-	err := model.PutUserInfo(ctx, dynamo, *user)
+	err := s.users.PutUser(ctx, *user)
 	if err != nil {
 		return err
 	}
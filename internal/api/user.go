@@ -6,21 +6,19 @@ import (
 	"net/http"
 
 	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api/services"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-gonic/gin"
 )
 
-var UserApi *userApi
-
 type userApi struct {
 	srv services.UserService
 }
 
-func NewUserApi(client *ethclient.Client) *userApi {
-	UserApi = &userApi{srv: services.NewUserService(client)}
-	return UserApi
+func NewUserApi(client chain.Client, users storage.UserRepository) *userApi {
+	return &userApi{srv: services.NewUserService(client, users)}
 }
 
 func (u *userApi) GetUser(ctx *gin.Context) {
@@ -37,6 +35,7 @@ func (u *userApi) GetUser(ctx *gin.Context) {
 		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
 		return
 	}
+	setETag(ctx, user.Version)
 	utils.Response(ctx, utils.SuccessCode, utils.Success, user)
 }
 
@@ -140,13 +139,35 @@ func (u *userApi) UpdateUser(ctx *gin.Context) {
 		utils.Response(ctx, http.StatusOK, utils.InvalidParamErr, nil)
 		return
 	}
+	if param.User == nil {
+		utils.InvalidParamErr.Message = "Please enter user."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	version, err := ifMatch(ctx)
+	if err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct If-Match."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	// the body never sets the version, the user is updated at the version
+	// it was read, or the If-Match one
+	if version == 0 {
+		current, err := u.srv.GetUserInfo(ctx, token.PublicAddress)
+		if err != nil {
+			operationFailed(ctx, err)
+			return
+		}
+		version = current.Version
+	}
+	param.User.Version = version
 	userInfo, err := u.srv.UpdateUserInfo(ctx, token.PublicAddress, param.User, param.UpdateMask)
 	if err != nil {
-		utils.InternalServerError.Message = fmt.Sprintf("Operation failed, %s.", err.Error())
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		operationFailed(ctx, err)
 		return
 	}
 	userInfo.PublicAddress = token.PublicAddress
+	setETag(ctx, userInfo.Version)
 	utils.Response(ctx, utils.SuccessCode, utils.Success, userInfo)
 }
 
@@ -0,0 +1,164 @@
+// Package app - the api server built from the config. The clients, the
+// services and the handlers belong to the app, so several apps can run side
+// by side, e.g. in the tests.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/api/router"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/client"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/nonce"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/outbox"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/model"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/hdwallet"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/oracle"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// RelayInterval - the interval of the outbox relay
+	RelayInterval = 2 * time.Second
+	// RelayMaxAttempts - the failed publishes before an outbox message is dead
+	RelayMaxAttempts = 10
+	// ProductExpire - the cache of the products
+	ProductExpire = 10 * time.Minute
+)
+
+// App - the api server with its clients and handlers.
+type App struct {
+	Config   *config.AppConfig
+	Dynamo   *storage.DaoClient
+	SQS      *client.SQSClient
+	Chains   *registry.Chains
+	Handlers *api.Handlers
+	Relay    *outbox.Relay
+	Engine   *gin.Engine
+}
+
+// NewDynamo - the client of the table of the config, DynamoDB Local in the
+// dev env.
+func NewDynamo(ctx context.Context, cfg *config.AppConfig) (*storage.DaoClient, error) {
+	if cfg.IsDevEnv() {
+		return storage.NewDevLocalClient(cfg.DB.Table, cfg.DB.Host, cfg.DB.Port)
+	}
+	return storage.NewDynamoClient(ctx, cfg.DB.Region, cfg.DB.Table)
+}
+
+// NewSQS - the client of the queue of the config, the local queue in the
+// dev env.
+func NewSQS(ctx context.Context, cfg *config.AppConfig) (*client.SQSClient, error) {
+	if cfg.IsDevEnv() {
+		return client.NewDevSQSClient(cfg.SQS.URL, cfg.SQS.Host, cfg.SQS.Port), nil
+	}
+	return client.NewSQSClient(ctx, cfg.SQS.Region, cfg.SQS.URL)
+}
+
+// New - validate the config and build the app, the chains are connected by
+// dial and the owner is the address of the admin.
+func New(ctx context.Context, cfg *config.AppConfig, owner string, dial registry.Dialer) (*App, error) {
+	if err := errors.Join(cfg.ValidateChains(), cfg.ValidateTreasuries(), cfg.ValidateOracle(), cfg.ValidateDeposit()); err != nil {
+		return nil, fmt.Errorf("validate config: %w", err)
+	}
+	dynamo, err := NewDynamo(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create dynamo client: %w", err)
+	}
+	sqs, err := NewSQS(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create sqs client: %w", err)
+	}
+	chains, err := registry.Build(ctx, cfg, dial)
+	if err != nil {
+		return nil, fmt.Errorf("connect chains: %w", err)
+	}
+	nonces := model.NewNonceRepository(dynamo)
+	nonce.UseForRelayers(nonces, chains)
+
+	prices, err := registry.BuildOracle(cfg, chains)
+	if err != nil {
+		return nil, fmt.Errorf("create oracle: %w", err)
+	}
+	var xpub *hdwallet.ExtendedKey
+	if cfg.Deposit != nil {
+		if xpub, err = hdwallet.ParseExtendedKey(cfg.Deposit.XPub); err != nil {
+			return nil, fmt.Errorf("parse deposit xpub: %w", err)
+		}
+	}
+	defaultChain, err := chains.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		users    = model.NewUserRepository(dynamo)
+		products = model.NewProductRepository(dynamo)
+		orders   = model.NewOrderRepository(dynamo)
+	)
+	handlers := &api.Handlers{
+		User:    api.NewUserApi(defaultChain.Client, users),
+		Product: api.NewProductApi(products, ProductExpire, cfg.Currency),
+		Order:   api.NewOrderApi(orders, users, products, chains, oracle.NewQuoter(prices, cfg.QuoteTTL), cfg.Currency, xpub),
+		Payment: api.NewPaymentApi(chains, orders, cfg.DB.Table),
+		Refund:  api.NewRefundApi(chains, orders, nonce.Managers(nonces, chains, 0), cfg.DB.Table),
+	}
+	return &App{
+		Config:   cfg,
+		Dynamo:   dynamo,
+		SQS:      sqs,
+		Chains:   chains,
+		Handlers: handlers,
+		Relay:    outbox.NewRelay(orders, sqs, RelayInterval, RelayMaxAttempts),
+		Engine:   newEngine(owner, handlers),
+	}, nil
+}
+
+// newEngine - the routes of the handlers.
+func newEngine(owner string, handlers *api.Handlers) *gin.Engine {
+	engine := gin.New()
+	engine.Use(cors.Default())
+	engine.Use(gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		c.AbortWithStatusJSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "Service internal exception!",
+		})
+	}))
+	router.RegisterRoutes(engine, owner, handlers)
+	return engine
+}
+
+// Run - serve the http port of the config and relay the outbox until the
+// context is done, then shut the server down.
+func (a *App) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", a.Config.HttpPort),
+		Handler: a.Engine,
+	}
+	go a.Relay.Run(ctx)
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Printf("shutdown server failed: %s", err)
+		}
+	}()
+	log.Println("Server started success")
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		log.Println("Server was shutdown gracefully")
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20/binding"
+	"github.com/ethereum/go-ethereum"
+)
+
+// chainClient - a client which only knows its chain id and the metadata of
+// its token.
+type chainClient struct {
+	chain.Client
+	chainId *big.Int
+}
+
+func (c *chainClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return c.chainId, nil
+}
+
+func (c *chainClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	tokenABI, err := binding.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	method, err := tokenABI.MethodById(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	switch method.Name {
+	case "name":
+		return method.Outputs.Pack("USD Coin")
+	case "symbol":
+		return method.Outputs.Pack("USDC")
+	case "decimals":
+		return method.Outputs.Pack(uint8(6))
+	case "totalSupply":
+		return method.Outputs.Pack(big.NewInt(1e12))
+	}
+	return nil, errors.New("method not supported")
+}
+
+func newConfig(chainId uint64, table string) *config.AppConfig {
+	token := "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"
+	treasury := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+	return &config.AppConfig{
+		Env:      "dev",
+		Currency: "USD",
+		QuoteTTL: time.Minute,
+		Oracle:   &config.Oracle{Type: config.StaticOracle, Prices: map[string]float64{"USDC/USD": 1}},
+		Chains: []*config.Chain{{
+			ChainId: chainId,
+			Name:    "simulated",
+			EthUrl:  "simulated",
+			Tokens:  []*config.Token{{FilePath: "ERC20", Address: token, Symbol: "usdc", Decimals: 6}},
+		}},
+		Treasuries: []*config.Treasury{{ChainId: chainId, Token: token, Address: treasury}},
+		DB:         &config.Dyanmodb{Host: "localhost", Port: 8000, Table: table},
+		SQS:        &config.SQS{Host: "localhost", Port: 9324, URL: "http://localhost:9324/queue/monitor"},
+	}
+}
+
+func TestNew(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	dial := func(ctx context.Context, url string) (chain.Client, error) {
+		return &chainClient{chainId: big.NewInt(1337)}, nil
+	}
+	ctx := context.Background()
+	a, err := New(ctx, newConfig(1337, "A"), "0x01", dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(ctx, newConfig(1337, "B"), "0x02", dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Dynamo == b.Dynamo || a.Dynamo.Table != "A" || b.Dynamo.Table != "B" {
+		t.Fatal("the apps share the dynamo client")
+	}
+	if a.Handlers == b.Handlers || a.Handlers.Order == b.Handlers.Order || a.Chains == b.Chains {
+		t.Fatal("the apps share the handlers")
+	}
+	for _, engine := range []http.Handler{a.Engine, b.Engine} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/info", nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected the request without token to be unauthorized, got %d", w.Code)
+		}
+	}
+
+	// the config is validated
+	cfg := newConfig(1337, "C")
+	cfg.Oracle = nil
+	if _, err := New(ctx, cfg, "0x03", dial); !errors.Is(err, config.ErrInvalidOracle) {
+		t.Fatalf("expected the config without oracle to be rejected, got %v", err)
+	}
+}
@@ -68,3 +68,8 @@ func Send[data *protos.CreateMonitorRequest](ctx context.Context, sqsClient *SQS
 	}
 	return nil
 }
+
+// Publish - send the monitor request to the queue.
+func (c *SQSClient) Publish(ctx context.Context, req *protos.CreateMonitorRequest) error {
+	return Send(ctx, c, req)
+}
@@ -1,5 +1,24 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/hdwallet"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// NativeToken - the token of the treasury receiving the native currency.
+const NativeToken = "native"
+
+// Oracle types.
+const (
+	StaticOracle    = "static"
+	ChainlinkOracle = "chainlink"
+)
+
 const (
 	Dev = "dev"
 	Pre = "pre"
@@ -7,21 +26,135 @@ const (
 )
 
 type AppConfig struct {
-	HttpPort uint64    `yaml:"http_port"`
-	Env      string    `yaml:"env"`
-	EthUrl   string    `yaml:"eth_url"`
-	Secret   string    `yaml:"secret"`
-	Owner    string    `yaml:"owner"`
-	Token    *Token    `yaml:"token"`
-	DB       *Dyanmodb `yaml:"db"`
-	SQS      *SQS      `yaml:"sqs"`
+	HttpPort   uint64        `yaml:"http_port"`
+	Env        string        `yaml:"env"`
+	Secret     string        `yaml:"secret"`
+	Owner      string        `yaml:"owner"`
+	Currency   string        `yaml:"currency"`
+	QuoteTTL   time.Duration `yaml:"quote_ttl"`
+	FeeTTL     time.Duration `yaml:"fee_ttl"`
+	Oracle     *Oracle       `yaml:"oracle"`
+	Deposit    *Deposit      `yaml:"deposit"`
+	Chains     []*Chain      `yaml:"chains"`
+	Treasuries []*Treasury   `yaml:"treasuries"`
+	DB         *Dyanmodb     `yaml:"db"`
+	SQS        *SQS          `yaml:"sqs"`
+}
+
+// Chain - an EVM chain accepting payments with its rpc, tokens, the
+// optional native currency and the optional relayer of the permits.
+type Chain struct {
+	ChainId uint64   `yaml:"chain_id"`
+	Name    string   `yaml:"name"`
+	EthUrl  string   `yaml:"eth_url"`
+	Tokens  []*Token `yaml:"tokens"`
+	Native  *Native  `yaml:"native"`
+	Relayer *Relayer `yaml:"relayer"`
 }
+
+// Token - an erc20 token, the domain is required by the permits of EIP-2612
+// and the authorizations of EIP-3009.
 type Token struct {
-	FilePath string `yaml:"file_path"`
-	Address  string `yaml:"address"`
+	FilePath      string  `yaml:"file_path"`
+	Address       string  `yaml:"address"`
+	Symbol        string  `yaml:"symbol"`
+	Decimals      int     `yaml:"decimals"`
+	Domain        *Domain `yaml:"domain"`
+	Permit        bool    `yaml:"permit"`
+	Authorization bool    `yaml:"authorization"`
+}
+
+// Domain - the EIP-712 domain of the token contract.
+type Domain struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// Relayer - the signer submitting the permits. The fee cap and the daily
+// budget are in wei, zero is unlimited.
+type Relayer struct {
+	Signer      `yaml:",inline"`
+	MaxGas      uint64 `yaml:"max_gas"`
+	MaxFeeCap   uint64 `yaml:"max_fee_cap"`
+	DailyBudget uint64 `yaml:"daily_budget"`
+}
+
+// Native - the native currency of the chain.
+type Native struct {
 	Symbol   string `yaml:"symbol"`
 	Decimals int    `yaml:"decimals"`
 }
+
+// Oracle - the price source of the quotes, the static prices are loaded from
+// the file or inline and the chainlink feeds are read on the chain. A quote
+// fails when its feed errors or is stale, the inline prices are used instead
+// only when the fallback is enabled.
+type Oracle struct {
+	Type     string             `yaml:"type"`
+	File     string             `yaml:"file"`
+	Prices   map[string]float64 `yaml:"prices"`
+	ChainId  uint64             `yaml:"chain_id"`
+	Feeds    map[string]string  `yaml:"feeds"`
+	MaxAge   time.Duration      `yaml:"max_age"`
+	Fallback bool               `yaml:"fallback"`
+}
+
+// Deposit - the orders paid to their own deposit address, the addresses are
+// derived from the xpub by the order index. The key is the env of the file
+// of the matching xprv, only the sweeper reads it.
+type Deposit struct {
+	XPub string `yaml:"xpub"`
+	Key  string `yaml:"key"`
+}
+
+// Treasury - the merchant address receiving the payments of the token on the
+// chain. The refunds of the token are signed by its signer and are not
+// accepted without one.
+type Treasury struct {
+	ChainId uint64 `yaml:"chain_id"`
+	Token   string `yaml:"token"`
+	Address string `yaml:"address"`
+	Signer  `yaml:",inline"`
+}
+
+// Signer - the key of a merchant address, one of them is set. The key is the
+// env of a hex key file, the keystore is the env of an encrypted keystore file
+// unlocked by the file of the password env, and the remote is the url of a
+// clef or web3signer signing for the remote address with the remote method.
+type Signer struct {
+	Key           string `yaml:"key"`
+	Keystore      string `yaml:"keystore"`
+	Password      string `yaml:"password"`
+	Remote        string `yaml:"remote"`
+	RemoteMethod  string `yaml:"remote_method"`
+	RemoteAddress string `yaml:"remote_address"`
+}
+
+// IsSet - the signer has a key, a keystore or a remote.
+func (s *Signer) IsSet() bool {
+	return s.Key != "" || s.Keystore != "" || s.Remote != ""
+}
+
+// Validate - only one source of the key is set, the keystore has a password
+// and the remote has an address.
+func (s *Signer) Validate() error {
+	set := 0
+	for _, val := range []string{s.Key, s.Keystore, s.Remote} {
+		if val != "" {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return errors.Join(ErrInvalidSigner, fmt.Errorf("only one of key, keystore and remote is allowed"))
+	case s.Keystore != "" && s.Password == "":
+		return errors.Join(ErrInvalidSigner, fmt.Errorf("keystore %s has no password", s.Keystore))
+	case s.Remote != "" && !common.IsHexAddress(s.RemoteAddress):
+		return errors.Join(ErrInvalidSigner, fmt.Errorf("address %s of remote %s is invalid", s.RemoteAddress, s.Remote))
+	}
+	return nil
+}
+
 type Dyanmodb struct {
 	Host   string `yaml:"host"`
 	Port   uint64 `yaml:"port"`
@@ -36,6 +169,205 @@ type SQS struct {
 	URL    string `yaml:"url"`
 }
 
+var (
+	ErrInvalidChain     = errors.New("invalid chain")
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrInvalidTreasury  = errors.New("invalid treasury")
+	ErrTreasuryNotFound = errors.New("treasury not found")
+	ErrInvalidOracle    = errors.New("invalid oracle")
+	ErrInvalidDeposit   = errors.New("invalid deposit")
+	ErrInvalidSigner    = errors.New("invalid signer")
+)
+
 func (cfg *AppConfig) IsDevEnv() bool {
 	return cfg.Env == "dev"
 }
+
+// ValidateChains - every chain must have an id and a rpc, the ids are unique
+// and the tokens of each chain are valid.
+func (cfg *AppConfig) ValidateChains() error {
+	if len(cfg.Chains) == 0 {
+		return errors.Join(ErrInvalidChain, fmt.Errorf("no chain configured"))
+	}
+	seen := make(map[uint64]struct{}, len(cfg.Chains))
+	for _, val := range cfg.Chains {
+		if val.ChainId == 0 || val.EthUrl == "" {
+			return errors.Join(ErrInvalidChain, fmt.Errorf("chain id or rpc of %s is empty", val.Name))
+		}
+		if _, ok := seen[val.ChainId]; ok {
+			return errors.Join(ErrInvalidChain, fmt.Errorf("duplicate chain %d", val.ChainId))
+		}
+		seen[val.ChainId] = struct{}{}
+		if err := val.ValidateTokens(); err != nil {
+			return errors.Join(err, fmt.Errorf("chain %d", val.ChainId))
+		}
+		if val.Relayer != nil && !val.Relayer.IsSet() {
+			return errors.Join(ErrInvalidChain, fmt.Errorf("relayer signer of %d is empty", val.ChainId))
+		}
+		if val.Relayer != nil {
+			if err := val.Relayer.Validate(); err != nil {
+				return errors.Join(err, fmt.Errorf("relayer of %d", val.ChainId))
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateTokens - every token must have an address, a symbol and decimals,
+// and the symbols and the addresses are unique on the chain.
+func (c *Chain) ValidateTokens() error {
+	if len(c.Tokens) == 0 {
+		return errors.Join(ErrInvalidToken, fmt.Errorf("no token configured"))
+	}
+	symbols := make(map[string]struct{}, len(c.Tokens))
+	addresses := make(map[string]struct{}, len(c.Tokens))
+	for _, val := range c.Tokens {
+		if !common.IsHexAddress(val.Address) {
+			return errors.Join(ErrInvalidToken, fmt.Errorf("address %s is invalid", val.Address))
+		}
+		if val.Symbol == "" || val.FilePath == "" {
+			return errors.Join(ErrInvalidToken, fmt.Errorf("symbol or abi of %s is empty", val.Address))
+		}
+		if val.Decimals <= 0 {
+			return errors.Join(ErrInvalidToken, fmt.Errorf("decimals of %s is invalid", val.Symbol))
+		}
+		if (val.Permit || val.Authorization) && (val.Domain == nil || val.Domain.Name == "" || val.Domain.Version == "") {
+			return errors.Join(ErrInvalidToken, fmt.Errorf("domain of %s is incomplete", val.Symbol))
+		}
+		symbol, address := strings.ToUpper(val.Symbol), strings.ToLower(val.Address)
+		if _, ok := symbols[symbol]; ok {
+			return errors.Join(ErrInvalidToken, fmt.Errorf("duplicate symbol %s", val.Symbol))
+		}
+		if _, ok := addresses[address]; ok {
+			return errors.Join(ErrInvalidToken, fmt.Errorf("duplicate address %s", val.Address))
+		}
+		symbols[symbol], addresses[address] = struct{}{}, struct{}{}
+	}
+	if c.Native == nil {
+		return nil
+	}
+	if c.Native.Symbol == "" || c.Native.Decimals <= 0 {
+		return errors.Join(ErrInvalidToken, fmt.Errorf("native currency is incomplete"))
+	}
+	if _, ok := symbols[strings.ToUpper(c.Native.Symbol)]; ok {
+		return errors.Join(ErrInvalidToken, fmt.Errorf("duplicate symbol %s", c.Native.Symbol))
+	}
+	return nil
+}
+
+// ValidateTreasuries - every treasury must be a non-zero address and each
+// chain and token pair can only have one treasury.
+func (cfg *AppConfig) ValidateTreasuries() error {
+	if len(cfg.Treasuries) == 0 {
+		return errors.Join(ErrInvalidTreasury, fmt.Errorf("no treasury configured"))
+	}
+	seen := make(map[string]struct{}, len(cfg.Treasuries))
+	for _, val := range cfg.Treasuries {
+		if val.ChainId == 0 {
+			return errors.Join(ErrInvalidTreasury, fmt.Errorf("chain id of %s is empty", val.Address))
+		}
+		if val.Token != NativeToken && !common.IsHexAddress(val.Token) {
+			return errors.Join(ErrInvalidTreasury, fmt.Errorf("token %s is not an address", val.Token))
+		}
+		if !common.IsHexAddress(val.Address) || common.HexToAddress(val.Address) == (common.Address{}) {
+			return errors.Join(ErrInvalidTreasury, fmt.Errorf("address %s is invalid", val.Address))
+		}
+		if signer := val.signer(); signer.IsSet() {
+			if err := signer.Validate(); err != nil {
+				return errors.Join(ErrInvalidTreasury, err)
+			}
+		}
+		key := fmt.Sprintf("%d#%s", val.ChainId, strings.ToLower(val.Token))
+		if _, ok := seen[key]; ok {
+			return errors.Join(ErrInvalidTreasury, fmt.Errorf("duplicate treasury of token %s on chain %d", val.Token, val.ChainId))
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
+
+// GetTreasury - the treasury address of the token on the chain.
+func (cfg *AppConfig) GetTreasury(chainId uint64, token string) (string, error) {
+	for _, val := range cfg.Treasuries {
+		if val.ChainId == chainId && strings.EqualFold(val.Token, token) {
+			return common.HexToAddress(val.Address).Hex(), nil
+		}
+	}
+	return "", errors.Join(ErrTreasuryNotFound, fmt.Errorf("token %s on chain %d", token, chainId))
+}
+
+// GetTreasurySigner - the signer of the treasury, nil when the treasury has
+// none. The remote signer signs for the treasury address.
+func (cfg *AppConfig) GetTreasurySigner(chainId uint64, token string) *Signer {
+	for _, val := range cfg.Treasuries {
+		if val.ChainId == chainId && strings.EqualFold(val.Token, token) && val.IsSet() {
+			signer := val.signer()
+			return &signer
+		}
+	}
+	return nil
+}
+
+// signer - the remote signer of the treasury signs for the treasury address.
+func (t *Treasury) signer() Signer {
+	signer := t.Signer
+	if signer.RemoteAddress == "" {
+		signer.RemoteAddress = t.Address
+	}
+	return signer
+}
+
+// ValidateOracle - the store currency and the quote ttl must be set, a static
+// oracle needs a file or prices and a chainlink oracle needs the feeds on a
+// configured chain. The fallback of a chainlink oracle needs the prices.
+func (cfg *AppConfig) ValidateOracle() error {
+	if cfg.Currency == "" || cfg.QuoteTTL <= 0 {
+		return errors.Join(ErrInvalidOracle, fmt.Errorf("currency or quote ttl is empty"))
+	}
+	if cfg.Oracle == nil {
+		return errors.Join(ErrInvalidOracle, fmt.Errorf("no oracle configured"))
+	}
+	switch cfg.Oracle.Type {
+	case StaticOracle:
+		if cfg.Oracle.File == "" && len(cfg.Oracle.Prices) == 0 {
+			return errors.Join(ErrInvalidOracle, fmt.Errorf("file or prices of static oracle is empty"))
+		}
+	case ChainlinkOracle:
+		if len(cfg.Oracle.Feeds) == 0 {
+			return errors.Join(ErrInvalidOracle, fmt.Errorf("feeds of chainlink oracle is empty"))
+		}
+		if cfg.Oracle.Fallback && len(cfg.Oracle.Prices) == 0 {
+			return errors.Join(ErrInvalidOracle, fmt.Errorf("prices of chainlink oracle fallback is empty"))
+		}
+		for pair, address := range cfg.Oracle.Feeds {
+			if !common.IsHexAddress(address) {
+				return errors.Join(ErrInvalidOracle, fmt.Errorf("feed %s of %s is invalid", address, pair))
+			}
+		}
+		for _, val := range cfg.Chains {
+			if val.ChainId == cfg.Oracle.ChainId {
+				return nil
+			}
+		}
+		return errors.Join(ErrInvalidOracle, fmt.Errorf("chain %d of chainlink oracle is not configured", cfg.Oracle.ChainId))
+	default:
+		return errors.Join(ErrInvalidOracle, fmt.Errorf("unknown type %s", cfg.Oracle.Type))
+	}
+	return nil
+}
+
+// ValidateDeposit - the deposit mode is optional, when it is on the xpub must
+// be a public key so the app never holds the keys of the deposits.
+func (cfg *AppConfig) ValidateDeposit() error {
+	if cfg.Deposit == nil {
+		return nil
+	}
+	key, err := hdwallet.ParseExtendedKey(cfg.Deposit.XPub)
+	if err != nil {
+		return errors.Join(ErrInvalidDeposit, err)
+	}
+	if key.IsPrivate() {
+		return errors.Join(ErrInvalidDeposit, fmt.Errorf("xpub is a private key"))
+	}
+	return nil
+}
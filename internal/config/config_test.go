@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateTreasuries(t *testing.T) {
+	token := "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"
+	treasury := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+	cases := []struct {
+		name       string
+		treasuries []*Treasury
+		valid      bool
+	}{
+		{"valid", []*Treasury{{ChainId: 1, Token: token, Address: treasury}}, true},
+		{"remote signer", []*Treasury{{ChainId: 1, Token: token, Address: treasury, Signer: Signer{Remote: "http://localhost:8550"}}}, true},
+		{"key and keystore", []*Treasury{{ChainId: 1, Token: token, Address: treasury, Signer: Signer{Key: "TREASURY_KEY", Keystore: "TREASURY_KEYSTORE"}}}, false},
+		{"empty", nil, false},
+		{"zero address", []*Treasury{{ChainId: 1, Token: token, Address: "0x0000000000000000000000000000000000000000"}}, false},
+		{"invalid address", []*Treasury{{ChainId: 1, Token: token, Address: "treasury"}}, false},
+		{"missing chain", []*Treasury{{Token: token, Address: treasury}}, false},
+		{"duplicate", []*Treasury{
+			{ChainId: 1, Token: token, Address: treasury},
+			{ChainId: 1, Token: "0x1c7d4b196cb0c7b01d743fbc6116a902379c7238", Address: treasury},
+		}, false},
+	}
+	for _, c := range cases {
+		cfg := &AppConfig{Treasuries: c.treasuries}
+		err := cfg.ValidateTreasuries()
+		if c.valid && err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if !c.valid && !errors.Is(err, ErrInvalidTreasury) {
+			t.Fatalf("%s: expected invalid treasury, got %v", c.name, err)
+		}
+	}
+}
+
+func TestGetTreasury(t *testing.T) {
+	cfg := &AppConfig{Treasuries: []*Treasury{{
+		ChainId: 11155111,
+		Token:   "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238",
+		Address: "0x8ba1f109551bd432803012645ac136ddd64dba72",
+	}}}
+	treasury, err := cfg.GetTreasury(11155111, "0x1c7d4b196cb0c7b01d743fbc6116a902379c7238")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if treasury != "0x8ba1f109551bD432803012645Ac136ddd64DBA72" {
+		t.Fatalf("unexpected treasury %s", treasury)
+	}
+	if _, err := cfg.GetTreasury(1, "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"); !errors.Is(err, ErrTreasuryNotFound) {
+		t.Fatalf("expected treasury not found, got %v", err)
+	}
+}
+
+func TestValidateTokens(t *testing.T) {
+	usdc := &Token{FilePath: "ERC20", Address: "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238", Symbol: "USDC", Decimals: 6}
+	dai := &Token{FilePath: "ERC20", Address: "0x3e622317f8C93f7328350cF0B56d9eD4C620C5d6", Symbol: "DAI", Decimals: 18}
+	cases := []struct {
+		name   string
+		tokens []*Token
+		valid  bool
+	}{
+		{"valid", []*Token{usdc, dai}, true},
+		{"empty", nil, false},
+		{"duplicate symbol", []*Token{usdc, {FilePath: "ERC20", Address: dai.Address, Symbol: "usdc", Decimals: 18}}, false},
+		{"duplicate address", []*Token{usdc, {FilePath: "ERC20", Address: usdc.Address, Symbol: "USDT", Decimals: 6}}, false},
+		{"missing decimals", []*Token{{FilePath: "ERC20", Address: dai.Address, Symbol: "DAI"}}, false},
+		{"permit", []*Token{{FilePath: "ERC20", Address: usdc.Address, Symbol: "USDC", Decimals: 6, Domain: &Domain{Name: "USDC", Version: "2"}, Permit: true}}, true},
+		{"incomplete domain", []*Token{{FilePath: "ERC20", Address: usdc.Address, Symbol: "USDC", Decimals: 6, Domain: &Domain{Name: "USDC"}, Authorization: true}}, false},
+		{"missing domain", []*Token{{FilePath: "ERC20", Address: usdc.Address, Symbol: "USDC", Decimals: 6, Permit: true}}, false},
+	}
+	for _, c := range cases {
+		chain := &Chain{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: c.tokens}
+		err := chain.ValidateTokens()
+		if c.valid && err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if !c.valid && !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: expected invalid token, got %v", c.name, err)
+		}
+	}
+}
+
+func TestValidateChains(t *testing.T) {
+	tokens := []*Token{{FilePath: "ERC20", Address: "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238", Symbol: "USDC", Decimals: 6}}
+	cases := []struct {
+		name   string
+		chains []*Chain
+		err    error
+	}{
+		{"valid", []*Chain{
+			{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: tokens},
+			{ChainId: 8453, EthUrl: "http://localhost:8546", Tokens: tokens},
+		}, nil},
+		{"empty", nil, ErrInvalidChain},
+		{"missing rpc", []*Chain{{ChainId: 1, Tokens: tokens}}, ErrInvalidChain},
+		{"duplicate", []*Chain{
+			{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: tokens},
+			{ChainId: 1, EthUrl: "http://localhost:8546", Tokens: tokens},
+		}, ErrInvalidChain},
+		{"invalid token", []*Chain{{ChainId: 1, EthUrl: "http://localhost:8545"}}, ErrInvalidToken},
+		{"missing relayer key", []*Chain{{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: tokens, Relayer: &Relayer{}}}, ErrInvalidChain},
+		{"relayer keystore", []*Chain{{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: tokens,
+			Relayer: &Relayer{Signer: Signer{Keystore: "RELAYER_KEYSTORE", Password: "RELAYER_PASSWORD"}}}}, nil},
+		{"relayer keystore without password", []*Chain{{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: tokens,
+			Relayer: &Relayer{Signer: Signer{Keystore: "RELAYER_KEYSTORE"}}}}, ErrInvalidSigner},
+		{"relayer remote without address", []*Chain{{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: tokens,
+			Relayer: &Relayer{Signer: Signer{Remote: "http://localhost:8550"}}}}, ErrInvalidSigner},
+		{"relayer key and remote", []*Chain{{ChainId: 1, EthUrl: "http://localhost:8545", Tokens: tokens,
+			Relayer: &Relayer{Signer: Signer{Key: "RELAYER_KEY", Remote: "http://localhost:8550", RemoteAddress: "0x8ba1f109551bD432803012645Ac136ddd64DBA72"}}}}, ErrInvalidSigner},
+	}
+	for _, c := range cases {
+		cfg := &AppConfig{Chains: c.chains}
+		err := cfg.ValidateChains()
+		if c.err == nil && err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Fatalf("%s: expected %s, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestValidateOracle(t *testing.T) {
+	chains := []*Chain{{ChainId: 11155111}}
+	feeds := map[string]string{"ETH/USD": "0x694AA1769357215DE4FAC081bf1f309aDC325306"}
+	cases := []struct {
+		name   string
+		oracle *Oracle
+		ttl    time.Duration
+		err    error
+	}{
+		{"static", &Oracle{Type: StaticOracle, Prices: map[string]float64{"ETH/USD": 3000}}, time.Minute, nil},
+		{"chainlink", &Oracle{Type: ChainlinkOracle, ChainId: 11155111, Feeds: feeds}, time.Minute, nil},
+		{"chainlink fallback", &Oracle{Type: ChainlinkOracle, ChainId: 11155111, Feeds: feeds, Prices: map[string]float64{"ETH/USD": 3000}, Fallback: true}, time.Minute, nil},
+		{"fallback without prices", &Oracle{Type: ChainlinkOracle, ChainId: 11155111, Feeds: feeds, Fallback: true}, time.Minute, ErrInvalidOracle},
+		{"missing ttl", &Oracle{Type: StaticOracle, File: "prices.yaml"}, 0, ErrInvalidOracle},
+		{"missing oracle", nil, time.Minute, ErrInvalidOracle},
+		{"empty static", &Oracle{Type: StaticOracle}, time.Minute, ErrInvalidOracle},
+		{"unknown chain", &Oracle{Type: ChainlinkOracle, ChainId: 1, Feeds: feeds}, time.Minute, ErrInvalidOracle},
+		{"invalid feed", &Oracle{Type: ChainlinkOracle, ChainId: 11155111, Feeds: map[string]string{"ETH/USD": "feed"}}, time.Minute, ErrInvalidOracle},
+		{"unknown type", &Oracle{Type: "api"}, time.Minute, ErrInvalidOracle},
+	}
+	for _, c := range cases {
+		cfg := &AppConfig{Currency: "USD", QuoteTTL: c.ttl, Oracle: c.oracle, Chains: chains}
+		err := cfg.ValidateOracle()
+		if c.err == nil && err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Fatalf("%s: expected %s, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestValidateDeposit(t *testing.T) {
+	xpub := "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+	xprv := "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7"
+	cases := []struct {
+		name    string
+		deposit *Deposit
+		valid   bool
+	}{
+		{"disabled", nil, true},
+		{"xpub", &Deposit{XPub: xpub, Key: "DEPOSIT_KEY"}, true},
+		{"xprv", &Deposit{XPub: xprv}, false},
+		{"invalid", &Deposit{XPub: "xpub"}, false},
+	}
+	for _, c := range cases {
+		cfg := &AppConfig{Deposit: c.deposit}
+		err := cfg.ValidateDeposit()
+		if c.valid && err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if !c.valid && !errors.Is(err, ErrInvalidDeposit) {
+			t.Fatalf("%s: expected invalid deposit, got %v", c.name, err)
+		}
+	}
+}
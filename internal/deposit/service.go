@@ -0,0 +1,307 @@
+package deposit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/monitor"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/hdwallet"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/relayer"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	ErrDynamodbClientNotFound = errors.New("dynamodb client not found")
+	ErrEthereum               = errors.New("ethereum operation failed")
+	ErrInvalidDeposit         = errors.New("invalid deposit order")
+	ErrNoRelayer              = errors.New("no relayer to fund the gas of the sweep")
+	ErrQuoteExpired           = errors.New("quote expired before the deposit")
+)
+
+// transferGas - the gas of a plain transfer of the native currency.
+const transferGas uint64 = 21000
+
+// Change - what happened to one deposit order.
+type Change struct {
+	OrderId string `json:"order_id"`
+	From    string `json:"from"`
+	Address string `json:"address"`
+	TxHash  string `json:"tx_hash,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report - what a deposit run paid, funded and swept.
+type Report struct {
+	StartedAt int64    `json:"started_at"`
+	Checked   int      `json:"checked"`
+	Changed   []Change `json:"changed"`
+	Failed    []Change `json:"failed"`
+}
+
+// Service - settle the orders paid to their deposit addresses and sweep
+// the deposits to the treasuries. The key is the xprv of the deposit
+// addresses, the orders are not swept when it is nil.
+type Service struct {
+	orders storage.OrderRepository
+	chains *registry.Chains
+	key    *hdwallet.ExtendedKey
+}
+
+func NewService(orders storage.OrderRepository, chains *registry.Chains, key *hdwallet.ExtendedKey) *Service {
+	return &Service{
+		orders: orders,
+		chains: chains,
+		key:    key,
+	}
+}
+
+// Run - mark the created deposit orders which received their value as
+// paid, then sweep the paid ones.
+func (s *Service) Run(ctx context.Context) (*Report, error) {
+	if s.orders == nil {
+		return nil, ErrDynamodbClientNotFound
+	}
+	report := &Report{
+		StartedAt: time.Now().Unix(),
+		Changed:   []Change{},
+		Failed:    []Change{},
+	}
+	created, err := s.orders.GetOrdersByStatus(ctx, protos.StatusCreated)
+	if err != nil {
+		return report, err
+	}
+	for i := range created {
+		order := &created[i]
+		if !order.Deposit || order.DepositAddress == "" {
+			continue
+		}
+		report.Checked++
+		s.record(ctx, report, order, s.watch)
+	}
+
+	if s.key == nil {
+		return report, nil
+	}
+	paid, err := s.orders.GetOrdersByStatus(ctx, protos.StatusPaid, protos.StatusShipped, protos.StatusDelivered)
+	if err != nil {
+		return report, err
+	}
+	for i := range paid {
+		order := &paid[i]
+		if !order.Deposit || order.DepositAddress == "" || order.SweepHash != "" {
+			continue
+		}
+		report.Checked++
+		s.record(ctx, report, order, s.sweep)
+	}
+	return report, nil
+}
+
+// record - run the step of the order, the step returns what it did with
+// the hash of the transaction, an empty status when there is nothing to do yet.
+func (s *Service) record(ctx context.Context, report *Report, order *protos.Order, step func(ctx context.Context, order *protos.Order) (string, string, error)) {
+	change := Change{
+		OrderId: order.Id,
+		From:    order.From,
+		Address: order.DepositAddress,
+	}
+	status, hash, err := step(ctx, order)
+	if err != nil {
+		change.Error = err.Error()
+		report.Failed = append(report.Failed, change)
+		return
+	}
+	if status == "" {
+		return
+	}
+	change.Status, change.TxHash = status, hash
+	report.Changed = append(report.Changed, change)
+}
+
+// watch - the order is paid once its deposit address received the value.
+// A deposit completed after the quote expired is not accepted at the old
+// rate, the order stays created until the buyer requotes it and the
+// deposits cover the new quote.
+func (s *Service) watch(ctx context.Context, order *protos.Order) (string, string, error) {
+	c, token, err := s.token(order)
+	if err != nil {
+		return "", "", err
+	}
+	value := contract.ToWei(order.Amount, token.Decimals)
+	if order.Quote != nil {
+		if quoted, ok := new(big.Int).SetString(order.Quote.Value, 10); ok {
+			value = quoted
+		}
+	}
+	deposit, err := monitor.MonitorDeposit(ctx, c.Client, token.Address, common.HexToAddress(order.DepositAddress), order.DepositBlock, value)
+	if err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+	if !deposit.Complete {
+		return "", "", nil
+	}
+	if order.Quote != nil && order.Quote.ExpireAt > 0 {
+		head, err := c.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(deposit.BlockNumber))
+		if err != nil {
+			return "", "", errors.Join(ErrEthereum, err)
+		}
+		if order.Quote.Expired(int64(head.Time)) {
+			return "", "", errors.Join(ErrQuoteExpired,
+				fmt.Errorf("quote expired at %d, deposit %s mined at %d", order.Quote.ExpireAt, deposit.TxHash, head.Time))
+		}
+	}
+
+	order.Status = protos.StatusPaid
+	order.PaymentHash = deposit.TxHash
+	order.UpdatedAt = time.Now().Unix()
+	order.StatusCreatedAt = fmt.Sprintf("%s#%d", order.Status.String(), order.CreatedAt)
+	mask := []string{"status", "payment_hash", "updated_at", "status_created_at"}
+	if _, err := s.orders.UpdateOrder(ctx, order.From, order.Id, *order, mask); err != nil {
+		return "", "", err
+	}
+	return "paid", deposit.TxHash, nil
+}
+
+// sweep - transfer the token balance of the deposit address to the
+// treasury with the derived key. The deposit address holds no gas, so the
+// relayer of the chain funds it first and the sweep is sent by the next run
+// once the fund is mined.
+func (s *Service) sweep(ctx context.Context, order *protos.Order) (string, string, error) {
+	c, token, err := s.token(order)
+	if err != nil {
+		return "", "", err
+	}
+	child, err := s.key.Child(order.DepositIndex)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := child.PrivateKey()
+	if err != nil {
+		return "", "", err
+	}
+	address, err := child.Address()
+	if err != nil {
+		return "", "", err
+	}
+	if address != common.HexToAddress(order.DepositAddress) {
+		return "", "", errors.Join(ErrInvalidDeposit, fmt.Errorf("index %d derives %s", order.DepositIndex, address))
+	}
+
+	balance, err := token.Service.BalanceOf(ctx, address.Hex())
+	if err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+	if balance.Sign() == 0 {
+		return "", "", errors.Join(ErrInvalidDeposit, fmt.Errorf("%s has no balance", address))
+	}
+	treasury := common.HexToAddress(c.Treasuries[token.Symbol])
+	data, err := token.Service.GetABI().Pack(erc20.TRANSFER, treasury, balance)
+	if err != nil {
+		return "", "", err
+	}
+
+	gas, err := c.Client.EstimateGas(ctx, ethereum.CallMsg{From: address, To: &token.Address, Data: data})
+	if err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+	tip, err := c.Client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+	baseFee, err := chain.BaseFee(ctx, c.Client)
+	if err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(baseFee, big.NewInt(2)))
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gas), feeCap)
+	funds, err := c.Client.BalanceAt(ctx, address, nil)
+	if err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+	if funds.Cmp(cost) < 0 {
+		return s.fund(ctx, c, order, address, new(big.Int).Sub(cost, funds))
+	}
+
+	nonce, err := c.Client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+	tx, err := erc20.NewKeySigner(key).SignTx(ctx, types.NewTx(&types.DynamicFeeTx{
+		ChainID:   c.Id,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &token.Address,
+		Data:      data,
+		Value:     big.NewInt(0),
+	}), c.Id)
+	if err != nil {
+		return "", "", err
+	}
+	if err := c.Client.SendTransaction(ctx, tx); err != nil {
+		return "", "", errors.Join(ErrEthereum, err)
+	}
+
+	order.SweepHash = tx.Hash().Hex()
+	order.UpdatedAt = time.Now().Unix()
+	if _, err := s.orders.UpdateOrder(ctx, order.From, order.Id, *order, []string{"sweep_hash", "updated_at"}); err != nil {
+		return "", "", err
+	}
+	return "swept", order.SweepHash, nil
+}
+
+// fund - send the missing gas of the sweep from the relayer, nothing is sent
+// while the previous fund is not mined.
+func (s *Service) fund(ctx context.Context, c *registry.Chain, order *protos.Order, address common.Address, value *big.Int) (string, string, error) {
+	if c.Relayer == nil {
+		return "", "", errors.Join(ErrNoRelayer, fmt.Errorf("chain %s", c.Id))
+	}
+	if order.SweepFundHash != "" {
+		_, err := c.Client.TransactionReceipt(ctx, common.HexToHash(order.SweepFundHash))
+		if errors.Is(err, ethereum.NotFound) {
+			return "", "", nil
+		}
+		if err != nil {
+			return "", "", errors.Join(ErrEthereum, err)
+		}
+	}
+	txs, err := c.Relayer.Sign(ctx, relayer.Call{To: address, Gas: transferGas, Value: value})
+	if err != nil {
+		return "", "", err
+	}
+	if err := c.Relayer.Send(ctx, txs...); err != nil {
+		return "", "", err
+	}
+
+	order.SweepFundHash = txs[0].Hash().Hex()
+	order.UpdatedAt = time.Now().Unix()
+	if _, err := s.orders.UpdateOrder(ctx, order.From, order.Id, *order, []string{"sweep_fund_hash", "updated_at"}); err != nil {
+		return "", "", err
+	}
+	return "funded", order.SweepFundHash, nil
+}
+
+func (s *Service) token(order *protos.Order) (*registry.Chain, *erc20.Token, error) {
+	c, err := s.chains.Get(order.ChainId)
+	if err != nil {
+		return nil, nil, err
+	}
+	token, err := c.Tokens.Get(order.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, token, nil
+}
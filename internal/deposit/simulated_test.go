@@ -0,0 +1,168 @@
+//go:build simulated
+
+package deposit
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain/chaintest"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/hdwallet"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/relayer"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// masterPrv - the key of the deposit addresses, from the test vectors of
+// BIP-32.
+const masterPrv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+
+func TestSimulatedRun(t *testing.T) {
+	ctx := context.Background()
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	// the payer holds the tokens, the relayer funds the gas of the sweeps
+	b := chaintest.NewBackend(t, 3)
+	payer, treasury := b.Address(0), b.Address(1)
+	address := b.Deploy(t, 0, chaintest.Token{Name: "USD Coin", Symbol: "USDC", Decimals: 6, Supply: big.NewInt(1e12)})
+	token, err := contract.CreateContract("ERC20", address.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := erc20.NewERC20Service(b.Client, token, b.ChainId, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := erc20.NewRegistry()
+	if err := tokens.Register(&erc20.Token{Symbol: "USDC", Address: address, Decimals: 6, Service: service}); err != nil {
+		t.Fatal(err)
+	}
+	chains := registry.NewChains()
+	if err := chains.Register(&registry.Chain{
+		Id:         b.ChainId,
+		Client:     b.Client,
+		Tokens:     tokens,
+		Treasuries: map[string]string{"USDC": treasury.Hex()},
+		Relayer:    relayer.NewRelayer(b.Client, b.ChainId, erc20.NewKeySigner(b.Accounts[2]), relayer.Budget{}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := hdwallet.ParseExtendedKey(masterPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := b.Client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := memory.NewOrderRepository()
+	// the deposit of each order is paid by the payer
+	deposit := func(id string, index uint32, quote *protos.Quote, amount int64) {
+		t.Helper()
+		child, err := key.Child(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		to, err := child.Address()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = orders.PutOrder(ctx, protos.Order{Id: id, From: payer.Hex(), ChainId: b.ChainId.Uint64(), Token: "USDC", Amount: 5,
+			Quote: quote, Status: protos.StatusCreated, Deposit: true, DepositAddress: to.Hex(), DepositIndex: index, DepositBlock: block})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := service.GetABI().Pack(erc20.TRANSFER, to, big.NewInt(amount))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Mine(t, b.Send(t, 0, &address, nil, data))
+	}
+	deposit("found", 0, nil, 5_000_000)
+	deposit("underpaid", 1, nil, 2_000_000)
+	deposit("expired", 2, &protos.Quote{Value: "5000000", ExpireAt: 1}, 5_000_000)
+
+	get := func(id string) *protos.Order {
+		t.Helper()
+		order, err := orders.GetOrder(ctx, payer.Hex(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order
+	}
+	changes := func(report *Report) string {
+		var got []string
+		for _, change := range report.Changed {
+			got = append(got, change.OrderId+":"+change.Status)
+		}
+		return strings.Join(got, ",")
+	}
+
+	// the found deposit is paid, then the relayer funds the gas of its sweep
+	srv := NewService(orders, chains, key)
+	report, err := srv.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changes(report); got != "found:paid,found:funded" {
+		t.Fatalf("unexpected changes %s", got)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].OrderId != "expired" || !strings.Contains(report.Failed[0].Error, ErrQuoteExpired.Error()) {
+		t.Fatalf("unexpected failures %+v", report.Failed)
+	}
+	found := get("found")
+	if found.Status != protos.StatusPaid || found.PaymentHash == "" || found.SweepFundHash == "" {
+		t.Fatalf("unexpected order %+v", found)
+	}
+	if get("underpaid").Status != protos.StatusCreated || get("expired").Status != protos.StatusCreated {
+		t.Fatal("expected the underpaid and expired orders created")
+	}
+
+	// the fund is not mined, nothing is sent
+	if report, err = srv.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := changes(report); got != "" {
+		t.Fatalf("unexpected changes before the fund is mined %s", got)
+	}
+
+	// the fund is mined, the deposit is swept to the treasury
+	b.Commit()
+	if report, err = srv.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := changes(report); got != "found:swept" {
+		t.Fatalf("unexpected changes %s", got)
+	}
+	b.Commit()
+	if _, err := b.Receipt(common.HexToHash(get("found").SweepHash)); err != nil {
+		t.Fatal(err)
+	}
+	balance, err := service.BalanceOf(ctx, treasury.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(big.NewInt(5_000_000)) != 0 {
+		t.Fatalf("treasury balance is %s", balance)
+	}
+
+	// the swept order is done
+	if report, err = srv.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := changes(report); got != "" {
+		t.Fatalf("unexpected changes after the sweep %s", got)
+	}
+
+	if _, err := NewService(nil, chains, key).Run(ctx); !errors.Is(err, ErrDynamodbClientNotFound) {
+		t.Fatalf("expected the missing repository to fail, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrAuthorization error = errors.New("invalid authorization transfer")
+
+	// transferTopic - the topic of the Transfer(from, to, value) event.
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// MonitorAuthorization - an authorization can be submitted by anyone, so it
+// is matched by the AuthorizationUsed(authorizer, nonce) log instead of the
+// tx hash, then the transaction must transfer the value from the buyer to
+// the recipient. The hash of the matched transaction is returned.
+func MonitorAuthorization(ctx context.Context, client chain.Client, req *protos.CreateMonitorRequest) (protos.Status, string, error) {
+	if len(req.Topics) == 0 {
+		return protos.StatusMonitorFailed, req.TxHash, errors.Join(ErrAuthorization, fmt.Errorf("topic is empty"))
+	}
+	contract := common.HexToAddress(req.Contract)
+	query := ethereum.FilterQuery{
+		Addresses: []common.Address{contract},
+		Topics: [][]common.Hash{
+			{common.HexToHash(req.Topics[0])},
+			{common.BytesToHash(common.HexToAddress(req.From).Bytes())},
+			{common.HexToHash(req.Nonce)},
+		},
+		FromBlock: new(big.Int).SetUint64(req.FromBlock),
+	}
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		logs, err := client.FilterLogs(ctx, query)
+		if err != nil {
+			return protos.StatusMonitorFailed, req.TxHash, err
+		}
+		if len(logs) > 0 {
+			hash := logs[0].TxHash
+			receipt, err := client.TransactionReceipt(ctx, hash)
+			if err != nil {
+				return protos.StatusMonitorFailed, hash.Hex(), err
+			}
+			if err := CheckAuthorization(receipt.Logs, req); err != nil {
+				return protos.StatusPaidFailed, hash.Hex(), err
+			}
+			return protos.StatusPaid, hash.Hex(), nil
+		}
+		select {
+		case <-ctx.Done():
+			return protos.StatusMonitorFailed, req.TxHash, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAuthorization - the logs transfer the value of the request from the
+// buyer to the recipient.
+func CheckAuthorization(logs []*types.Log, req *protos.CreateMonitorRequest) error {
+	return checkTransfer(logs, req, ErrAuthorization)
+}
+
+// checkTransfer - the logs transfer the value of the request from the sender
+// to the recipient of the request.
+func checkTransfer(logs []*types.Log, req *protos.CreateMonitorRequest, errKind error) error {
+	value, ok := new(big.Int).SetString(req.Value, 10)
+	if !ok {
+		return errors.Join(errKind, fmt.Errorf("value %s is invalid", req.Value))
+	}
+	contract, from := common.HexToAddress(req.Contract), common.HexToAddress(req.From)
+	for _, vLog := range logs {
+		if vLog.Address != contract || len(vLog.Topics) != 3 || vLog.Topics[0] != transferTopic {
+			continue
+		}
+		if common.BytesToAddress(vLog.Topics[1].Bytes()) != from {
+			continue
+		}
+		if !IsRecipient(*vLog, req.To) {
+			return errors.Join(errKind, fmt.Errorf("transfer is not sent to %s", req.To))
+		}
+		if new(big.Int).SetBytes(vLog.Data).Cmp(value) != 0 {
+			return errors.Join(errKind, fmt.Errorf("value %s is not %s", new(big.Int).SetBytes(vLog.Data), value))
+		}
+		return nil
+	}
+	return errors.Join(errKind, fmt.Errorf("transfer log not found"))
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var ErrDeposit error = errors.New("invalid deposit")
+
+// Deposit - the transfers of a token received by a deposit address, the
+// hash and the block are the transfer which completes the value.
+type Deposit struct {
+	Received    *big.Int
+	TxHash      string
+	BlockNumber uint64
+	Complete    bool
+}
+
+// DepositPage - the blocks of one log query of the deposits, the rpc
+// providers limit the block range of eth_getLogs.
+var DepositPage uint64 = 2000
+
+// MonitorDeposit - a deposit address belongs to one order, so every
+// Transfer log to it since the block of the order pays the order, whoever
+// sends it. The deposit is complete once the received value reaches the
+// value. The blocks up to the latest one are queried by page.
+func MonitorDeposit(ctx context.Context, client chain.Client, token, to common.Address, fromBlock uint64, value *big.Int) (*Deposit, error) {
+	latest, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, errors.Join(ErrDeposit, err)
+	}
+	deposit := &Deposit{Received: big.NewInt(0)}
+	for from := fromBlock; from <= latest; from += DepositPage {
+		toBlock := min(from+DepositPage-1, latest)
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			Addresses: []common.Address{token},
+			Topics: [][]common.Hash{
+				{transferTopic},
+				{},
+				{common.BytesToHash(to.Bytes())},
+			},
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(toBlock),
+		})
+		if err != nil {
+			return nil, errors.Join(ErrDeposit, err)
+		}
+		for _, vLog := range logs {
+			if vLog.Removed || vLog.Address != token || len(vLog.Topics) != 3 || vLog.Topics[0] != transferTopic {
+				continue
+			}
+			if common.BytesToAddress(vLog.Topics[2].Bytes()) != to {
+				continue
+			}
+			deposit.Received.Add(deposit.Received, new(big.Int).SetBytes(vLog.Data))
+			if !deposit.Complete && deposit.Received.Cmp(value) >= 0 {
+				deposit.TxHash, deposit.BlockNumber, deposit.Complete = vLog.TxHash.Hex(), vLog.BlockNumber, true
+			}
+		}
+	}
+	return deposit, nil
+}
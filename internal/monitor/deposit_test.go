@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// logClient - a chain of the logs up to the latest block, the ranges of
+// the queries are recorded.
+type logClient struct {
+	chain.Client
+	logs    []types.Log
+	latest  uint64
+	queries [][2]uint64
+}
+
+func (c *logClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.latest, nil
+}
+
+func (c *logClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	c.queries = append(c.queries, [2]uint64{from, to})
+	var logs []types.Log
+	for _, vLog := range c.logs {
+		if vLog.BlockNumber >= from && vLog.BlockNumber <= to {
+			logs = append(logs, vLog)
+		}
+	}
+	return logs, nil
+}
+
+func transferLog(token, from, to common.Address, value int64, hash string) types.Log {
+	return types.Log{
+		Address: token,
+		Topics: []common.Hash{
+			transferTopic,
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data:        common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+		TxHash:      common.HexToHash(hash),
+		BlockNumber: new(big.Int).SetBytes(common.FromHex(hash)).Uint64(),
+	}
+}
+
+func TestMonitorDeposit(t *testing.T) {
+	token := common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	exchange := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	deposit := common.HexToAddress("0x6Fb2A9a5B1d64B79F45aA1D8f3A8dC5d0e1b6d2C")
+	other := common.HexToAddress("0x3e622317f8C93f7328350cF0B56d9eD4C620C5d6")
+
+	removed := transferLog(token, exchange, deposit, 500, "0x04")
+	removed.Removed = true
+	client := &logClient{latest: 5, logs: []types.Log{
+		transferLog(token, exchange, deposit, 600, "0x01"),
+		transferLog(other, exchange, deposit, 500, "0x02"),
+		removed,
+		transferLog(token, exchange, deposit, 500, "0x03"),
+	}}
+	got, err := MonitorDeposit(context.Background(), client, token, deposit, 1, big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Complete || got.Received.Cmp(big.NewInt(1100)) != 0 || got.TxHash != common.HexToHash("0x03").Hex() || got.BlockNumber != 3 {
+		t.Fatalf("unexpected deposit %+v", got)
+	}
+
+	got, err = MonitorDeposit(context.Background(), client, token, deposit, 1, big.NewInt(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Complete || got.TxHash != "" {
+		t.Fatalf("deposit should not be complete %+v", got)
+	}
+}
+
+func TestMonitorDepositPages(t *testing.T) {
+	page := DepositPage
+	DepositPage = 2
+	defer func() { DepositPage = page }()
+
+	token := common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	exchange := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	deposit := common.HexToAddress("0x6Fb2A9a5B1d64B79F45aA1D8f3A8dC5d0e1b6d2C")
+	client := &logClient{latest: 6, logs: []types.Log{
+		transferLog(token, exchange, deposit, 400, "0x02"),
+		transferLog(token, exchange, deposit, 600, "0x05"),
+	}}
+	got, err := MonitorDeposit(context.Background(), client, token, deposit, 2, big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Complete || got.BlockNumber != 5 {
+		t.Fatalf("unexpected deposit %+v", got)
+	}
+	if want := [][2]uint64{{2, 3}, {4, 5}, {6, 6}}; !reflect.DeepEqual(client.queries, want) {
+		t.Fatalf("queried %v, not %v", client.queries, want)
+	}
+}
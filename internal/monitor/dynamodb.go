@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -15,6 +16,8 @@ import (
 
 const (
 	PkExists string = "attribute_exists(pk)"
+	// Version - the version of the order, every write adds one
+	Version string = "version"
 )
 
 var (
@@ -22,7 +25,10 @@ var (
 	ErrUpdate     error = errors.New("update error")
 )
 
-// UpdateOrder - update order.
+// UpdateTransStatus - settle the payment of the order. The order must still
+// be pending, a monitor_failed order may also be settled as paid or
+// paid_failed. A request is delivered at least once, so an order which is
+// already settled by another run is left as it is and no error is returned.
 // Pk: USER#<public address>
 // Sk: ORDER#<order_id>
 func UpdateTransStatus(ctx context.Context, client *dynamodb.Client, data *protos.UpdateTrans) error {
@@ -41,7 +47,13 @@ func UpdateTransStatus(ctx context.Context, client *dynamodb.Client, data *proto
 	update.Set(expression.Name("updated_at"), expression.Value(now))
 	update.Set(expression.Name("status_created_at"),
 		expression.Value(fmt.Sprintf("%s#%d", data.Status.String(), now)))
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	update.Add(expression.Name(Version), expression.Value(1))
+	condition := expression.Name("status").Equal(expression.Value(protos.StatusPending))
+	if data.Status != protos.StatusMonitorFailed {
+		condition = expression.Name("status").In(
+			expression.Value(protos.StatusPending), expression.Value(protos.StatusMonitorFailed))
+	}
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
 	if err != nil {
 		return errors.Join(ErrExpression, err)
 	}
@@ -53,7 +65,86 @@ func UpdateTransStatus(ctx context.Context, client *dynamodb.Client, data *proto
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
 		ReturnValues:              types.ReturnValueNone,
-		ConditionExpression:       aws.String(PkExists),
+		ConditionExpression:       expr.Condition(),
+	})
+	if storage.IsConditionalCheckFailed(err) {
+		// the order is settled or not found
+		return nil
+	}
+	if err != nil {
+		return errors.Join(ErrUpdate, err)
+	}
+	return nil
+}
+
+// UpdateRefundStatus - settle the refund and its order in one transaction.
+// A confirmed refund adds its amount to the refunded amount of the order, a
+// failed one restores the status of the order. The order must still be
+// refund_pending, so a redelivered request is not counted twice. The hash of
+// the request is the mined refund, it replaced the sent one when they differ.
+// Order Pk: USER#<public address>
+// Order Sk: ORDER#<order_id>
+// Refund Pk: USER#<public address>
+// Refund Sk: REFUND#<order_id>#<refund_id>
+func UpdateRefundStatus(ctx context.Context, client *dynamodb.Client, req *protos.CreateMonitorRequest, buyer string, status protos.RefundStatus) error {
+	now := time.Now().Unix()
+	orderStatus := req.Refund.Previous
+	if status == protos.RefundConfirmed {
+		orderStatus = req.Refund.Status
+	}
+	orderUpdate := expression.Set(expression.Name("status"), expression.Value(orderStatus))
+	orderUpdate.Set(expression.Name("updated_at"), expression.Value(now))
+	orderUpdate.Set(expression.Name("status_created_at"),
+		expression.Value(fmt.Sprintf("%s#%d", orderStatus.String(), now)))
+	orderUpdate.Add(expression.Name(Version), expression.Value(1))
+	if status == protos.RefundConfirmed {
+		orderUpdate.Add(expression.Name("refunded"), expression.Value(req.Refund.Amount))
+	}
+	orderExpr, err := expression.NewBuilder().
+		WithUpdate(orderUpdate).
+		WithCondition(expression.Name("status").Equal(expression.Value(protos.StatusRefundPending))).
+		Build()
+	if err != nil {
+		return errors.Join(ErrExpression, err)
+	}
+
+	refundUpdate := expression.Set(expression.Name("status"), expression.Value(status))
+	refundUpdate.Set(expression.Name("tx_hash"), expression.Value(req.TxHash))
+	refundUpdate.Set(expression.Name("updated_at"), expression.Value(now))
+	refundExpr, err := expression.NewBuilder().WithUpdate(refundUpdate).Build()
+	if err != nil {
+		return errors.Join(ErrExpression, err)
+	}
+
+	_, err = client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName: aws.String(req.Table),
+					Key: map[string]types.AttributeValue{
+						"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", buyer)},
+						"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", req.OrderId)},
+					},
+					ExpressionAttributeNames:  orderExpr.Names(),
+					ExpressionAttributeValues: orderExpr.Values(),
+					UpdateExpression:          orderExpr.Update(),
+					ConditionExpression:       orderExpr.Condition(),
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName: aws.String(req.Table),
+					Key: map[string]types.AttributeValue{
+						"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", buyer)},
+						"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("REFUND#%s#%s", req.OrderId, req.Refund.Id)},
+					},
+					ExpressionAttributeNames:  refundExpr.Names(),
+					ExpressionAttributeValues: refundExpr.Values(),
+					UpdateExpression:          refundExpr.Update(),
+					ConditionExpression:       aws.String(PkExists),
+				},
+			},
+		},
 	})
 	if err != nil {
 		return errors.Join(ErrUpdate, err)
@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	PollInterval = time.Second * 2
+
+	ErrNativeTransfer error = errors.New("invalid native transfer")
+)
+
+// MonitorNative - native transfers have no event, poll the receipt of the
+// transaction until it is mined, then check it sends the value from the
+// buyer to the recipient. A transaction replacing it by the nonce is checked
+// the same way and its hash is returned. StatusMonitorFailed is returned
+// when the context is done or the client fails.
+func MonitorNative(ctx context.Context, client chain.Client, req *protos.CreateMonitorRequest) (protos.Status, string, error) {
+	hash := common.HexToHash(req.TxHash)
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return protos.StatusPaidFailed, req.TxHash, errors.Join(ErrNativeTransfer, fmt.Errorf("transaction reverted"))
+			}
+			break
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return protos.StatusMonitorFailed, req.TxHash, err
+		}
+		tx, receipt, err := Replacement(ctx, client, req)
+		if err != nil {
+			return protos.StatusMonitorFailed, req.TxHash, err
+		}
+		if tx != nil {
+			if err := CheckReplacement(tx, receipt, req); err != nil {
+				return protos.StatusPaidFailed, tx.Hash().Hex(), err
+			}
+			return protos.StatusPaid, tx.Hash().Hex(), nil
+		}
+		select {
+		case <-ctx.Done():
+			return protos.StatusMonitorFailed, req.TxHash, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	tx, _, err := client.TransactionByHash(ctx, hash)
+	if err != nil {
+		return protos.StatusMonitorFailed, req.TxHash, err
+	}
+	if err := CheckNative(tx, req); err != nil {
+		return protos.StatusPaidFailed, req.TxHash, err
+	}
+	return protos.StatusPaid, req.TxHash, nil
+}
+
+// CheckNative - the transaction sends the value of the request from the
+// buyer to the recipient.
+func CheckNative(tx *types.Transaction, req *protos.CreateMonitorRequest) error {
+	sender, err := types.LatestSignerForChainID(tx.ChainId()).Sender(tx)
+	if err != nil {
+		return errors.Join(ErrNativeTransfer, err)
+	}
+	if sender != common.HexToAddress(req.From) {
+		return errors.Join(ErrNativeTransfer, fmt.Errorf("sender %s is not %s", sender.Hex(), req.From))
+	}
+	if !common.IsHexAddress(req.To) || tx.To() == nil || *tx.To() != common.HexToAddress(req.To) {
+		return errors.Join(ErrNativeTransfer, fmt.Errorf("transfer is not sent to %s", req.To))
+	}
+	value, ok := new(big.Int).SetString(req.Value, 10)
+	if !ok || tx.Value().Cmp(value) != 0 {
+		return errors.Join(ErrNativeTransfer, fmt.Errorf("value %s is not %s", tx.Value(), req.Value))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var ErrRefund error = errors.New("invalid refund")
+
+// MonitorRefund - a refund is sent by the treasury, poll its receipt until
+// it is mined, then check it transfers the value from the treasury to the
+// buyer. A transaction replacing it by the nonce, a refund with bumped fees,
+// is checked the same way and its hash is returned. RefundUnknow is returned
+// when the context is done or the client fails, the refund is still pending
+// then.
+func MonitorRefund(ctx context.Context, client chain.Client, req *protos.CreateMonitorRequest) (protos.RefundStatus, string, error) {
+	hash := common.HexToHash(req.TxHash)
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return protos.RefundFailed, req.TxHash, errors.Join(ErrRefund, fmt.Errorf("transaction reverted"))
+			}
+			if req.Native {
+				break
+			}
+			if err := CheckRefund(receipt.Logs, req); err != nil {
+				return protos.RefundFailed, req.TxHash, err
+			}
+			return protos.RefundConfirmed, req.TxHash, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return protos.RefundUnknow, req.TxHash, err
+		}
+		tx, receipt, err := Replacement(ctx, client, req)
+		if err != nil {
+			return protos.RefundUnknow, req.TxHash, err
+		}
+		if tx != nil {
+			if err := CheckReplacement(tx, receipt, req); err != nil {
+				return protos.RefundFailed, tx.Hash().Hex(), errors.Join(ErrRefund, err)
+			}
+			return protos.RefundConfirmed, tx.Hash().Hex(), nil
+		}
+		select {
+		case <-ctx.Done():
+			return protos.RefundUnknow, req.TxHash, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	tx, _, err := client.TransactionByHash(ctx, hash)
+	if err != nil {
+		return protos.RefundUnknow, req.TxHash, err
+	}
+	if err := CheckNative(tx, req); err != nil {
+		return protos.RefundFailed, req.TxHash, errors.Join(ErrRefund, err)
+	}
+	return protos.RefundConfirmed, req.TxHash, nil
+}
+
+// CheckRefund - the logs transfer the value of the request from the
+// treasury to the buyer.
+func CheckRefund(logs []*types.Log, req *protos.CreateMonitorRequest) error {
+	return checkTransfer(logs, req, ErrRefund)
+}
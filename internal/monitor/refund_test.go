@@ -0,0 +1,39 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCheckRefund(t *testing.T) {
+	token := common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	treasury := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	buyer := common.HexToAddress("0x3e622317f8C93f7328350cF0B56d9eD4C620C5d6")
+	other := common.HexToAddress("0x6Fb2A9a5B1d64B79F45aA1D8f3A8dC5d0e1b6d2C")
+	req := &protos.CreateMonitorRequest{
+		Contract: token.Hex(),
+		From:     treasury.Hex(),
+		To:       buyer.Hex(),
+		Value:    "500",
+	}
+
+	refund := transferLog(token, treasury, buyer, 500, "0x01")
+	if err := CheckRefund([]*types.Log{&refund}, req); err != nil {
+		t.Fatal(err)
+	}
+	partial := transferLog(token, treasury, buyer, 400, "0x01")
+	if err := CheckRefund([]*types.Log{&partial}, req); !errors.Is(err, ErrRefund) {
+		t.Fatalf("wrong value should fail, got %v", err)
+	}
+	stray := transferLog(token, treasury, other, 500, "0x01")
+	if err := CheckRefund([]*types.Log{&stray}, req); !errors.Is(err, ErrRefund) {
+		t.Fatalf("wrong recipient should fail, got %v", err)
+	}
+	if err := CheckRefund(nil, req); !errors.Is(err, ErrRefund) {
+		t.Fatalf("missing log should fail, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var ErrReplaced error = errors.New("transaction replaced")
+
+// FindReplacement - the transaction mined with the nonce of the sender in
+// place of the transaction of the hash. A wallet speeding up or cancelling
+// a transaction sends another one with the same nonce. nil is returned
+// while the nonce is not mined, or when the transaction itself is mined.
+func FindReplacement(ctx context.Context, client chain.Client, sender common.Address, nonce uint64, hash common.Hash, fromBlock uint64) (*types.Transaction, *types.Receipt, error) {
+	latest, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	mined, err := client.NonceAt(ctx, sender, new(big.Int).SetUint64(latest))
+	if err != nil {
+		return nil, nil, err
+	}
+	if mined <= nonce {
+		return nil, nil, nil
+	}
+
+	// the first block after which the nonce of the sender is past the nonce
+	low, high := fromBlock, latest
+	for low < high {
+		mid := low + (high-low)/2
+		count, err := client.NonceAt(ctx, sender, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return nil, nil, err
+		}
+		if count > nonce {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(low))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tx := range block.Transactions() {
+		if tx.Nonce() != nonce {
+			continue
+		}
+		from, err := types.LatestSignerForChainID(tx.ChainId()).Sender(tx)
+		if err != nil || from != sender {
+			continue
+		}
+		if tx.Hash() == hash {
+			return nil, nil, nil
+		}
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return nil, nil, err
+		}
+		return tx, receipt, nil
+	}
+	return nil, nil, fmt.Errorf("nonce %d of %s not found in block %d", nonce, sender.Hex(), low)
+}
+
+// CheckReplacement - the replacing transaction is adopted when it still
+// pays the request, a cancelled or changed one is not.
+func CheckReplacement(tx *types.Transaction, receipt *types.Receipt, req *protos.CreateMonitorRequest) error {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return errors.Join(ErrReplaced, fmt.Errorf("replacement %s reverted", tx.Hash().Hex()))
+	}
+	if req.Native {
+		if err := CheckNative(tx, req); err != nil {
+			return errors.Join(ErrReplaced, err)
+		}
+		return nil
+	}
+	return checkTransfer(receipt.Logs, req, ErrReplaced)
+}
+
+// Replacement - the replacement of the transaction of the request, the
+// requests without the sender nonce are never replaced.
+func Replacement(ctx context.Context, client chain.Client, req *protos.CreateMonitorRequest) (*types.Transaction, *types.Receipt, error) {
+	if req.SenderNonce == nil || !common.IsHexAddress(req.Sender) {
+		return nil, nil, nil
+	}
+	return FindReplacement(ctx, client, common.HexToAddress(req.Sender), *req.SenderNonce, common.HexToHash(req.TxHash), req.FromBlock)
+}
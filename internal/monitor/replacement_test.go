@@ -0,0 +1,138 @@
+package monitor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// blockClient - a chain of the blocks by number, the nonce of an address is
+// past its last transaction up to the block.
+type blockClient struct {
+	chain.Client
+	blocks   map[uint64][]*types.Transaction
+	latest   uint64
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (c *blockClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.latest, nil
+}
+
+func (c *blockClient) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	var nonce uint64
+	for n, txs := range c.blocks {
+		if n > number.Uint64() {
+			continue
+		}
+		for _, tx := range txs {
+			if from, _ := types.LatestSignerForChainID(tx.ChainId()).Sender(tx); from == account && tx.Nonce() >= nonce {
+				nonce = tx.Nonce() + 1
+			}
+		}
+	}
+	return nonce, nil
+}
+
+func (c *blockClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return types.NewBlockWithHeader(&types.Header{Number: number}).WithBody(c.blocks[number.Uint64()], nil), nil
+}
+
+func (c *blockClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	return c.receipts[hash], nil
+}
+
+func signedTransfer(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, to common.Address, value int64, tip int64) *types.Transaction {
+	t.Helper()
+	chainId := big.NewInt(1337)
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainId,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(tip),
+		GasFeeCap: big.NewInt(3e9),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(value),
+	}), types.NewLondonSigner(chainId), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestFindReplacement(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyer := crypto.PubkeyToAddress(key.PublicKey)
+	treasury := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	req := &protos.CreateMonitorRequest{From: buyer.Hex(), To: treasury.Hex(), Native: true, Value: "500"}
+	ctx := context.Background()
+
+	sent := signedTransfer(t, key, 4, treasury, 500, 1e9)
+	client := &blockClient{
+		blocks: map[uint64][]*types.Transaction{
+			100: {signedTransfer(t, key, 3, treasury, 1, 1e9)},
+		},
+		latest:   110,
+		receipts: make(map[common.Hash]*types.Receipt),
+	}
+	if tx, _, err := FindReplacement(ctx, client, buyer, 4, sent.Hash(), 98); err != nil || tx != nil {
+		t.Fatalf("unmined nonce should not be replaced, got %v %v", tx, err)
+	}
+
+	// the wallet sped up the payment
+	speedUp := signedTransfer(t, key, 4, treasury, 500, 2e9)
+	client.blocks[105] = []*types.Transaction{speedUp}
+	client.receipts[speedUp.Hash()] = &types.Receipt{Status: types.ReceiptStatusSuccessful}
+	tx, receipt, err := FindReplacement(ctx, client, buyer, 4, sent.Hash(), 98)
+	if err != nil || tx == nil || tx.Hash() != speedUp.Hash() {
+		t.Fatalf("expected the speed up, got %v %v", tx, err)
+	}
+	if err := CheckReplacement(tx, receipt, req); err != nil {
+		t.Fatal(err)
+	}
+	if tx, _, err := FindReplacement(ctx, client, buyer, 4, speedUp.Hash(), 98); err != nil || tx != nil {
+		t.Fatalf("the mined transaction is not replaced, got %v %v", tx, err)
+	}
+
+	// the wallet cancelled the payment with a transfer of nothing to itself
+	cancel := signedTransfer(t, key, 4, buyer, 0, 2e9)
+	client.blocks[105] = []*types.Transaction{cancel}
+	client.receipts[cancel.Hash()] = &types.Receipt{Status: types.ReceiptStatusSuccessful}
+	tx, receipt, err = FindReplacement(ctx, client, buyer, 4, sent.Hash(), 98)
+	if err != nil || tx == nil || tx.Hash() != cancel.Hash() {
+		t.Fatalf("expected the cancel, got %v %v", tx, err)
+	}
+	if err := CheckReplacement(tx, receipt, req); !errors.Is(err, ErrReplaced) {
+		t.Fatalf("cancel should fail, got %v", err)
+	}
+}
+
+func TestCheckReplacementTransfer(t *testing.T) {
+	token := common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	buyer := common.HexToAddress("0x3e622317f8C93f7328350cF0B56d9eD4C620C5d6")
+	treasury := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	req := &protos.CreateMonitorRequest{Contract: token.Hex(), From: buyer.Hex(), To: treasury.Hex(), Value: "500"}
+	tx := types.NewTx(&types.DynamicFeeTx{To: &token})
+
+	paid := transferLog(token, buyer, treasury, 500, "0x02")
+	if err := CheckReplacement(tx, &types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{&paid}}, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckReplacement(tx, &types.Receipt{Status: types.ReceiptStatusFailed, Logs: []*types.Log{&paid}}, req); !errors.Is(err, ErrReplaced) {
+		t.Fatalf("reverted replacement should fail, got %v", err)
+	}
+	if err := CheckReplacement(tx, &types.Receipt{Status: types.ReceiptStatusSuccessful}, req); !errors.Is(err, ErrReplaced) {
+		t.Fatalf("replacement without the transfer should fail, got %v", err)
+	}
+}
@@ -5,14 +5,22 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
-func Monitor(client *ethclient.Client, req *protos.CreateMonitorRequest) (<-chan types.Log, func(), <-chan error) {
+// IsRecipient - the Transfer(from, to, value) log is sent to the address.
+func IsRecipient(vLog types.Log, to string) bool {
+	if len(vLog.Topics) != 3 || !common.IsHexAddress(to) {
+		return false
+	}
+	return common.BytesToAddress(vLog.Topics[2].Bytes()) == common.HexToAddress(to)
+}
+
+func Monitor(client chain.Client, req *protos.CreateMonitorRequest) (<-chan types.Log, func(), <-chan error) {
 	contract := common.HexToAddress(req.Contract)
 	topics := make([][]common.Hash, 1)
 	topic := make([]common.Hash, len(req.Topics))
@@ -0,0 +1,116 @@
+//go:build simulated
+
+package monitor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain/chaintest"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20/binding"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSimulatedMonitor(t *testing.T) {
+	b := chaintest.NewBackend(t, 2)
+	token := b.Deploy(t, 0, chaintest.Token{Name: "USD Coin", Symbol: "USDC", Decimals: 6, Supply: big.NewInt(1e12)})
+	tokenABI, err := binding.ERC20MetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyer, treasury := b.Address(0), b.Address(1)
+	block, err := b.Client.BlockNumber(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := tokenABI.Pack("transfer", treasury, big.NewInt(5_000_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := b.Send(t, 0, &token, nil, data)
+	req := &protos.CreateMonitorRequest{
+		Contract:  token.Hex(),
+		Topics:    []string{tokenABI.Events["Transfer"].ID.Hex()},
+		From:      buyer.Hex(),
+		To:        treasury.Hex(),
+		Value:     "5000000",
+		FromBlock: block,
+		TxHash:    tx.Hash().Hex(),
+	}
+	logs, _, errChan := Monitor(b.Client, req)
+	b.Mine(t, tx)
+
+	select {
+	case vLog := <-logs:
+		if !IsRecipient(vLog, req.To) {
+			t.Fatalf("transfer is not sent to %s", req.To)
+		}
+		if vLog.TxHash != tx.Hash() || new(big.Int).SetBytes(vLog.Data).Cmp(big.NewInt(5_000_000)) != 0 {
+			t.Fatalf("unexpected log %+v", vLog)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("transfer log not received")
+	}
+}
+
+func TestSimulatedMonitorNative(t *testing.T) {
+	PollInterval = 50 * time.Millisecond
+	b := chaintest.NewBackend(t, 2)
+	buyer, treasury := b.Address(0), b.Address(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx := b.Send(t, 0, &treasury, big.NewInt(1e15), nil)
+	req := &protos.CreateMonitorRequest{
+		Native: true,
+		From:   buyer.Hex(),
+		To:     treasury.Hex(),
+		Value:  "1000000000000000",
+		TxHash: tx.Hash().Hex(),
+	}
+	b.Mine(t, tx)
+	status, hash, err := MonitorNative(ctx, b.Client, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != protos.StatusPaid || hash != req.TxHash {
+		t.Fatalf("unexpected status %v of %s", status, hash)
+	}
+
+	// the wallet cancels the payment with a transaction to itself before it
+	// is mined
+	block, err := b.Client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx = b.Send(t, 0, &treasury, big.NewInt(1e15), nil)
+	cancelTx, err := types.SignNewTx(b.Accounts[0], types.LatestSignerForChainID(b.ChainId), &types.DynamicFeeTx{
+		ChainID:   b.ChainId,
+		Nonce:     tx.Nonce(),
+		GasTipCap: new(big.Int).Mul(tx.GasTipCap(), big.NewInt(2)),
+		GasFeeCap: new(big.Int).Mul(tx.GasFeeCap(), big.NewInt(2)),
+		Gas:       tx.Gas(),
+		To:        &buyer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Client.SendTransaction(ctx, cancelTx); err != nil {
+		t.Fatal(err)
+	}
+	nonce := tx.Nonce()
+	req.TxHash, req.FromBlock = tx.Hash().Hex(), block
+	req.Sender, req.SenderNonce = buyer.Hex(), &nonce
+	b.Mine(t, cancelTx)
+
+	status, hash, err = MonitorNative(ctx, b.Client, req)
+	if status != protos.StatusPaidFailed || hash != cancelTx.Hash().Hex() {
+		t.Fatalf("unexpected status %v of %s, %v", status, hash, err)
+	}
+}
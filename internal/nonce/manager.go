@@ -0,0 +1,430 @@
+package nonce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	ErrDynamodbClientNotFound = errors.New("dynamodb client not found")
+	ErrEthereum               = errors.New("ethereum operation failed")
+	ErrNonce                  = errors.New("invalid nonce")
+)
+
+const (
+	// DefaultStuckAfter - a transaction pending longer is stuck, a nonce
+	// reserved longer without being sent is a gap.
+	DefaultStuckAfter = 5 * time.Minute
+	// bumpPercent - the pool only accepts a replacement raising both fees by 10%
+	bumpPercent = 10
+	// fillGas - the gas of the transfer to itself filling a gap
+	fillGas uint64 = 21000
+)
+
+// Manager - hand out the nonces of a merchant address in order. The next
+// nonce and the transactions of the nonces are kept in the repository, so
+// every instance sending with the address shares them.
+type Manager struct {
+	nonces     storage.NonceRepository
+	client     chain.Client
+	chainId    *big.Int
+	signer     erc20.Signer
+	stuckAfter time.Duration
+}
+
+func NewManager(nonces storage.NonceRepository, client chain.Client, chainId *big.Int, signer erc20.Signer, stuckAfter time.Duration) *Manager {
+	if stuckAfter <= 0 {
+		stuckAfter = DefaultStuckAfter
+	}
+	return &Manager{
+		nonces:     nonces,
+		client:     client,
+		chainId:    chainId,
+		signer:     signer,
+		stuckAfter: stuckAfter,
+	}
+}
+
+// Address - the address of the nonces.
+func (m *Manager) Address() common.Address {
+	return m.signer.Address()
+}
+
+// Reserve - hand out the count consecutive nonces and return the first one.
+// The nonces of a new address start at its pending nonce on the chain.
+func (m *Manager) Reserve(ctx context.Context, count uint64) (uint64, error) {
+	if m.nonces == nil {
+		return 0, ErrDynamodbClientNotFound
+	}
+	chainId, address := m.chainId.Uint64(), m.Address().Hex()
+	for {
+		next, err := m.nonces.GetNextNonce(ctx, chainId, address)
+		if errors.Is(err, storage.ErrNotFound) {
+			pending, err := m.client.PendingNonceAt(ctx, m.Address())
+			if err != nil {
+				return 0, errors.Join(ErrEthereum, err)
+			}
+			// another instance may start the nonces first, both read them again
+			if err := m.nonces.InitNonce(ctx, chainId, address, pending); err != nil && !storage.IsConditionalCheckFailed(err) {
+				return 0, err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		err = m.nonces.ReserveNonces(ctx, chainId, address, next, count, time.Now().Unix())
+		if err == nil {
+			return next, nil
+		}
+		if !storage.IsConditionalCheckFailed(err) {
+			return 0, err
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// Sign - sign the transaction with the next nonce, the nonce and the chain
+// of the transaction are replaced.
+func (m *Manager) Sign(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	nonce, err := m.Reserve(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	record := protos.NonceTx{
+		ChainId: m.chainId.Uint64(),
+		Address: m.Address().Hex(),
+		Nonce:   nonce,
+		Status:  protos.NonceReserved,
+	}
+	signTx, err := m.signer.SignTx(ctx, m.withNonce(tx, nonce, tx.GasTipCap(), tx.GasFeeCap()), m.chainId)
+	if err != nil {
+		record.Status, record.UpdatedAt = protos.NonceFailed, time.Now().Unix()
+		return nil, errors.Join(err, m.nonces.UpdateNonceTx(ctx, record, []string{"status", "updated_at"}))
+	}
+	if err := m.record(ctx, &record, signTx); err != nil {
+		return nil, err
+	}
+	return signTx, nil
+}
+
+// Send - broadcast the signed transaction and mark it pending. The nonce of
+// a transaction which the node rejects is a gap, it is filled by Fill. Any
+// other failure, e.g. a timeout, may follow the broadcast, so the
+// transaction stays pending and is bumped by Bump once it is stuck.
+func (m *Manager) Send(ctx context.Context, tx *types.Transaction) error {
+	record := protos.NonceTx{
+		ChainId:   m.chainId.Uint64(),
+		Address:   m.Address().Hex(),
+		Nonce:     tx.Nonce(),
+		Status:    protos.NoncePending,
+		UpdatedAt: time.Now().Unix(),
+	}
+	sendErr := m.client.SendTransaction(ctx, tx)
+	if sendErr != nil {
+		if chain.IsRejected(sendErr) {
+			record.Status = protos.NonceFailed
+		}
+		sendErr = errors.Join(ErrEthereum, sendErr)
+	}
+	return errors.Join(sendErr, m.nonces.UpdateNonceTx(ctx, record, []string{"status", "updated_at"}))
+}
+
+// Abandon - mark the nonce of a signed transaction which is never sent
+// failed, it is a gap filled by Fill.
+func (m *Manager) Abandon(ctx context.Context, tx *types.Transaction) error {
+	return m.nonces.UpdateNonceTx(ctx, protos.NonceTx{
+		ChainId:   m.chainId.Uint64(),
+		Address:   m.Address().Hex(),
+		Nonce:     tx.Nonce(),
+		Status:    protos.NonceFailed,
+		UpdatedAt: time.Now().Unix(),
+	}, []string{"status", "updated_at"})
+}
+
+// Report - the nonces of an address compared with the chain. The mined and
+// the pending are the nonces of the chain, the next is the next nonce handed
+// out. The gaps are the nonces the pool is missing and the stuck are the
+// transactions pending longer than the stuck time.
+type Report struct {
+	ChainId uint64   `json:"chain_id"`
+	Address string   `json:"address"`
+	Mined   uint64   `json:"mined"`
+	Pending uint64   `json:"pending"`
+	Next    uint64   `json:"next"`
+	Settled int      `json:"settled"`
+	Gaps    []uint64 `json:"gaps"`
+	Stuck   []uint64 `json:"stuck"`
+}
+
+// Check - mark the transactions below the mined nonce mined, then report the
+// gaps and the stuck transactions. The next nonce follows the chain when the
+// address sent transactions without the manager.
+func (m *Manager) Check(ctx context.Context) (*Report, error) {
+	if m.nonces == nil {
+		return nil, ErrDynamodbClientNotFound
+	}
+	chainId, address := m.chainId.Uint64(), m.Address().Hex()
+	report := &Report{ChainId: chainId, Address: address, Gaps: []uint64{}, Stuck: []uint64{}}
+	var err error
+	if report.Mined, err = m.client.NonceAt(ctx, m.Address(), nil); err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	if report.Pending, err = m.client.PendingNonceAt(ctx, m.Address()); err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	report.Next, err = m.nonces.GetNextNonce(ctx, chainId, address)
+	if errors.Is(err, storage.ErrNotFound) {
+		report.Next = report.Pending
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report.Pending > report.Next {
+		if err := m.nonces.AdvanceNonce(ctx, chainId, address, report.Pending); err != nil {
+			return nil, err
+		}
+		report.Next = report.Pending
+	}
+
+	txs, err := m.nonces.GetUnminedNonceTxs(ctx, chainId, address)
+	if err != nil {
+		return nil, err
+	}
+	unmined := make([]protos.NonceTx, 0, len(txs))
+	for _, tx := range txs {
+		if tx.Nonce >= report.Mined {
+			unmined = append(unmined, tx)
+			continue
+		}
+		if err := m.settle(ctx, tx); err != nil {
+			return nil, err
+		}
+		report.Settled++
+	}
+	report.Gaps, report.Stuck = inspect(report.Mined, report.Pending, report.Next, unmined, time.Now().Unix(), int64(m.stuckAfter.Seconds()))
+	return report, nil
+}
+
+// inspect - the nonces from the mined one are in the pool up to the pending
+// one, the missing ones after it are gaps. A nonce without a transaction, a
+// failed one or one reserved longer than the stuck time is a gap, and a
+// transaction pending longer than the stuck time is stuck.
+func inspect(mined, pending, next uint64, txs []protos.NonceTx, now, stuckAfter int64) ([]uint64, []uint64) {
+	byNonce := make(map[uint64]protos.NonceTx, len(txs))
+	for _, tx := range txs {
+		byNonce[tx.Nonce] = tx
+	}
+	gaps, stuck := []uint64{}, []uint64{}
+	for nonce := mined; nonce < next; nonce++ {
+		tx, ok := byNonce[nonce]
+		old := ok && now-tx.UpdatedAt > stuckAfter
+		switch {
+		case ok && tx.Status == protos.NoncePending && old:
+			stuck = append(stuck, nonce)
+		case nonce < pending:
+			// the pool holds a transaction of the nonce
+		case !ok, tx.Status == protos.NonceFailed, tx.Status == protos.NonceReserved && old:
+			gaps = append(gaps, nonce)
+		}
+	}
+	return gaps, stuck
+}
+
+// settle - mark the transaction of a mined nonce mined, the hash is the
+// mined one of the transaction and its replacements.
+func (m *Manager) settle(ctx context.Context, tx protos.NonceTx) error {
+	for _, hash := range append([]string{tx.TxHash}, tx.Replaced...) {
+		if hash == "" {
+			continue
+		}
+		_, err := m.client.TransactionReceipt(ctx, common.HexToHash(hash))
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		if err != nil {
+			return errors.Join(ErrEthereum, err)
+		}
+		tx.TxHash = hash
+		break
+	}
+	tx.Status, tx.UpdatedAt = protos.NonceMined, time.Now().Unix()
+	return m.nonces.UpdateNonceTx(ctx, tx, []string{"tx_hash", "status", "updated_at"})
+}
+
+// Bump - replace the pending transaction of the nonce with higher fees, the
+// replaced hash is kept with the transaction.
+func (m *Manager) Bump(ctx context.Context, nonce uint64) (*types.Transaction, error) {
+	record, prev, err := m.load(ctx, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if record.Status != protos.NoncePending || prev == nil {
+		return nil, errors.Join(ErrNonce, fmt.Errorf("nonce %d is %s", nonce, record.Status))
+	}
+	tip, feeCap, err := m.fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tip, feeCap = bumpFees(prev.GasTipCap(), prev.GasFeeCap(), tip, feeCap)
+	return m.replace(ctx, record, m.withNonce(prev, nonce, tip, feeCap))
+}
+
+// Fill - send a transfer of nothing to itself with the nonce of a gap, so the
+// transactions after it are mined. A failed transaction was rejected by the
+// node or never sent, it is never sent again.
+func (m *Manager) Fill(ctx context.Context, nonce uint64) (*types.Transaction, error) {
+	record, prev, err := m.load(ctx, nonce)
+	if errors.Is(err, storage.ErrNotFound) {
+		record, err = &protos.NonceTx{ChainId: m.chainId.Uint64(), Address: m.Address().Hex(), Nonce: nonce}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Status == protos.NonceMined {
+		return nil, errors.Join(ErrNonce, fmt.Errorf("nonce %d is mined", nonce))
+	}
+	tip, feeCap, err := m.fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		// the pool may still hold it, the fill must replace it
+		tip, feeCap = bumpFees(prev.GasTipCap(), prev.GasFeeCap(), tip, feeCap)
+	}
+	to := m.Address()
+	return m.replace(ctx, record, types.NewTx(&types.DynamicFeeTx{
+		ChainID:   m.chainId,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       fillGas,
+		To:        &to,
+		Value:     big.NewInt(0),
+	}))
+}
+
+// replace - sign and send the transaction in place of the one of the record.
+func (m *Manager) replace(ctx context.Context, record *protos.NonceTx, tx *types.Transaction) (*types.Transaction, error) {
+	signTx, err := m.signer.SignTx(ctx, tx, m.chainId)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.client.SendTransaction(ctx, signTx); err != nil {
+		return nil, errors.Join(ErrEthereum, err)
+	}
+	if record.TxHash != "" {
+		record.Replaced = append(record.Replaced, record.TxHash)
+	}
+	record.Status = protos.NoncePending
+	if err := m.record(ctx, record, signTx); err != nil {
+		return nil, err
+	}
+	return signTx, nil
+}
+
+// record - store the signed transaction of the nonce.
+func (m *Manager) record(ctx context.Context, record *protos.NonceTx, signTx *types.Transaction) error {
+	raw, err := signTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	record.TxHash = signTx.Hash().Hex()
+	record.RawTx = hexutil.Encode(raw)
+	record.UpdatedAt = time.Now().Unix()
+	mask := []string{"tx_hash", "raw_tx", "status", "updated_at"}
+	if len(record.Replaced) > 0 {
+		mask = append(mask, "replaced")
+	}
+	return m.nonces.UpdateNonceTx(ctx, *record, mask)
+}
+
+// load - the record of the nonce with its signed transaction, nil when it
+// was never signed.
+func (m *Manager) load(ctx context.Context, nonce uint64) (*protos.NonceTx, *types.Transaction, error) {
+	if m.nonces == nil {
+		return nil, nil, ErrDynamodbClientNotFound
+	}
+	record, err := m.nonces.GetNonceTx(ctx, m.chainId.Uint64(), m.Address().Hex(), nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record.RawTx == "" {
+		return record, nil, nil
+	}
+	raw, err := hexutil.Decode(record.RawTx)
+	if err != nil {
+		return nil, nil, errors.Join(ErrNonce, err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, nil, errors.Join(ErrNonce, err)
+	}
+	return record, tx, nil
+}
+
+// fees - the suggested tip and a fee cap leaving room for the base fee to
+// double.
+func (m *Manager) fees(ctx context.Context) (*big.Int, *big.Int, error) {
+	tip, err := m.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, errors.Join(ErrEthereum, err)
+	}
+	baseFee, err := chain.BaseFee(ctx, m.client)
+	if err != nil {
+		return nil, nil, errors.Join(ErrEthereum, err)
+	}
+	return tip, new(big.Int).Add(tip, new(big.Int).Mul(baseFee, big.NewInt(2))), nil
+}
+
+// bumpFees - raise both fees of the replaced transaction by the bump percent,
+// or to the current fees when they are higher.
+func bumpFees(tip, feeCap, currentTip, currentFeeCap *big.Int) (*big.Int, *big.Int) {
+	bump := func(fee *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(fee, big.NewInt(100+bumpPercent))
+		bumped.Div(bumped, big.NewInt(100))
+		return bumped.Add(bumped, big.NewInt(1))
+	}
+	newTip, newFeeCap := bump(tip), bump(feeCap)
+	if currentTip.Cmp(newTip) > 0 {
+		newTip = new(big.Int).Set(currentTip)
+	}
+	if currentFeeCap.Cmp(newFeeCap) > 0 {
+		newFeeCap = new(big.Int).Set(currentFeeCap)
+	}
+	if newTip.Cmp(newFeeCap) > 0 {
+		newFeeCap = new(big.Int).Set(newTip)
+	}
+	return newTip, newFeeCap
+}
+
+// withNonce - the transaction with the nonce and the fees on the chain of
+// the manager.
+func (m *Manager) withNonce(tx *types.Transaction, nonce uint64, tip, feeCap *big.Int) *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:    m.chainId,
+		Nonce:      nonce,
+		GasTipCap:  tip,
+		GasFeeCap:  feeCap,
+		Gas:        tx.Gas(),
+		To:         tx.To(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	})
+}
@@ -0,0 +1,187 @@
+package nonce
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestBumpFees(t *testing.T) {
+	cases := []struct {
+		name                             string
+		tip, feeCap, current, currentCap int64
+		wantTip, wantCap                 int64
+	}{
+		{"bump", 1e9, 3e9, 5e8, 2e9, 1100000001, 3300000001},
+		{"current is higher", 1e9, 3e9, 2e9, 6e9, 2e9, 6e9},
+		{"cap follows tip", 1e9, 1e9, 4e9, 1e9, 4e9, 4e9},
+	}
+	for _, c := range cases {
+		tip, feeCap := bumpFees(big.NewInt(c.tip), big.NewInt(c.feeCap), big.NewInt(c.current), big.NewInt(c.currentCap))
+		if tip.Int64() != c.wantTip || feeCap.Int64() != c.wantCap {
+			t.Fatalf("%s: got tip %s fee cap %s", c.name, tip, feeCap)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	now, stuckAfter := int64(1000), int64(300)
+	tx := func(nonce uint64, status protos.NonceStatus, updatedAt int64) protos.NonceTx {
+		return protos.NonceTx{Nonce: nonce, Status: status, UpdatedAt: updatedAt}
+	}
+	// mined 10, the pool holds 10 and 11, 12 to 17 are handed out
+	txs := []protos.NonceTx{
+		tx(10, protos.NoncePending, 100),
+		tx(11, protos.NoncePending, 900),
+		tx(12, protos.NonceFailed, 900),
+		tx(13, protos.NonceReserved, 900),
+		tx(14, protos.NonceReserved, 100),
+		tx(15, protos.NoncePending, 100),
+		tx(17, protos.NoncePending, 900),
+	}
+	gaps, stuck := inspect(10, 12, 18, txs, now, stuckAfter)
+	if !reflect.DeepEqual(gaps, []uint64{12, 14, 16}) {
+		t.Fatalf("unexpected gaps %v", gaps)
+	}
+	if !reflect.DeepEqual(stuck, []uint64{10, 15}) {
+		t.Fatalf("unexpected stuck %v", stuck)
+	}
+
+	gaps, stuck = inspect(5, 5, 5, nil, now, stuckAfter)
+	if len(gaps) != 0 || len(stuck) != 0 {
+		t.Fatalf("no nonce handed out, got gaps %v stuck %v", gaps, stuck)
+	}
+}
+
+// sendClient - a chain whose pool starts at the pending nonce and answers
+// the sends with the error.
+type sendClient struct {
+	chain.Client
+	pending uint64
+	fail    error
+	sent    []*types.Transaction
+}
+
+func (c *sendClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return c.pending, nil
+}
+
+func (c *sendClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (c *sendClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: big.NewInt(1e9)}, nil
+}
+
+func (c *sendClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if c.fail != nil {
+		return c.fail
+	}
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &sendClient{pending: 7}
+	nonces := memory.NewNonceRepository()
+	m := NewManager(nonces, client, big.NewInt(11155111), erc20.NewKeySigner(key), 0)
+	chainId, address := uint64(11155111), m.Address().Hex()
+	status := func(nonce uint64) protos.NonceStatus {
+		record, err := nonces.GetNonceTx(ctx, chainId, address, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record.Status
+	}
+
+	// the nonces of a new address start at its pending nonce
+	first, err := m.Reserve(ctx, 2)
+	if err != nil || first != 7 {
+		t.Fatalf("reserved %d %v, not 7", first, err)
+	}
+	if status(7) != protos.NonceReserved || status(8) != protos.NonceReserved {
+		t.Fatal("expected the nonces reserved")
+	}
+
+	to := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	unsigned := types.NewTx(&types.DynamicFeeTx{Nonce: 0, Gas: 21000, To: &to, Value: big.NewInt(1), GasTipCap: big.NewInt(1e9), GasFeeCap: big.NewInt(3e9)})
+	sign := func(want uint64) *types.Transaction {
+		tx, err := m.Sign(ctx, unsigned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Nonce() != want || tx.ChainId().Uint64() != chainId {
+			t.Fatalf("signed nonce %d on chain %s, not %d", tx.Nonce(), tx.ChainId(), want)
+		}
+		return tx
+	}
+
+	tx := sign(9)
+	if err := m.Send(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	if status(9) != protos.NoncePending || len(client.sent) != 1 {
+		t.Fatalf("expected nonce 9 pending, got %s", status(9))
+	}
+
+	// a timeout may follow the broadcast, the transaction stays pending
+	client.fail = context.DeadlineExceeded
+	if err := m.Send(ctx, sign(10)); !errors.Is(err, ErrEthereum) {
+		t.Fatalf("expected the send to fail, got %v", err)
+	}
+	if status(10) != protos.NoncePending {
+		t.Fatalf("expected the ambiguous nonce 10 pending, got %s", status(10))
+	}
+
+	// a rejected transaction is never in the pool, its nonce is a gap
+	client.fail = errors.New("insufficient funds for gas * price + value")
+	rejected := sign(11)
+	if err := m.Send(ctx, rejected); !errors.Is(err, ErrEthereum) {
+		t.Fatalf("expected the send to fail, got %v", err)
+	}
+	if status(11) != protos.NonceFailed {
+		t.Fatalf("expected the rejected nonce 11 failed, got %s", status(11))
+	}
+
+	// the fill replaces the rejected transaction with a transfer to itself
+	client.fail = nil
+	fill, err := m.Fill(ctx, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fill.Nonce() != 11 || *fill.To() != m.Address() || fill.Value().Sign() != 0 || fill.GasTipCap().Cmp(rejected.GasTipCap()) <= 0 {
+		t.Fatalf("unexpected fill %+v", fill)
+	}
+	record, err := nonces.GetNonceTx(ctx, chainId, address, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != protos.NoncePending || record.TxHash != fill.Hash().Hex() || !reflect.DeepEqual(record.Replaced, []string{rejected.Hash().Hex()}) {
+		t.Fatalf("unexpected record %+v", record)
+	}
+
+	// a mined nonce is never filled
+	record.Status = protos.NonceMined
+	if err := nonces.UpdateNonceTx(ctx, *record, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Fill(ctx, 11); !errors.Is(err, ErrNonce) {
+		t.Fatalf("expected the mined nonce not to be filled, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package nonce
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Managers - a manager for each merchant address of the chains, the relayer
+// and the treasury signers. An address signing for several tokens has one
+// manager on a chain.
+func Managers(nonces storage.NonceRepository, chains *registry.Chains, stuckAfter time.Duration) []*Manager {
+	var managers []*Manager
+	for _, c := range chains.List() {
+		seen := make(map[string]bool)
+		add := func(signer erc20.Signer) {
+			if signer == nil || seen[signer.Address().Hex()] {
+				return
+			}
+			seen[signer.Address().Hex()] = true
+			managers = append(managers, NewManager(nonces, c.Client, c.Id, signer, stuckAfter))
+		}
+		if c.Relayer != nil {
+			add(c.Relayer.Signer())
+		}
+		for _, signer := range c.TreasurySigners {
+			add(signer)
+		}
+	}
+	return managers
+}
+
+// Find - the manager of the address on the chain, nil when it has none.
+func Find(managers []*Manager, chainId *big.Int, address common.Address) *Manager {
+	for _, m := range managers {
+		if m.chainId.Cmp(chainId) == 0 && m.Address() == address {
+			return m
+		}
+	}
+	return nil
+}
+
+// UseForRelayers - the relayers of the chains sign with the managed nonces.
+func UseForRelayers(nonces storage.NonceRepository, chains *registry.Chains) {
+	for _, c := range chains.List() {
+		if c.Relayer != nil {
+			c.Relayer.UseNonces(NewManager(nonces, c.Client, c.Id, c.Relayer.Signer(), 0))
+		}
+	}
+}
+
+// Result - the report of an address with the hashes of the transactions
+// filling its gaps and replacing its stuck transactions.
+type Result struct {
+	*Report
+	Filled []string `json:"filled"`
+	Bumped []string `json:"bumped"`
+	Errors []string `json:"errors"`
+}
+
+// Repair - check the nonces of the managers, fill the gaps and bump the
+// stuck transactions. The dry run only reports them.
+func Repair(ctx context.Context, managers []*Manager, dryRun bool) []Result {
+	results := make([]Result, 0, len(managers))
+	for _, m := range managers {
+		report, err := m.Check(ctx)
+		if err != nil {
+			results = append(results, Result{
+				Report: &Report{ChainId: m.chainId.Uint64(), Address: m.Address().Hex()},
+				Errors: []string{err.Error()},
+			})
+			continue
+		}
+		result := Result{Report: report, Filled: []string{}, Bumped: []string{}, Errors: []string{}}
+		if dryRun {
+			results = append(results, result)
+			continue
+		}
+		for _, nonce := range report.Gaps {
+			tx, err := m.Fill(ctx, nonce)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("fill %d: %s", nonce, err))
+				continue
+			}
+			result.Filled = append(result.Filled, tx.Hash().Hex())
+		}
+		for _, nonce := range report.Stuck {
+			tx, err := m.Bump(ctx, nonce)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("bump %d: %s", nonce, err))
+				continue
+			}
+			result.Bumped = append(result.Bumped, tx.Hash().Hex())
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,96 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+)
+
+var (
+	ErrDynamodbClientNotFound = errors.New("dynamodb client not found")
+	ErrPublish                = errors.New("publish outbox message failed")
+)
+
+// Publisher - the queue the monitor requests are published to.
+type Publisher interface {
+	// Publish - send the monitor request to the queue.
+	// @param ctx - context
+	// @param req - the monitor request
+	// @return error
+	Publish(ctx context.Context, req *protos.CreateMonitorRequest) error
+}
+
+// Relay - publish the pending outbox messages to sqs at least once.
+type Relay struct {
+	orders    storage.OrderRepository
+	publisher Publisher
+	interval  time.Duration
+	// maxAttempts - the failed publishes before a message is dead
+	maxAttempts int
+}
+
+func NewRelay(orders storage.OrderRepository, publisher Publisher, interval time.Duration, maxAttempts int) *Relay {
+	return &Relay{
+		orders:      orders,
+		publisher:   publisher,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run - flush the outbox every interval until the context is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Flush(ctx); err != nil {
+				log.Printf("outbox relay: %s", err)
+			}
+		}
+	}
+}
+
+// Flush - publish every pending message and mark it sent.
+// A message is marked sent only after sqs accepted it, so a crash in
+// between publishes it again on the next flush. A message which failed
+// max attempts times is dead and no longer published.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	if r.orders == nil {
+		return 0, ErrDynamodbClientNotFound
+	}
+	items, err := r.orders.GetPendingOutbox(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		sent int
+		errs []error
+	)
+	for _, item := range items {
+		if item.Message == nil {
+			continue
+		}
+		if err := r.publisher.Publish(ctx, item.Message); err != nil {
+			errs = append(errs, errors.Join(ErrPublish, err))
+			if err := r.orders.MarkOutboxFailed(ctx, item, err.Error(), r.maxAttempts); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := r.orders.MarkOutboxSent(ctx, item.Id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
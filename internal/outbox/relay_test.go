@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+)
+
+// fakeSQS - the queue which fails the orders of fail and keeps the rest.
+type fakeSQS struct {
+	fail     map[string]bool
+	messages []*protos.CreateMonitorRequest
+}
+
+func (q *fakeSQS) Publish(ctx context.Context, req *protos.CreateMonitorRequest) error {
+	if q.fail[req.OrderId] {
+		return errors.New("service unavailable")
+	}
+	q.messages = append(q.messages, req)
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	ctx := context.Background()
+	orders := memory.NewOrderRepository()
+	for i, id := range []string{"order-1", "order-2"} {
+		now := time.Now().Unix() + int64(i)
+		if err := orders.PutOrder(ctx, protos.Order{Id: id, From: "0x01", Status: protos.StatusCreated, CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		outbox := protos.Outbox{
+			Id:        "outbox-" + id,
+			Message:   &protos.CreateMonitorRequest{OrderId: id, From: "0x01"},
+			Status:    protos.OutboxPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := orders.PayOrder(ctx, "0x01", id, protos.Order{Status: protos.StatusPending}, []string{"status"}, outbox); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sqs := &fakeSQS{fail: map[string]bool{"order-2": true}}
+	relay := NewRelay(orders, sqs, time.Second, 2)
+
+	sent, err := relay.Flush(ctx)
+	if !errors.Is(err, ErrPublish) {
+		t.Fatalf("expected the failed publish to be reported, got %v", err)
+	}
+	if sent != 1 || len(sqs.messages) != 1 || sqs.messages[0].OrderId != "order-1" {
+		t.Fatalf("expected only order-1 to be published, sent %d %+v", sent, sqs.messages)
+	}
+	pending, err := orders.GetPendingOutbox(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Id != "outbox-order-2" || pending[0].Attempts != 1 || pending[0].LastError != "service unavailable" {
+		t.Fatalf("expected order-2 to stay pending after one attempt, got %+v", pending)
+	}
+
+	// the second failure reaches the cap, the message is dead
+	if _, err := relay.Flush(ctx); !errors.Is(err, ErrPublish) {
+		t.Fatalf("expected the failed publish to be reported, got %v", err)
+	}
+	if pending, err = orders.GetPendingOutbox(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected the dead message not to be pending, got %+v", pending)
+	}
+
+	// nothing is pending, the queue recovers without publishing it again
+	sqs.fail = nil
+	if sent, err = relay.Flush(ctx); err != nil || sent != 0 {
+		t.Fatalf("expected an empty flush, sent %d, %v", sent, err)
+	}
+	if len(sqs.messages) != 1 {
+		t.Fatalf("expected no more messages, got %+v", sqs.messages)
+	}
+}
@@ -0,0 +1,286 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/monitor"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	ErrDynamodbClientNotFound = errors.New("dynamodb client not found")
+	ErrEthereum               = errors.New("ethereum operation failed")
+)
+
+// StaleAfter - the orders updated within it are left to the monitor, it is
+// longer than the timeout of the monitor.
+var StaleAfter = 15 * time.Minute
+
+// Change - the settlement of one order.
+type Change struct {
+	OrderId  string `json:"order_id"`
+	From     string `json:"from"`
+	TxHash   string `json:"tx_hash"`
+	Previous string `json:"previous"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report - what a reconciliation run found and changed.
+type Report struct {
+	DryRun    bool     `json:"dry_run"`
+	StartedAt int64    `json:"started_at"`
+	Checked   int      `json:"checked"`
+	Changed   []Change `json:"changed"`
+	Skipped   []Change `json:"skipped"`
+	Failed    []Change `json:"failed"`
+}
+
+// Reconciler - settle orders stuck in pending or monitor_failed by reading
+// the payment receipts directly.
+type Reconciler struct {
+	orders storage.OrderRepository
+	chains *registry.Chains
+	// stale - the age of the last update of the orders which are settled
+	stale time.Duration
+}
+
+func NewReconciler(orders storage.OrderRepository, chains *registry.Chains, stale time.Duration) *Reconciler {
+	return &Reconciler{
+		orders: orders,
+		chains: chains,
+		stale:  stale,
+	}
+}
+
+// Run - scan the stuck orders and settle them as paid or paid_failed.
+// The orders updated after the stale cutoff are skipped, the monitor may
+// still settle them. In dry-run mode the report is built but no order is
+// updated.
+func (r *Reconciler) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	if r.orders == nil {
+		return nil, ErrDynamodbClientNotFound
+	}
+	now := time.Now()
+	cutoff := now.Add(-r.stale).Unix()
+	report := &Report{
+		DryRun:    dryRun,
+		StartedAt: now.Unix(),
+		Changed:   []Change{},
+		Skipped:   []Change{},
+		Failed:    []Change{},
+	}
+	orders, err := r.orders.GetOrdersByStatus(ctx, protos.StatusPending, protos.StatusMonitorFailed)
+	if err != nil {
+		return report, err
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		report.Checked++
+		change := Change{
+			OrderId:  order.Id,
+			From:     order.From,
+			TxHash:   order.PaymentHash,
+			Previous: order.Status.String(),
+		}
+		if order.UpdatedAt > cutoff {
+			change.Status = order.Status.String()
+			change.Reason = "order is not stale"
+			report.Skipped = append(report.Skipped, change)
+			continue
+		}
+		status, reason, err := r.settle(ctx, order, cutoff)
+		change.Reason = reason
+		if err != nil {
+			change.Status = order.Status.String()
+			change.Error = err.Error()
+			report.Failed = append(report.Failed, change)
+			continue
+		}
+		if status == order.Status || status == protos.StatusUnknow {
+			change.Status = order.Status.String()
+			report.Skipped = append(report.Skipped, change)
+			continue
+		}
+		change.Status = status.String()
+
+		if !dryRun {
+			order.Status = status
+			order.UpdatedAt = time.Now().Unix()
+			order.StatusCreatedAt = fmt.Sprintf("%s#%d", status.String(), order.CreatedAt)
+			mask := []string{"status", "updated_at", "status_created_at"}
+			if order.PaymentHash != change.TxHash {
+				// the payment was replaced by another transaction of its nonce
+				mask = append(mask, "payment_hash")
+			}
+			if _, err := r.orders.UpdateOrder(ctx, order.From, order.Id, *order, mask); err != nil {
+				change.Error = err.Error()
+				report.Failed = append(report.Failed, change)
+				continue
+			}
+		}
+		report.Changed = append(report.Changed, change)
+	}
+	return report, nil
+}
+
+// settle - decide the status of the order from its payment receipt.
+// StatusUnknow means the payment is not settled yet. A transaction which
+// is not found may still be broadcast until the order is stale, i.e.
+// updated before the cutoff.
+func (r *Reconciler) settle(ctx context.Context, order *protos.Order, cutoff int64) (protos.Status, string, error) {
+	if order.PaymentHash == "" {
+		return protos.StatusPaidFailed, "payment hash is empty", nil
+	}
+	hash := common.HexToHash(order.PaymentHash)
+	ch, err := r.chains.Get(order.ChainId)
+	if err != nil {
+		return protos.StatusUnknow, "chain is not registered", err
+	}
+
+	receipt, err := ch.Client.TransactionReceipt(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		replaced, replacedReceipt, err := r.replacement(ctx, ch, order)
+		if err != nil {
+			return protos.StatusUnknow, "", errors.Join(ErrEthereum, err)
+		}
+		if replaced != nil {
+			order.PaymentHash = replaced.Hash().Hex()
+			return r.match(ctx, ch, order, replaced.Hash(), replacedReceipt)
+		}
+		_, isPending, err := ch.Client.TransactionByHash(ctx, hash)
+		if errors.Is(err, ethereum.NotFound) {
+			if order.UpdatedAt > cutoff {
+				return protos.StatusUnknow, "transaction not found", nil
+			}
+			return r.failAuthorization(ctx, ch, order, "transaction not found")
+		}
+		if err != nil {
+			return protos.StatusUnknow, "", errors.Join(ErrEthereum, err)
+		}
+		if isPending {
+			return protos.StatusUnknow, "transaction is still pending", nil
+		}
+		return protos.StatusUnknow, "receipt not found", nil
+	}
+	if err != nil {
+		return protos.StatusUnknow, "", errors.Join(ErrEthereum, err)
+	}
+	return r.match(ctx, ch, order, hash, receipt)
+}
+
+// match - decide the status of the order from the mined payment.
+func (r *Reconciler) match(ctx context.Context, ch *registry.Chain, order *protos.Order, hash common.Hash, receipt *types.Receipt) (protos.Status, string, error) {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return r.failAuthorization(ctx, ch, order, "transaction reverted")
+	}
+	if ch.IsNative(order.Token) {
+		return r.matchValue(ctx, ch, order, hash)
+	}
+	return r.matchTransfer(ch, order, receipt.Logs)
+}
+
+// replacement - the transaction mined with the nonce of the payment in its
+// place, a wallet speeding up or cancelling the payment sends one. The
+// payments stored without their nonce are never replaced.
+func (r *Reconciler) replacement(ctx context.Context, ch *registry.Chain, order *protos.Order) (*types.Transaction, *types.Receipt, error) {
+	if order.PaymentNonce == nil || !common.IsHexAddress(order.PaymentSender) {
+		return nil, nil, nil
+	}
+	return monitor.FindReplacement(ctx, ch.Client, common.HexToAddress(order.PaymentSender), *order.PaymentNonce,
+		common.HexToHash(order.PaymentHash), order.PaymentBlock)
+}
+
+// failAuthorization - the payment transaction failed, but the authorization
+// of the order may be submitted by another transaction, which is left to
+// the monitor.
+func (r *Reconciler) failAuthorization(ctx context.Context, ch *registry.Chain, order *protos.Order, reason string) (protos.Status, string, error) {
+	if order.AuthorizationNonce == "" {
+		return protos.StatusPaidFailed, reason, nil
+	}
+	token, err := ch.Tokens.Get(order.Token)
+	if err != nil {
+		return protos.StatusUnknow, "token is not registered", err
+	}
+	used, err := token.Service.AuthorizationState(ctx, order.From, common.HexToHash(order.AuthorizationNonce))
+	if err != nil {
+		return protos.StatusUnknow, "", errors.Join(ErrEthereum, err)
+	}
+	if used {
+		return protos.StatusUnknow, "authorization is used by another transaction", nil
+	}
+	return protos.StatusPaidFailed, reason, nil
+}
+
+// matchValue - the native payment has no log, the transaction itself must
+// send the quoted value from the buyer to the treasury.
+func (r *Reconciler) matchValue(ctx context.Context, ch *registry.Chain, order *protos.Order, hash common.Hash) (protos.Status, string, error) {
+	if order.Quote == nil {
+		return protos.StatusPaidFailed, "quote not found", nil
+	}
+	value, ok := new(big.Int).SetString(order.Quote.Value, 10)
+	if !ok {
+		return protos.StatusPaidFailed, fmt.Sprintf("quote value %s is invalid", order.Quote.Value), nil
+	}
+	tx, _, err := ch.Client.TransactionByHash(ctx, hash)
+	if err != nil {
+		return protos.StatusUnknow, "", errors.Join(ErrEthereum, err)
+	}
+	sender, err := types.LatestSignerForChainID(tx.ChainId()).Sender(tx)
+	if err != nil {
+		return protos.StatusPaidFailed, "sender not found", nil
+	}
+	if !strings.EqualFold(sender.Hex(), order.From) {
+		return protos.StatusPaidFailed, fmt.Sprintf("transaction sender %s is not the buyer", sender.Hex()), nil
+	}
+	if tx.To() == nil || *tx.To() != common.HexToAddress(ch.Treasuries[ch.Native.Symbol]) {
+		return protos.StatusPaidFailed, "transaction recipient is not the treasury", nil
+	}
+	if tx.Value().Cmp(value) != 0 {
+		return protos.StatusPaidFailed, fmt.Sprintf("transaction value %s does not match %s", tx.Value(), value), nil
+	}
+	return protos.StatusPaid, "transaction value matched", nil
+}
+
+// matchTransfer - find the Transfer log of the order in the receipt logs.
+func (r *Reconciler) matchTransfer(ch *registry.Chain, order *protos.Order, logs []*types.Log) (protos.Status, string, error) {
+	token, err := ch.Tokens.Get(order.Token)
+	if err != nil {
+		return protos.StatusUnknow, "token is not registered", err
+	}
+	treasury := common.HexToAddress(ch.Treasuries[token.Symbol])
+	amount := contract.ToWei(order.Amount, token.Decimals)
+	for _, vLog := range logs {
+		if vLog.Address != token.Address {
+			continue
+		}
+		transfer, err := contract.ParseTransferLog(token.Service.GetABI(), *vLog)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(transfer.From.Hex(), order.From) {
+			continue
+		}
+		if transfer.To != treasury {
+			return protos.StatusPaidFailed, fmt.Sprintf("transfer recipient %s is not the treasury", transfer.To.Hex()), nil
+		}
+		if transfer.Value.Cmp(amount) != 0 {
+			return protos.StatusPaidFailed, fmt.Sprintf("transfer amount %s does not match %s", transfer.Value, amount), nil
+		}
+		return protos.StatusPaid, "transfer log found", nil
+	}
+	return protos.StatusPaidFailed, "transfer log not found", nil
+}
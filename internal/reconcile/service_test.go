@@ -0,0 +1,358 @@
+package reconcile
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/registry"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/memory"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/native"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// receiptClient - a chain of the mined transactions by block, the nonce of
+// an address is past its last transaction up to the block.
+type receiptClient struct {
+	chain.Client
+	blocks   map[uint64][]*types.Transaction
+	latest   uint64
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (c *receiptClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.latest, nil
+}
+
+func (c *receiptClient) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	var nonce uint64
+	for n, txs := range c.blocks {
+		if n > number.Uint64() {
+			continue
+		}
+		for _, tx := range txs {
+			if from, _ := types.LatestSignerForChainID(tx.ChainId()).Sender(tx); from == account && tx.Nonce() >= nonce {
+				nonce = tx.Nonce() + 1
+			}
+		}
+	}
+	return nonce, nil
+}
+
+func (c *receiptClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return types.NewBlockWithHeader(&types.Header{Number: number}).WithBody(c.blocks[number.Uint64()], nil), nil
+}
+
+func (c *receiptClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	receipt, ok := c.receipts[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func (c *receiptClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	for _, txs := range c.blocks {
+		for _, tx := range txs {
+			if tx.Hash() == hash {
+				return tx, false, nil
+			}
+		}
+	}
+	return nil, false, ethereum.NotFound
+}
+
+// mine - add the transaction to the next block with a receipt of the status.
+func (c *receiptClient) mine(tx *types.Transaction, status uint64, logs ...*types.Log) {
+	c.latest++
+	c.blocks[c.latest] = append(c.blocks[c.latest], tx)
+	c.receipts[tx.Hash()] = &types.Receipt{Status: status, TxHash: tx.Hash(), Logs: logs, BlockNumber: new(big.Int).SetUint64(c.latest)}
+}
+
+// tokenService - the token whose authorizations are used by used.
+type tokenService struct {
+	erc20.ERC20Service
+	abi  abi.ABI
+	used map[common.Hash]bool
+}
+
+func (s *tokenService) GetABI() abi.ABI {
+	return s.abi
+}
+
+func (s *tokenService) AuthorizationState(ctx context.Context, authorizer string, nonce common.Hash) (bool, error) {
+	return s.used[nonce], nil
+}
+
+func signedTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, to common.Address, value int64) *types.Transaction {
+	t.Helper()
+	chainId := big.NewInt(1337)
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainId,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(3e9),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(value),
+	}), types.NewLondonSigner(chainId), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestSettle(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	token, err := contract.CreateContract("ERC20", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyer := crypto.PubkeyToAddress(key.PublicKey)
+	treasury := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	other := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	client := &receiptClient{blocks: make(map[uint64][]*types.Transaction), receipts: make(map[common.Hash]*types.Receipt)}
+	service := &tokenService{abi: token.ABI, used: make(map[common.Hash]bool)}
+	tokens := erc20.NewRegistry()
+	if err := tokens.Register(&erc20.Token{Symbol: "USDC", Address: token.Address, Decimals: 6, Service: service}); err != nil {
+		t.Fatal(err)
+	}
+	chains := registry.NewChains()
+	if err := chains.Register(&registry.Chain{
+		Id:         big.NewInt(1337),
+		Client:     client,
+		Tokens:     tokens,
+		Native:     &native.Currency{Symbol: "ETH", Decimals: 18},
+		Treasuries: map[string]string{"ETH": treasury.Hex(), "USDC": treasury.Hex()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReconciler(nil, chains, time.Minute)
+
+	transferLog := func(to common.Address, amount float64) *types.Log {
+		return &types.Log{
+			Address: token.Address,
+			Topics: []common.Hash{
+				token.ABI.Events[contract.EventTransfer].ID,
+				common.BytesToHash(buyer.Bytes()),
+				common.BytesToHash(to.Bytes()),
+			},
+			Data: common.LeftPadBytes(contract.ToWei(amount, 6).Bytes(), 32),
+		}
+	}
+
+	// the transactions of the buyer use the nonces in order
+	nonce := uint64(0)
+	next := func(to common.Address, value int64) *types.Transaction {
+		tx := signedTx(t, key, nonce, to, value)
+		nonce++
+		return tx
+	}
+	paid := next(token.Address, 0)
+	client.mine(paid, types.ReceiptStatusSuccessful, transferLog(treasury, 1.5))
+	reverted := next(token.Address, 0)
+	client.mine(reverted, types.ReceiptStatusFailed)
+	wrongRecipient := next(other, 500)
+	client.mine(wrongRecipient, types.ReceiptStatusSuccessful)
+	wrongAmount := next(token.Address, 0)
+	client.mine(wrongAmount, types.ReceiptStatusSuccessful, transferLog(treasury, 1))
+	// the payment is sped up by another transaction of its nonce
+	replacedNonce := nonce
+	replaced := next(treasury, 500)
+	replacement := signedTx(t, key, replacedNonce, treasury, 500)
+	client.mine(replacement, types.ReceiptStatusSuccessful)
+	lost := signedTx(t, key, nonce+10, treasury, 500)
+
+	stale := time.Now().Add(-time.Hour).Unix()
+	fresh := time.Now().Unix()
+	cutoff := time.Now().Add(-time.Minute).Unix()
+	quote := &protos.Quote{Symbol: "ETH", Value: "500"}
+	authorization := common.HexToHash("0x01").Hex()
+	service.used[common.HexToHash(authorization)] = true
+
+	tests := []struct {
+		name   string
+		order  protos.Order
+		status protos.Status
+		hash   string
+	}{
+		{
+			name:   "paid",
+			order:  protos.Order{Token: "USDC", Amount: 1.5, PaymentHash: paid.Hash().Hex(), UpdatedAt: stale},
+			status: protos.StatusPaid,
+		},
+		{
+			name:   "not found while fresh",
+			order:  protos.Order{Token: "USDC", Amount: 1.5, PaymentHash: lost.Hash().Hex(), UpdatedAt: fresh},
+			status: protos.StatusUnknow,
+		},
+		{
+			name:   "not found",
+			order:  protos.Order{Token: "USDC", Amount: 1.5, PaymentHash: lost.Hash().Hex(), UpdatedAt: stale},
+			status: protos.StatusPaidFailed,
+		},
+		{
+			name:   "reverted",
+			order:  protos.Order{Token: "USDC", Amount: 1.5, PaymentHash: reverted.Hash().Hex(), UpdatedAt: stale},
+			status: protos.StatusPaidFailed,
+		},
+		{
+			name:   "wrong recipient",
+			order:  protos.Order{Token: "ETH", Quote: quote, PaymentHash: wrongRecipient.Hash().Hex(), UpdatedAt: stale},
+			status: protos.StatusPaidFailed,
+		},
+		{
+			name:   "wrong amount",
+			order:  protos.Order{Token: "USDC", Amount: 1.5, PaymentHash: wrongAmount.Hash().Hex(), UpdatedAt: stale},
+			status: protos.StatusPaidFailed,
+		},
+		{
+			name: "replaced",
+			order: protos.Order{Token: "ETH", Quote: quote, PaymentHash: replaced.Hash().Hex(), UpdatedAt: stale,
+				PaymentSender: buyer.Hex(), PaymentNonce: &replacedNonce, PaymentBlock: 1},
+			status: protos.StatusPaid,
+			hash:   replacement.Hash().Hex(),
+		},
+		{
+			name: "authorization used",
+			order: protos.Order{Token: "USDC", Amount: 1.5, PaymentHash: lost.Hash().Hex(), UpdatedAt: stale,
+				AuthorizationNonce: authorization},
+			status: protos.StatusUnknow,
+		},
+		{
+			name:   "authorization reverted",
+			order:  protos.Order{Token: "USDC", Amount: 1.5, PaymentHash: reverted.Hash().Hex(), UpdatedAt: stale, AuthorizationNonce: common.HexToHash("0x02").Hex()},
+			status: protos.StatusPaidFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order
+			order.From, order.ChainId = buyer.Hex(), 1337
+			hash := order.PaymentHash
+			if tt.hash != "" {
+				hash = tt.hash
+			}
+			status, reason, err := r.settle(context.Background(), &order, cutoff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.status {
+				t.Fatalf("expected %v, got %v: %s", tt.status, status, reason)
+			}
+			if order.PaymentHash != hash {
+				t.Fatalf("expected the payment hash %s, got %s", hash, order.PaymentHash)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyer := crypto.PubkeyToAddress(key.PublicKey)
+	treasury := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	client := &receiptClient{blocks: make(map[uint64][]*types.Transaction), receipts: make(map[common.Hash]*types.Receipt)}
+	chains := registry.NewChains()
+	if err := chains.Register(&registry.Chain{
+		Id:         big.NewInt(1337),
+		Client:     client,
+		Tokens:     erc20.NewRegistry(),
+		Native:     &native.Currency{Symbol: "ETH", Decimals: 18},
+		Treasuries: map[string]string{"ETH": treasury.Hex()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	paid := signedTx(t, key, 0, treasury, 500)
+	client.mine(paid, types.ReceiptStatusSuccessful)
+	lost := signedTx(t, key, 10, treasury, 500)
+	stale := time.Now().Add(-time.Hour).Unix()
+	quote := &protos.Quote{Symbol: "ETH", Value: "500"}
+
+	orders := memory.NewOrderRepository()
+	put := func(id string, status protos.Status, hash string, updatedAt int64) {
+		t.Helper()
+		err := orders.PutOrder(ctx, protos.Order{Id: id, From: buyer.Hex(), ChainId: 1337, Token: "ETH", Quote: quote,
+			Status: status, PaymentHash: hash, UpdatedAt: updatedAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("paid", protos.StatusPending, paid.Hash().Hex(), stale)
+	put("lost", protos.StatusMonitorFailed, lost.Hash().Hex(), stale)
+	put("fresh", protos.StatusPending, lost.Hash().Hex(), time.Now().Unix())
+	put("created", protos.StatusCreated, "", stale)
+	status := func(id string) protos.Status {
+		t.Helper()
+		order, err := orders.GetOrder(ctx, buyer.Hex(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order.Status
+	}
+	changed := func(report *Report) map[string]string {
+		changes := make(map[string]string)
+		for _, change := range report.Changed {
+			changes[change.OrderId] = change.Status
+		}
+		return changes
+	}
+	want := map[string]string{"paid": protos.StatusPaid.String(), "lost": protos.StatusPaidFailed.String()}
+
+	r := NewReconciler(orders, chains, time.Minute)
+	report, err := r.Run(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 3 || len(report.Skipped) != 1 || report.Skipped[0].OrderId != "fresh" || !reflect.DeepEqual(changed(report), want) {
+		t.Fatalf("unexpected dry run %+v", report)
+	}
+	// the dry run updates no order
+	if status("paid") != protos.StatusPending || status("lost") != protos.StatusMonitorFailed {
+		t.Fatal("expected the dry run to leave the orders")
+	}
+
+	if report, err = r.Run(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Failed) != 0 || !reflect.DeepEqual(changed(report), want) {
+		t.Fatalf("unexpected run %+v", report)
+	}
+	if status("paid") != protos.StatusPaid || status("lost") != protos.StatusPaidFailed || status("fresh") != protos.StatusPending {
+		t.Fatalf("unexpected statuses %s %s %s", status("paid"), status("lost"), status("fresh"))
+	}
+
+	// the settled orders are not checked again
+	if report, err = r.Run(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 1 || len(report.Changed) != 0 {
+		t.Fatalf("unexpected second run %+v", report)
+	}
+
+	if _, err := NewReconciler(nil, chains, time.Minute).Run(ctx, false); !errors.Is(err, ErrDynamodbClientNotFound) {
+		t.Fatalf("expected the missing repository to fail, got %v", err)
+	}
+}
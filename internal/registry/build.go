@@ -0,0 +1,208 @@
+package registry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/fees"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/native"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/relayer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Dialer - connect to the rpc of a chain.
+type Dialer func(ctx context.Context, url string) (chain.Client, error)
+
+// DialRPC - the dialer of the real chains.
+func DialRPC(ctx context.Context, url string) (chain.Client, error) {
+	return ethclient.DialContext(ctx, url)
+}
+
+// Build - connect the configured chains, the chain id reported by the rpc
+// must match the configured one.
+func Build(ctx context.Context, cfg *config.AppConfig, dial Dialer) (*Chains, error) {
+	chains := NewChains()
+	for _, val := range cfg.Chains {
+		client, err := dial(ctx, val.EthUrl)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("connect chain %d", val.ChainId), err)
+		}
+		c, err := newChain(ctx, cfg, val, client)
+		if err != nil {
+			return nil, err
+		}
+		if err := chains.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return chains, nil
+}
+
+func newChain(ctx context.Context, cfg *config.AppConfig, info *config.Chain, client chain.Client) (*Chain, error) {
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("get chain id of %d", info.ChainId), err)
+	}
+	if chainId.Uint64() != info.ChainId {
+		return nil, errors.Join(ErrChainMismatch, fmt.Errorf("rpc of %d returns %s", info.ChainId, chainId))
+	}
+
+	c := &Chain{
+		Id:              chainId,
+		Name:            info.Name,
+		Client:          client,
+		Tokens:          erc20.NewRegistry(),
+		Treasuries:      make(map[string]string, len(info.Tokens)+1),
+		TreasurySigners: make(map[string]erc20.Signer),
+		Fees:            fees.NewEstimator(client, cfg.FeeTTL),
+	}
+	for _, val := range info.Tokens {
+		token, err := contract.CreateContract(val.FilePath, val.Address)
+		if err != nil {
+			return nil, err
+		}
+		treasury, err := cfg.GetTreasury(info.ChainId, val.Address)
+		if err != nil {
+			return nil, err
+		}
+		service, err := erc20.NewERC20Service(client, token, chainId, val.Decimals)
+		if err != nil {
+			return nil, err
+		}
+		t := &erc20.Token{
+			Symbol:   val.Symbol,
+			Address:  token.Address,
+			Decimals: val.Decimals,
+			Service:  service,
+		}
+		if err := checkToken(ctx, t); err != nil {
+			return nil, errors.Join(fmt.Errorf("token %s on %d", val.Symbol, info.ChainId), err)
+		}
+		if val.Domain != nil {
+			t.Domain = &erc20.Domain{Name: val.Domain.Name, Version: val.Domain.Version}
+			t.Permit, t.Authorization = val.Permit, val.Authorization
+		}
+		if err := c.Tokens.Register(t); err != nil {
+			return nil, err
+		}
+		c.Treasuries[t.Symbol] = treasury
+		if err := c.treasurySigner(ctx, cfg.GetTreasurySigner(info.ChainId, val.Address), t.Symbol); err != nil {
+			return nil, err
+		}
+	}
+	if info.Relayer != nil {
+		if c.Relayer, err = newRelayer(ctx, client, chainId, info.Relayer); err != nil {
+			return nil, err
+		}
+	}
+	if info.Native == nil {
+		return c, nil
+	}
+
+	treasury, err := cfg.GetTreasury(info.ChainId, config.NativeToken)
+	if err != nil {
+		return nil, err
+	}
+	c.Native = &native.Currency{
+		Symbol:   strings.ToUpper(info.Native.Symbol),
+		Decimals: info.Native.Decimals,
+		Service:  native.NewNativeService(client, chainId),
+	}
+	c.Treasuries[c.Native.Symbol] = treasury
+	if err := c.treasurySigner(ctx, cfg.GetTreasurySigner(info.ChainId, config.NativeToken), c.Native.Symbol); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkToken - the symbol and the decimals of the config must be the ones of
+// the token contract.
+func checkToken(ctx context.Context, t *erc20.Token) error {
+	meta, err := t.Service.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(meta.Symbol, t.Symbol) {
+		return errors.Join(ErrTokenMismatch, fmt.Errorf("contract symbol is %s", meta.Symbol))
+	}
+	if int(meta.Decimals) != t.Decimals {
+		return errors.Join(ErrTokenMismatch, fmt.Errorf("contract decimals is %d", meta.Decimals))
+	}
+	return nil
+}
+
+// treasurySigner - the signer of the treasury of the symbol, it must sign
+// for the treasury address.
+func (c *Chain) treasurySigner(ctx context.Context, info *config.Signer, symbol string) error {
+	if info == nil {
+		return nil
+	}
+	signer, err := newSigner(ctx, info)
+	if err != nil {
+		return errors.Join(fmt.Errorf("treasury signer of %s on %s", symbol, c.Id), err)
+	}
+	if address := signer.Address().Hex(); address != c.Treasuries[symbol] {
+		return errors.Join(ErrChainMismatch, fmt.Errorf("treasury signer of %s is %s, not %s", symbol, address, c.Treasuries[symbol]))
+	}
+	c.TreasurySigners[symbol] = signer
+	return nil
+}
+
+// newSigner - the key and the keystore are read from the files of the envs
+// like the other secrets of the config, the remote signer holds the key itself.
+func newSigner(ctx context.Context, info *config.Signer) (erc20.Signer, error) {
+	switch {
+	case info.Keystore != "":
+		keyjson, err := os.ReadFile(os.Getenv(info.Keystore))
+		if err != nil {
+			return nil, err
+		}
+		password, err := os.ReadFile(os.Getenv(info.Password))
+		if err != nil {
+			return nil, err
+		}
+		return erc20.NewKeystoreSigner(keyjson, strings.TrimRight(string(password), "\r\n"))
+	case info.Remote != "":
+		return erc20.NewRemoteSigner(ctx, info.Remote, common.HexToAddress(info.RemoteAddress), info.RemoteMethod)
+	default:
+		key, err := readKey(info.Key)
+		if err != nil {
+			return nil, err
+		}
+		return erc20.NewKeySigner(key), nil
+	}
+}
+
+// readKey - read the hex key from the file of the env.
+func readKey(env string) (*ecdsa.PrivateKey, error) {
+	content, err := os.ReadFile(os.Getenv(env))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(string(content)), "0x"))
+}
+
+// newRelayer - the relayer signs with the signer of the config.
+func newRelayer(ctx context.Context, client chain.Client, chainId *big.Int, info *config.Relayer) (*relayer.Relayer, error) {
+	signer, err := newSigner(ctx, &info.Signer)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("relayer signer of %s", chainId), err)
+	}
+	budget := relayer.Budget{
+		MaxGas:    info.MaxGas,
+		MaxFeeCap: new(big.Int).SetUint64(info.MaxFeeCap),
+		Daily:     new(big.Int).SetUint64(info.DailyBudget),
+	}
+	return relayer.NewRelayer(client, chainId, signer, budget), nil
+}
@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20/binding"
+	"github.com/ethereum/go-ethereum"
+)
+
+// chainClient - a client which only knows its chain id and the metadata of
+// its token.
+type chainClient struct {
+	chain.Client
+	chainId  *big.Int
+	symbol   string
+	decimals uint8
+}
+
+func (c *chainClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return c.chainId, nil
+}
+
+func (c *chainClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	tokenABI, err := binding.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	method, err := tokenABI.MethodById(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	switch method.Name {
+	case "name":
+		return method.Outputs.Pack("USD Coin")
+	case "symbol":
+		return method.Outputs.Pack(c.symbol)
+	case "decimals":
+		return method.Outputs.Pack(c.decimals)
+	case "totalSupply":
+		return method.Outputs.Pack(big.NewInt(1e12))
+	}
+	return nil, errors.New("method not supported")
+}
+
+func TestBuild(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	dial := func(ctx context.Context, url string) (chain.Client, error) {
+		return &chainClient{chainId: big.NewInt(1337), symbol: "USDC", decimals: 6}, nil
+	}
+
+	token := "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"
+	treasury := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+	cfg := &config.AppConfig{
+		Chains: []*config.Chain{{
+			ChainId: 1337,
+			Name:    "simulated",
+			EthUrl:  "simulated",
+			Tokens:  []*config.Token{{FilePath: "ERC20", Address: token, Symbol: "usdc", Decimals: 6}},
+			Native:  &config.Native{Symbol: "eth", Decimals: 18},
+		}},
+		Treasuries: []*config.Treasury{
+			{ChainId: 1337, Token: token, Address: treasury},
+			{ChainId: 1337, Token: config.NativeToken, Address: treasury},
+		},
+	}
+	chains, err := Build(context.Background(), cfg, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := chains.Get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Id.Uint64() != 1337 || !c.IsNative("ETH") {
+		t.Fatalf("unexpected chain %s", c.Id)
+	}
+	usdc, err := c.Tokens.Get("USDC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Treasuries[usdc.Symbol] != treasury || c.Treasuries["ETH"] != treasury {
+		t.Fatalf("unexpected treasuries %v", c.Treasuries)
+	}
+	if _, err := chains.Get(1); !errors.Is(err, ErrChainNotFound) {
+		t.Fatalf("expected chain not found, got %v", err)
+	}
+
+	cfg.Chains[0].Tokens[0].Decimals = 18
+	if _, err := Build(context.Background(), cfg, dial); !errors.Is(err, ErrTokenMismatch) {
+		t.Fatalf("expected token mismatch, got %v", err)
+	}
+
+	cfg.Chains[0].Tokens[0].Decimals = 6
+	cfg.Chains[0].ChainId = 1
+	if _, err := Build(context.Background(), cfg, dial); !errors.Is(err, ErrChainMismatch) {
+		t.Fatalf("expected chain mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/fees"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/native"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/relayer"
+)
+
+var (
+	ErrChainNotFound   = errors.New("chain not found")
+	ErrChainRegistered = errors.New("chain already registered")
+	ErrChainMismatch   = errors.New("chain id mismatch")
+	ErrTokenMismatch   = errors.New("token mismatch")
+)
+
+// Chain - an EVM chain accepting payments, the treasuries are the recipient
+// addresses by token symbol and the treasury signers send the refunds of the
+// tokens which have one. The relayer is nil when the permits are not
+// accepted on the chain. The fees suggest the fees of the payments.
+type Chain struct {
+	Id              *big.Int
+	Name            string
+	Client          chain.Client
+	Tokens          *erc20.Registry
+	Native          *native.Currency
+	Treasuries      map[string]string
+	TreasurySigners map[string]erc20.Signer
+	Relayer         *relayer.Relayer
+	Fees            *fees.Estimator
+}
+
+// IsNative - the symbol is the native currency of the chain.
+func (c *Chain) IsNative(symbol string) bool {
+	return c.Native != nil && strings.EqualFold(symbol, c.Native.Symbol)
+}
+
+// Chains - the accepted chains by id, the first registered chain is the
+// default one.
+type Chains struct {
+	chains map[uint64]*Chain
+	ids    []uint64
+}
+
+func NewChains() *Chains {
+	return &Chains{
+		chains: make(map[uint64]*Chain),
+	}
+}
+
+// Register - add a chain, the id must be unique.
+func (r *Chains) Register(c *Chain) error {
+	if c == nil || c.Id == nil || c.Client == nil || c.Tokens == nil {
+		return errors.Join(ErrChainNotFound, fmt.Errorf("chain is incomplete"))
+	}
+	id := c.Id.Uint64()
+	if _, ok := r.chains[id]; ok {
+		return errors.Join(ErrChainRegistered, fmt.Errorf("chain %d", id))
+	}
+	r.chains[id] = c
+	r.ids = append(r.ids, id)
+	return nil
+}
+
+// Get - get the chain by id, 0 is the default chain.
+func (r *Chains) Get(id uint64) (*Chain, error) {
+	if id == 0 {
+		return r.Default()
+	}
+	c, ok := r.chains[id]
+	if !ok {
+		return nil, errors.Join(ErrChainNotFound, fmt.Errorf("chain %d", id))
+	}
+	return c, nil
+}
+
+// Default - the first registered chain.
+func (r *Chains) Default() (*Chain, error) {
+	if len(r.ids) == 0 {
+		return nil, ErrChainNotFound
+	}
+	return r.chains[r.ids[0]], nil
+}
+
+// List - the chains in the registered order.
+func (r *Chains) List() []*Chain {
+	chains := make([]*Chain, 0, len(r.ids))
+	for _, id := range r.ids {
+		chains = append(chains, r.chains[id])
+	}
+	return chains
+}
@@ -0,0 +1,35 @@
+package registry
+
+import (
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/config"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/oracle"
+)
+
+// BuildOracle - the oracle of the quotes. A quote of a chainlink oracle fails
+// when its feed errors or is stale, unless the fallback to the inline prices
+// is enabled.
+func BuildOracle(cfg *config.AppConfig, chains *Chains) (oracle.Oracle, error) {
+	if cfg.Oracle.Type == config.StaticOracle {
+		if cfg.Oracle.File != "" {
+			return oracle.LoadStaticOracle(cfg.Oracle.File)
+		}
+		return oracle.NewStaticOracle(cfg.Oracle.Prices)
+	}
+
+	c, err := chains.Get(cfg.Oracle.ChainId)
+	if err != nil {
+		return nil, err
+	}
+	feeds, err := oracle.NewChainlinkOracle(c.Client, cfg.Oracle.Feeds, cfg.Oracle.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Oracle.Fallback {
+		return feeds, nil
+	}
+	static, err := oracle.NewStaticOracle(cfg.Oracle.Prices)
+	if err != nil {
+		return nil, err
+	}
+	return oracle.NewFallback(feeds, static), nil
+}
@@ -1,10 +1,9 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
-	"reflect"
 
-	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
@@ -42,26 +41,131 @@ func GetProductInfoKey(id string) map[string]types.AttributeValue {
 	return result
 }
 
-func GetUpdateExpression(in interface{}, pk, sk string, updateMask []string) (expression.Expression, error) {
-	var (
-		vals   = reflect.ValueOf(in)
-		start  = true
-		update expression.UpdateBuilder
-	)
-	for _, key := range updateMask {
-		sKey := utils.ToCamelCase(key)
-		if sKey == pk || sKey == sk {
-			continue
-		}
+func GetOutboxKey(id string) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	result[Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(OutboxKey, id),
+	}
+	result[Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(MessageKey, id),
+	}
+	return result
+}
+
+func GetIdempotencyKey(address, key string) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	result[Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(UserKey, address),
+	}
+	result[Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(IdemKey, key),
+	}
+	return result
+}
+
+func GetRefundKey(address, orderId, refundId string) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	result[Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(UserKey, address),
+	}
+	result[Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(RefundKey, orderId, refundId),
+	}
+	return result
+}
+
+func GetCounterKey(name string) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	result[Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(CounterKey, name),
+	}
+	result[Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(CounterKey, name),
+	}
+	return result
+}
 
-		if vals.FieldByName(sKey).IsValid() {
-			if start {
-				update = expression.Set(expression.Name(key), expression.Value(vals.FieldByName(sKey).Interface()))
-				start = false
-			} else {
-				update.Set(expression.Name(key), expression.Value(vals.FieldByName(sKey).Interface()))
+// GetNonceKey - the next nonce of the address shares the pk of its
+// transactions.
+func GetNonceKey(chainId uint64, address string) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	result[Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(NonceKey, chainId, address),
+	}
+	result[Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(NonceKey, chainId, address),
+	}
+	return result
+}
+
+func GetNonceTxKey(chainId uint64, address string, nonce uint64) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	result[Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(NonceKey, chainId, address),
+	}
+	result[Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(NonceTxKey, nonce),
+	}
+	return result
+}
+
+func GetMigrationKey(version uint64) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	result[Pk] = &types.AttributeValueMemberS{
+		Value: MigrationKey,
+	}
+	result[Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(VersionKey, version),
+	}
+	return result
+}
+
+// IsConditionalCheckFailed - check the error is caused by a condition
+// expression, for a single item or inside a transaction.
+func IsConditionalCheckFailed(err error) bool {
+	if errors.Is(err, ErrConditionFailed) {
+		return true
+	}
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return true
+	}
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) {
+		for _, reason := range txErr.CancellationReasons {
+			if reason.Code != nil && *reason.Code == ConditionalCheckFailed {
+				return true
 			}
 		}
 	}
+	return false
+}
+
+// GetUpdateExpression - set the paths of the update mask from in and stamp
+// updated_at, the paths must start with one of the fields.
+func GetUpdateExpression(in interface{}, fields Fields, updateMask []string) (expression.Expression, error) {
+	update, err := getUpdateBuilder(in, fields, updateMask)
+	if err != nil {
+		return expression.Expression{}, err
+	}
 	return expression.NewBuilder().WithUpdate(update).Build()
 }
+
+// GetVersionedUpdateExpression - same as GetUpdateExpression, the item is
+// only updated when the condition holds and its version goes up by one.
+// When the version is not zero the item is only updated at that version,
+// zero skips the check.
+func GetVersionedUpdateExpression(in interface{}, fields Fields, updateMask []string, version uint64, condition expression.ConditionBuilder) (expression.Expression, error) {
+	update, err := getUpdateBuilder(in, fields, updateMask)
+	if err != nil {
+		return expression.Expression{}, err
+	}
+	update = update.Add(expression.Name(Version), expression.Value(1))
+	if version > 0 {
+		condition = expression.And(condition, expression.Name(Version).Equal(expression.Value(version)))
+	}
+	return expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(condition).
+		Build()
+}
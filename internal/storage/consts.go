@@ -9,11 +9,17 @@ const (
 	Sk              string = "sk"
 	SoftDeleted     string = "soft_deleted"
 	OrderStatusDate string = "order_status_date"
+	OutboxPending   string = "outbox_pending"
+	// Version - the version of a mutable item, every write adds one
+	Version string = "version"
 
-	SoftDeletedIndex  string = "soft_deleted_index"
-	FilterOrderStatus string = "filter_order_status"
-	PkNotExists       string = "attribute_not_exists(pk)"
-	PkExists          string = "attribute_exists(pk)"
+	SoftDeletedIndex   string = "soft_deleted_index"
+	FilterOrderStatus  string = "filter_order_status"
+	OutboxPendingIndex string = "outbox_pending_index"
+	PkNotExists        string = "attribute_not_exists(pk)"
+	PkExists           string = "attribute_exists(pk)"
+
+	ConditionalCheckFailed string = "ConditionalCheckFailed"
 )
 
 var (
@@ -21,6 +27,19 @@ var (
 	ProfileKey = "#PROFILE#%s"
 	OrderKey   = "ORDER#%s"
 	ProductKey = "PRODUCT#%s"
+	OutboxKey  = "OUTBOX#%s"
+	MessageKey = "MESSAGE#%s"
+	IdemKey    = "IDEMPOTENCY#%s"
+	CounterKey = "COUNTER#%s"
+	RefundKey  = "REFUND#%s#%s"
+	NonceKey   = "NONCE#%d#%s"
+	NonceTxKey = "TX#%020d"
+	// MigrationKey, VersionKey - the applied migrations of the table
+	MigrationKey = "MIGRATION"
+	VersionKey   = "VERSION#%020d"
 
 	ErrNotFound = errors.New("data not found")
+	// ErrConditionFailed - the condition of a write of a repository which is
+	// not backed by dynamodb failed
+	ErrConditionFailed = errors.New("condition failed")
 )
@@ -4,44 +4,33 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/once"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
-var (
-	onceInit  once.Once
-	daoClient *DaoClient
-)
-
 type DaoClient struct {
 	DynamoClient *dynamodb.Client
 	Table        string
 }
 
-func NewDynamoClient(ctx context.Context, region, table string) error {
-	var (
-		err    error
-		cfg    aws.Config
-		client *DaoClient
-	)
-	// singleton
-	onceInit.Do(func() error {
-		cfg, err = config.LoadDefaultConfig(ctx, config.WithRegion(region))
-		if err == nil {
-			client.Table = table
-			client.DynamoClient = dynamodb.NewFromConfig(cfg)
-			daoClient = client
-		}
-		return err
-	})
-	return err
+// NewDynamoClient - the client of the table in the region, every call
+// returns a new client.
+func NewDynamoClient(ctx context.Context, region, table string) (*DaoClient, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &DaoClient{
+		Table:        table,
+		DynamoClient: dynamodb.NewFromConfig(cfg),
+	}, nil
 }
 
-func NewDevLocalClient(table string, host string, port uint64) error {
-	cfg, _ := config.LoadDefaultConfig(context.TODO(),
+// NewDevLocalClient - the client of the table of DynamoDB Local.
+func NewDevLocalClient(table string, host string, port uint64) (*DaoClient, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithRegion("us-east-1"),
 		config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
 			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
@@ -54,15 +43,11 @@ func NewDevLocalClient(table string, host string, port uint64) error {
 			},
 		}),
 	)
-	dynamo := dynamodb.NewFromConfig(cfg)
-	client := &DaoClient{
-		Table:        table,
-		DynamoClient: dynamo,
+	if err != nil {
+		return nil, err
 	}
-	daoClient = client
-	return nil
-}
-
-func GetDynamoClient() *DaoClient {
-	return daoClient
+	return &DaoClient{
+		Table:        table,
+		DynamoClient: dynamodb.NewFromConfig(cfg),
+	}, nil
 }
@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+)
+
+// UpdatedAt - the update time of an item, it is stamped on every update
+const UpdatedAt string = "updated_at"
+
+var ErrInvalidUpdateMask = errors.New("invalid update mask")
+
+// Fields - the mutable fields of an item by their names in the update mask.
+// A path of the mask starts with one of them and goes on through the fields
+// of a struct or the keys of a map, e.g. addresses.home.postal_code.
+type Fields map[string]struct{}
+
+func NewFields(names ...string) Fields {
+	fields := make(Fields, len(names))
+	for _, name := range names {
+		fields[name] = struct{}{}
+	}
+	return fields
+}
+
+var (
+	// UserFields - the profile, the address and the timestamps are kept
+	UserFields = NewFields("name", "email", "addresses")
+	// ProductFields - the catalog fields of the product
+	ProductFields = NewFields("name", "description", "image", "price", "currency", "soft_deleted")
+	// OrderFields - the shipping address, the quote and the progress of the
+	// payment, the buyer, the products, the chain and the deposit address
+	// are fixed when the order is created
+	OrderFields = NewFields("address", "amount", "currency", "quote", "prepared_tx", "status", "status_created_at",
+		"payment_hash", "payment_sender", "payment_nonce", "payment_block", "authorization_nonce",
+		"shipment_hash", "sweep_hash", "sweep_fund_hash", "refunded")
+	// NonceTxFields - the sent transaction of the nonce
+	NonceTxFields = NewFields("tx_hash", "raw_tx", "replaced", "status")
+)
+
+// Validate - check every path of the update mask is a mutable field of in.
+// @param in - the item with the new values
+// @param updateMask - the paths to update, updated_at is always allowed
+// @return error - ErrInvalidUpdateMask with the invalid paths
+func (f Fields) Validate(in interface{}, updateMask []string) error {
+	_, err := f.resolve(reflect.ValueOf(in), updateMask)
+	return err
+}
+
+// step - a step of a path, the field of a struct or the key of a map.
+type step struct {
+	name  string
+	index []int
+	key   reflect.Value
+}
+
+// maskPath - a path of the update mask and its value in the item.
+type maskPath struct {
+	steps []step
+	value reflect.Value
+}
+
+// name - the document path of the attribute.
+func (p maskPath) name() string {
+	names := make([]string, len(p.steps))
+	for i, s := range p.steps {
+		names[i] = s.name
+	}
+	return strings.Join(names, ".")
+}
+
+// resolve - the paths of the update mask, updated_at is skipped as it is
+// stamped anyway.
+func (f Fields) resolve(in reflect.Value, updateMask []string) ([]maskPath, error) {
+	var (
+		paths   []maskPath
+		invalid []string
+	)
+	for _, key := range updateMask {
+		key = strings.TrimSpace(key)
+		if key == UpdatedAt {
+			continue
+		}
+		segments := strings.Split(key, ".")
+		if _, ok := f[segments[0]]; !ok {
+			invalid = append(invalid, key)
+			continue
+		}
+		path, ok := lookup(in, segments)
+		if !ok {
+			invalid = append(invalid, key)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(invalid) > 0 {
+		return nil, errors.Join(ErrInvalidUpdateMask, fmt.Errorf("unknown fields %s", strings.Join(invalid, ", ")))
+	}
+	if len(paths) == 0 {
+		return nil, errors.Join(ErrInvalidUpdateMask, errors.New("no fields to update"))
+	}
+
+	// a path cannot be updated with its parent or twice
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = path.name()
+	}
+	sort.Strings(names)
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] || strings.HasPrefix(names[i], names[i-1]+".") {
+			return nil, errors.Join(ErrInvalidUpdateMask, fmt.Errorf("%s overlaps %s", names[i], names[i-1]))
+		}
+	}
+	return paths, nil
+}
+
+// lookup - follow the segments through the fields of the structs, by their
+// dynamodbav or json names, and the string keys of the maps.
+func lookup(val reflect.Value, segments []string) (maskPath, bool) {
+	var path maskPath
+	for _, segment := range segments {
+		if segment == "" || strings.ContainsAny(segment, "[]") {
+			return path, false
+		}
+		for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return path, false
+			}
+			val = val.Elem()
+		}
+		switch val.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByName(val.Type(), segment)
+			if !ok {
+				return path, false
+			}
+			path.steps = append(path.steps, step{name: attributeName(field), index: field.Index})
+			val = val.FieldByIndex(field.Index)
+		case reflect.Map:
+			if val.Type().Key().Kind() != reflect.String {
+				return path, false
+			}
+			key := reflect.ValueOf(segment).Convert(val.Type().Key())
+			if val = val.MapIndex(key); !val.IsValid() {
+				return path, false
+			}
+			path.steps = append(path.steps, step{name: segment, key: key})
+		default:
+			return path, false
+		}
+	}
+	path.value = val
+	return path, true
+}
+
+// fieldByName - the field of the name, the keys and the version of an item
+// are never found.
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		switch attr := attributeName(field); attr {
+		case Pk, Sk, Version, "-":
+			continue
+		case name:
+			return field, true
+		}
+		if json, _, _ := strings.Cut(field.Tag.Get("json"), ","); json == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// attributeName - the name of the field in the item, as attributevalue
+// marshals it.
+func attributeName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("dynamodbav"), ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func getUpdateBuilder(in interface{}, fields Fields, updateMask []string) (expression.UpdateBuilder, error) {
+	val := reflect.Indirect(reflect.ValueOf(in))
+	paths, err := fields.resolve(val, updateMask)
+	if err != nil {
+		return expression.UpdateBuilder{}, err
+	}
+	var update expression.UpdateBuilder
+	for _, path := range paths {
+		update = update.Set(expression.Name(path.name()), expression.Value(path.value.Interface()))
+	}
+	if field, ok := val.Type().FieldByName("UpdatedAt"); ok && attributeName(field) == UpdatedAt {
+		update = update.Set(expression.Name(UpdatedAt), expression.Value(time.Now().Unix()))
+	}
+	return update, nil
+}
+
+// ApplyUpdateMask - set the paths of the update mask from in to out and
+// stamp updated_at, like the expression of GetUpdateExpression does. A
+// nested path fails when its parent does not exist in out. Out must be a
+// pointer to the type of in.
+func ApplyUpdateMask(out, in interface{}, fields Fields, updateMask []string) error {
+	paths, err := fields.resolve(reflect.ValueOf(in), updateMask)
+	if err != nil {
+		return err
+	}
+	dst := reflect.ValueOf(out).Elem()
+	for _, path := range paths {
+		if err := set(dst, path.steps, path.value); err != nil {
+			return errors.Join(ErrInvalidUpdateMask, fmt.Errorf("%s: %w", path.name(), err))
+		}
+	}
+	if field := dst.FieldByName("UpdatedAt"); field.IsValid() && field.CanSet() && field.Kind() == reflect.Int64 {
+		field.SetInt(time.Now().Unix())
+	}
+	return nil
+}
+
+// set - set the value at the steps of dst, the maps on the way are copied
+// as their values are not addressable.
+func set(dst reflect.Value, steps []step, value reflect.Value) error {
+	for dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			return errors.New("the parent does not exist")
+		}
+		dst = dst.Elem()
+	}
+	s := steps[0]
+	if s.index != nil {
+		field := dst.FieldByIndex(s.index)
+		if len(steps) == 1 {
+			field.Set(value)
+			return nil
+		}
+		return set(field, steps[1:], value)
+	}
+
+	if dst.IsNil() {
+		return errors.New("the parent does not exist")
+	}
+	if len(steps) == 1 {
+		dst.SetMapIndex(s.key, value)
+		return nil
+	}
+	current := dst.MapIndex(s.key)
+	if !current.IsValid() {
+		return errors.New("the parent does not exist")
+	}
+	elem := reflect.New(current.Type()).Elem()
+	elem.Set(current)
+	if err := set(elem, steps[1:], value); err != nil {
+		return err
+	}
+	dst.SetMapIndex(s.key, elem)
+	return nil
+}
@@ -0,0 +1,611 @@
+// Package memory - the repositories of the storage kept in memory, with the
+// same conditions as the dynamodb ones, for the tests of the services.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+)
+
+// users - the users by public address.
+type users struct {
+	mu    sync.Mutex
+	items map[string]protos.User
+}
+
+func NewUserRepository() storage.UserRepository {
+	return &users{items: make(map[string]protos.User)}
+}
+
+func (r *users) GetUser(ctx context.Context, publicAddress string) (*protos.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.items[publicAddress]
+	if !ok {
+		return new(protos.User), storage.ErrNotFound
+	}
+	user = copyUser(user)
+	return &user, nil
+}
+
+func (r *users) PutUser(ctx context.Context, user protos.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.items[user.PublicAddress]; ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("user %s exists", user.PublicAddress))
+	}
+	user.Version = 1
+	r.items[user.PublicAddress] = copyUser(user)
+	return nil
+}
+
+func (r *users) UpdateUser(ctx context.Context, publicAddress string, user protos.User, updateMask []string) (*protos.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.items[publicAddress]
+	if !ok {
+		return new(protos.User), errors.Join(storage.ErrConditionFailed, fmt.Errorf("user %s does not exist", publicAddress))
+	}
+	if err := checkVersion(current.Version, user.Version); err != nil {
+		return new(protos.User), err
+	}
+	// the addresses are copied, a failed mask leaves the stored user as it is
+	current = copyUser(current)
+	if err := storage.ApplyUpdateMask(&current, copyUser(user), storage.UserFields, updateMask); err != nil {
+		return new(protos.User), err
+	}
+	current.Version++
+	r.items[publicAddress] = current
+	current = copyUser(current)
+	return &current, nil
+}
+
+// products - the products by id.
+type products struct {
+	mu    sync.Mutex
+	items map[string]protos.Product
+}
+
+func NewProductRepository() storage.ProductRepository {
+	return &products{items: make(map[string]protos.Product)}
+}
+
+func (r *products) GetProduct(ctx context.Context, id string) (*protos.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	product, ok := r.items[id]
+	if !ok {
+		return new(protos.Product), storage.ErrNotFound
+	}
+	return &product, nil
+}
+
+func (r *products) PutProduct(ctx context.Context, product protos.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.items[product.Id]; ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("product %s exists", product.Id))
+	}
+	product.Version = 1
+	r.items[product.Id] = product
+	return nil
+}
+
+func (r *products) UpdateProduct(ctx context.Context, id string, product protos.Product, updateMask []string) (*protos.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.items[id]
+	if !ok {
+		return new(protos.Product), errors.Join(storage.ErrConditionFailed, fmt.Errorf("product %s does not exist", id))
+	}
+	if err := checkVersion(current.Version, product.Version); err != nil {
+		return new(protos.Product), err
+	}
+	if err := storage.ApplyUpdateMask(&current, product, storage.ProductFields, updateMask); err != nil {
+		return new(protos.Product), err
+	}
+	current.Version++
+	r.items[id] = current
+	return &current, nil
+}
+
+// GetProducts - get the products sorted by id.
+func (r *products) GetProducts(ctx context.Context) ([]*protos.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := make([]*protos.Product, 0, len(r.items))
+	for _, product := range r.items {
+		product := product
+		items = append(items, &product)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	return items, nil
+}
+
+// orders - the orders by buyer and id, their refunds, the outbox messages
+// of their payments and refunds, the idempotency keys and the counters.
+type orders struct {
+	mu          sync.Mutex
+	items       map[string]map[string]protos.Order
+	refunds     map[string]protos.Refund
+	outbox      map[string]protos.Outbox
+	idempotency map[string]protos.Idempotency
+	counters    map[string]uint64
+}
+
+func NewOrderRepository() storage.OrderRepository {
+	return &orders{
+		items:       make(map[string]map[string]protos.Order),
+		refunds:     make(map[string]protos.Refund),
+		outbox:      make(map[string]protos.Outbox),
+		idempotency: make(map[string]protos.Idempotency),
+		counters:    make(map[string]uint64),
+	}
+}
+
+func (r *orders) GetOrder(ctx context.Context, publicAddress, orderId string) (*protos.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	order, ok := r.items[publicAddress][orderId]
+	if !ok {
+		return new(protos.Order), storage.ErrNotFound
+	}
+	order = copyOrder(order)
+	return &order, nil
+}
+
+// GetUserOrders - get the orders of the user sorted by id.
+func (r *orders) GetUserOrders(ctx context.Context, publicAddress string) ([]protos.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var items []protos.Order
+	for _, order := range r.items[publicAddress] {
+		items = append(items, protos.Order{
+			Id:        order.Id,
+			From:      order.From,
+			Status:    order.Status,
+			CreatedAt: order.CreatedAt,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	return items, nil
+}
+
+// GetOrdersByStatus - get the orders of the statuses sorted by buyer and id.
+func (r *orders) GetOrdersByStatus(ctx context.Context, statuses ...protos.Status) ([]protos.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var items []protos.Order
+	for _, orders := range r.items {
+		for _, order := range orders {
+			if slices.Contains(statuses, order.Status) {
+				items = append(items, copyOrder(order))
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].From != items[j].From {
+			return items[i].From < items[j].From
+		}
+		return items[i].Id < items[j].Id
+	})
+	return items, nil
+}
+
+func (r *orders) PutOrder(ctx context.Context, order protos.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.items[order.From][order.Id]; ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s exists", order.Id))
+	}
+	if r.items[order.From] == nil {
+		r.items[order.From] = make(map[string]protos.Order)
+	}
+	order.Version = 1
+	r.items[order.From][order.Id] = copyOrder(order)
+	return nil
+}
+
+func (r *orders) UpdateOrder(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string) (*protos.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, err := r.update(publicAddress, orderId, order.Version, order, updateMask)
+	if err != nil {
+		return new(protos.Order), err
+	}
+	current = copyOrder(current)
+	return &current, nil
+}
+
+func (r *orders) PayOrder(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string, outbox protos.Outbox) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.items[publicAddress][orderId]
+	if !ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s does not exist", orderId))
+	}
+	if current.Status != protos.StatusCreated && current.Status != protos.StatusPaidFailed {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s is %v", orderId, current.Status))
+	}
+	if _, ok := r.outbox[outbox.Id]; ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("outbox %s exists", outbox.Id))
+	}
+	if _, err := r.update(publicAddress, orderId, order.Version, order, updateMask); err != nil {
+		return err
+	}
+	r.outbox[outbox.Id] = outbox
+	return nil
+}
+
+func (r *orders) CancelPayment(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.items[publicAddress][orderId]
+	if !ok || current.Status != protos.StatusPending || current.PaymentHash != order.PaymentHash {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s is not pending at %s", orderId, order.PaymentHash))
+	}
+	// the order was written by the payment, it is cancelled at any version
+	_, err := r.update(publicAddress, orderId, 0, order, updateMask)
+	return err
+}
+
+func (r *orders) RefundOrder(ctx context.Context, order protos.Order, previous protos.Status, updateMask []string, refund protos.Refund, outbox protos.Outbox) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.items[order.From][order.Id]
+	if !ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s does not exist", order.Id))
+	}
+	if current.Status != previous {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s is %v, not %v", order.Id, current.Status, previous))
+	}
+	id := refundId(refund.From, refund.OrderId, refund.Id)
+	if _, ok := r.refunds[id]; ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("refund %s exists", refund.Id))
+	}
+	if _, ok := r.outbox[outbox.Id]; ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("outbox %s exists", outbox.Id))
+	}
+	if _, err := r.update(order.From, order.Id, order.Version, order, updateMask); err != nil {
+		return err
+	}
+	r.refunds[id] = refund
+	r.outbox[outbox.Id] = outbox
+	return nil
+}
+
+func (r *orders) FailRefund(ctx context.Context, order protos.Order, updateMask []string, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.items[order.From][order.Id]
+	if !ok || current.Status != protos.StatusRefundPending {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s is not refund_pending", order.Id))
+	}
+	key := refundId(order.From, order.Id, id)
+	refund, ok := r.refunds[key]
+	if !ok || refund.Status != protos.RefundPending {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("refund %s is not pending", id))
+	}
+	// the order was written by the refund, it is restored at any version
+	if _, err := r.update(order.From, order.Id, 0, order, updateMask); err != nil {
+		return err
+	}
+	refund.Status, refund.UpdatedAt = protos.RefundFailed, time.Now().Unix()
+	r.refunds[key] = refund
+	return nil
+}
+
+// GetRefunds - get the refunds of the order by creation.
+func (r *orders) GetRefunds(ctx context.Context, publicAddress, orderId string) ([]protos.Refund, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var items []protos.Refund
+	for _, refund := range r.refunds {
+		if refund.From == publicAddress && refund.OrderId == orderId {
+			items = append(items, refund)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt < items[j].CreatedAt })
+	return items, nil
+}
+
+// GetPendingOutbox - get the pending messages by creation.
+func (r *orders) GetPendingOutbox(ctx context.Context) ([]protos.Outbox, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var items []protos.Outbox
+	for _, outbox := range r.outbox {
+		if outbox.Status == protos.OutboxPending {
+			items = append(items, outbox)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt < items[j].CreatedAt })
+	return items, nil
+}
+
+func (r *orders) MarkOutboxSent(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	outbox, ok := r.outbox[id]
+	if !ok || outbox.Status != protos.OutboxPending {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("outbox %s is not pending", id))
+	}
+	now := time.Now().Unix()
+	outbox.Status = protos.OutboxSent
+	outbox.UpdatedAt, outbox.SentAt = now, now
+	r.outbox[id] = outbox
+	return nil
+}
+
+func (r *orders) CancelOutbox(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	outbox, ok := r.outbox[id]
+	if !ok || outbox.Status != protos.OutboxPending {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("outbox %s is not pending", id))
+	}
+	outbox.Status, outbox.UpdatedAt = protos.OutboxCancelled, time.Now().Unix()
+	r.outbox[id] = outbox
+	return nil
+}
+
+func (r *orders) MarkOutboxFailed(ctx context.Context, outbox protos.Outbox, reason string, maxAttempts int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.outbox[outbox.Id]
+	if !ok || current.Status != protos.OutboxPending || current.Attempts != outbox.Attempts {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("outbox %s is not pending at %d attempts", outbox.Id, outbox.Attempts))
+	}
+	current.Attempts++
+	current.LastError = reason
+	current.UpdatedAt = time.Now().Unix()
+	if maxAttempts > 0 && current.Attempts >= maxAttempts {
+		current.Status = protos.OutboxDead
+	}
+	r.outbox[outbox.Id] = current
+	return nil
+}
+
+// update - update the order at the version, zero is any version. The lock
+// is held by the caller.
+func (r *orders) update(publicAddress, orderId string, version uint64, order protos.Order, updateMask []string) (protos.Order, error) {
+	current, ok := r.items[publicAddress][orderId]
+	if !ok {
+		return current, errors.Join(storage.ErrConditionFailed, fmt.Errorf("order %s does not exist", orderId))
+	}
+	if err := checkVersion(current.Version, version); err != nil {
+		return current, err
+	}
+	current = copyOrder(current)
+	if err := storage.ApplyUpdateMask(&current, copyOrder(order), storage.OrderFields, updateMask); err != nil {
+		return current, err
+	}
+	current.Version++
+	r.items[publicAddress][orderId] = current
+	return current, nil
+}
+
+func (r *orders) PutIdempotency(ctx context.Context, info protos.Idempotency) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := idempotencyId(info.PublicAddress, info.Key)
+	if current, ok := r.idempotency[id]; ok && current.ExpireAt >= time.Now().Unix() {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("idempotency key %s is claimed", info.Key))
+	}
+	r.idempotency[id] = info
+	return nil
+}
+
+func (r *orders) GetIdempotency(ctx context.Context, publicAddress, key string) (*protos.Idempotency, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, ok := r.idempotency[idempotencyId(publicAddress, key)]
+	if !ok {
+		return new(protos.Idempotency), storage.ErrNotFound
+	}
+	return &info, nil
+}
+
+func (r *orders) CompleteIdempotency(ctx context.Context, publicAddress, key, response string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := idempotencyId(publicAddress, key)
+	info, ok := r.idempotency[id]
+	if !ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("idempotency key %s is not claimed", key))
+	}
+	info.Status = protos.IdempotencyCompleted
+	info.Response = response
+	info.UpdatedAt = time.Now().Unix()
+	r.idempotency[id] = info
+	return nil
+}
+
+func (r *orders) DeleteIdempotency(ctx context.Context, publicAddress, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.idempotency, idempotencyId(publicAddress, key))
+	return nil
+}
+
+func (r *orders) NextCounter(ctx context.Context, name string) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[name]++
+	return r.counters[name], nil
+}
+
+// nonces - the next nonce and the transactions by merchant address.
+type nonces struct {
+	mu   sync.Mutex
+	next map[string]uint64
+	txs  map[string]map[uint64]protos.NonceTx
+}
+
+func NewNonceRepository() storage.NonceRepository {
+	return &nonces{
+		next: make(map[string]uint64),
+		txs:  make(map[string]map[uint64]protos.NonceTx),
+	}
+}
+
+func (r *nonces) GetNextNonce(ctx context.Context, chainId uint64, address string) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next, ok := r.next[nonceId(chainId, address)]
+	if !ok {
+		return 0, storage.ErrNotFound
+	}
+	return next, nil
+}
+
+func (r *nonces) InitNonce(ctx context.Context, chainId uint64, address string, next uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := nonceId(chainId, address)
+	if _, ok := r.next[id]; ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("nonce of %s exists", address))
+	}
+	r.next[id] = next
+	return nil
+}
+
+func (r *nonces) ReserveNonces(ctx context.Context, chainId uint64, address string, next, count uint64, now int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := nonceId(chainId, address)
+	if current, ok := r.next[id]; !ok || current != next {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("next nonce of %s is not %d", address, next))
+	}
+	if r.txs[id] == nil {
+		r.txs[id] = make(map[uint64]protos.NonceTx)
+	}
+	for nonce := next; nonce < next+count; nonce++ {
+		r.txs[id][nonce] = protos.NonceTx{
+			ChainId:   chainId,
+			Address:   address,
+			Nonce:     nonce,
+			Status:    protos.NonceReserved,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+	r.next[id] = next + count
+	return nil
+}
+
+func (r *nonces) AdvanceNonce(ctx context.Context, chainId uint64, address string, next uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := nonceId(chainId, address)
+	if current, ok := r.next[id]; ok && current < next {
+		r.next[id] = next
+	}
+	return nil
+}
+
+func (r *nonces) GetNonceTx(ctx context.Context, chainId uint64, address string, nonce uint64) (*protos.NonceTx, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tx, ok := r.txs[nonceId(chainId, address)][nonce]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	tx = copyNonceTx(tx)
+	return &tx, nil
+}
+
+func (r *nonces) UpdateNonceTx(ctx context.Context, tx protos.NonceTx, updateMask []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := nonceId(tx.ChainId, tx.Address)
+	current, ok := r.txs[id][tx.Nonce]
+	if !ok {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("nonce %d is not reserved", tx.Nonce))
+	}
+	current = copyNonceTx(current)
+	if err := storage.ApplyUpdateMask(&current, copyNonceTx(tx), storage.NonceTxFields, updateMask); err != nil {
+		return err
+	}
+	r.txs[id][tx.Nonce] = current
+	return nil
+}
+
+// GetUnminedNonceTxs - get the unmined transactions sorted by nonce.
+func (r *nonces) GetUnminedNonceTxs(ctx context.Context, chainId uint64, address string) ([]protos.NonceTx, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var txs []protos.NonceTx
+	for _, tx := range r.txs[nonceId(chainId, address)] {
+		if tx.Status != protos.NonceMined {
+			txs = append(txs, copyNonceTx(tx))
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Nonce < txs[j].Nonce })
+	return txs, nil
+}
+
+// checkVersion - the item is written at the version, zero is any version.
+func checkVersion(current, version uint64) error {
+	if version > 0 && version != current {
+		return errors.Join(storage.ErrConditionFailed, fmt.Errorf("version is %d, not %d", current, version))
+	}
+	return nil
+}
+
+func refundId(publicAddress, orderId, id string) string {
+	return fmt.Sprintf(storage.UserKey, publicAddress) + fmt.Sprintf(storage.RefundKey, orderId, id)
+}
+
+func nonceId(chainId uint64, address string) string {
+	return fmt.Sprintf(storage.NonceKey, chainId, address)
+}
+
+func idempotencyId(publicAddress, key string) string {
+	return fmt.Sprintf(storage.UserKey, publicAddress) + fmt.Sprintf(storage.IdemKey, key)
+}
+
+// copyUser - the addresses are not shared with the caller.
+func copyUser(user protos.User) protos.User {
+	if user.Addresses != nil {
+		addresses := make(map[string]protos.Address, len(user.Addresses))
+		for title, address := range user.Addresses {
+			addresses[title] = address
+		}
+		user.Addresses = addresses
+	}
+	return user
+}
+
+// copyOrder - the products, the quote and the nonce are not shared with the
+// caller.
+func copyOrder(order protos.Order) protos.Order {
+	if order.ProductIds != nil {
+		order.ProductIds = append([]protos.OrderProducts(nil), order.ProductIds...)
+	}
+	if order.Quote != nil {
+		quote := *order.Quote
+		order.Quote = &quote
+	}
+	if order.PaymentNonce != nil {
+		nonce := *order.PaymentNonce
+		order.PaymentNonce = &nonce
+	}
+	return order
+}
+
+// copyNonceTx - the replaced hashes are not shared with the caller.
+func copyNonceTx(tx protos.NonceTx) protos.NonceTx {
+	if tx.Replaced != nil {
+		tx.Replaced = append([]string(nil), tx.Replaced...)
+	}
+	return tx
+}
@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/storagetest"
+)
+
+func TestUserRepository(t *testing.T) {
+	storagetest.TestUserRepository(t, NewUserRepository())
+}
+
+func TestProductRepository(t *testing.T) {
+	storagetest.TestProductRepository(t, NewProductRepository())
+}
+
+func TestOrderRepository(t *testing.T) {
+	storagetest.TestOrderRepository(t, NewOrderRepository())
+}
+
+func TestNonceRepository(t *testing.T) {
+	storagetest.TestNonceRepository(t, NewNonceRepository())
+}
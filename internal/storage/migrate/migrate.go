@@ -0,0 +1,223 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrInvalidMigration = errors.New("invalid migration")
+
+// Migration - a change of the table, the migrations are applied once in the
+// order of their versions. Up must be safe to run on a table which already
+// has the change, e.g. the table created with the current schema.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      func(ctx context.Context, client *storage.DaoClient) error
+}
+
+// Record - the applied migration.
+// PK: MIGRATION
+// SK: VERSION#<version>
+type Record struct {
+	Version   uint64 `dynamodbav:"version"`
+	Name      string `dynamodbav:"name"`
+	AppliedAt int64  `dynamodbav:"applied_at"`
+}
+
+// Migrations - the migrations of the table, append new ones with the next
+// version and never change the applied ones.
+var Migrations = []Migration{
+	{Version: 1, Name: "expire_at ttl", Up: EnableTTL("expire_at")},
+	{Version: 2, Name: "outbox_pending_index", Up: CreateIndex(OutboxPendingIndex())},
+}
+
+// EnableTTL - the items expire at the unix time of the attribute.
+func EnableTTL(attribute string) func(ctx context.Context, client *storage.DaoClient) error {
+	return func(ctx context.Context, client *storage.DaoClient) error {
+		out, err := client.DynamoClient.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+			TableName: aws.String(client.Table),
+		})
+		if err != nil {
+			return err
+		}
+		if ttl := out.TimeToLiveDescription; ttl != nil &&
+			(ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled || ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+			if aws.ToString(ttl.AttributeName) != attribute {
+				return errors.Join(ErrInvalidMigration, fmt.Errorf("ttl of %s is %s", client.Table, aws.ToString(ttl.AttributeName)))
+			}
+			return nil
+		}
+		_, err = client.DynamoClient.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(client.Table),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String(attribute),
+				Enabled:       aws.Bool(true),
+			},
+		})
+		return err
+	}
+}
+
+// CreateIndex - add the global index unless the table has it, then wait for
+// it to become active.
+func CreateIndex(index types.GlobalSecondaryIndex) func(ctx context.Context, client *storage.DaoClient) error {
+	return func(ctx context.Context, client *storage.DaoClient) error {
+		out, err := client.DynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(client.Table),
+		})
+		if err != nil {
+			return err
+		}
+		for _, current := range out.Table.GlobalSecondaryIndexes {
+			if aws.ToString(current.IndexName) == aws.ToString(index.IndexName) {
+				return WaitActive(ctx, client)
+			}
+		}
+		_, err = client.DynamoClient.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(client.Table),
+			AttributeDefinitions: definitions(index.KeySchema),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+				Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:             index.IndexName,
+					KeySchema:             index.KeySchema,
+					Projection:            index.Projection,
+					ProvisionedThroughput: index.ProvisionedThroughput,
+				},
+			}},
+		})
+		if err != nil {
+			return err
+		}
+		return WaitActive(ctx, client)
+	}
+}
+
+// Applied - the applied migrations of the table by version.
+// PK: MIGRATION
+func Applied(ctx context.Context, client *storage.DaoClient) ([]Record, error) {
+	var records []Record
+	keyEx := expression.Key(storage.Pk).Equal(expression.Value(storage.MigrationKey))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyEx).Build()
+	if err != nil {
+		return nil, err
+	}
+	paginator := dynamodb.NewQueryPaginator(client.DynamoClient, &dynamodb.QueryInput{
+		TableName:                 aws.String(client.Table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ConsistentRead:            aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		response, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var page []Record
+		if err := attributevalue.UnmarshalListOfMaps(response.Items, &page); err != nil {
+			return nil, err
+		}
+		records = append(records, page...)
+	}
+	return records, nil
+}
+
+// record - store the applied migration, it fails when the version is
+// already recorded by another run.
+// PK: MIGRATION
+// SK: VERSION#<version>
+func record(ctx context.Context, client *storage.DaoClient, m Migration, now int64) error {
+	item, err := attributevalue.MarshalMap(Record{Version: m.Version, Name: m.Name, AppliedAt: now})
+	if err != nil {
+		return err
+	}
+	for key, value := range storage.GetMigrationKey(m.Version) {
+		item[key] = value
+	}
+	_, err = client.DynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(client.Table),
+		Item:                item,
+		ConditionExpression: aws.String(storage.PkNotExists),
+	})
+	return err
+}
+
+// Pending - the migrations which are not applied yet, by version.
+func Pending(applied []Record, migrations []Migration) ([]Migration, error) {
+	done := make(map[uint64]struct{}, len(applied))
+	for _, r := range applied {
+		done[r.Version] = struct{}{}
+	}
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	var pending []Migration
+	for i, m := range sorted {
+		if m.Version == 0 || m.Up == nil || strings.TrimSpace(m.Name) == "" {
+			return nil, errors.Join(ErrInvalidMigration, fmt.Errorf("version %d %q", m.Version, m.Name))
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, errors.Join(ErrInvalidMigration, fmt.Errorf("duplicate version %d", m.Version))
+		}
+		if _, ok := done[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Up - create the table unless it exists and apply the pending migrations
+// in order. With dry run the pending migrations are only returned.
+// @param ctx - context
+// @param client - the client of the table
+// @param migrations - the migrations of the table
+// @param dryRun - only report the pending migrations
+// @return the pending migrations, applied unless dry run
+// @return error - the applied migrations before the failure stay recorded
+func Up(ctx context.Context, client *storage.DaoClient, migrations []Migration, dryRun bool) ([]Migration, error) {
+	if dryRun {
+		applied, err := Applied(ctx, client)
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			// the table is created by the run
+			applied, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return Pending(applied, migrations)
+	}
+
+	if _, err := CreateTable(ctx, client); err != nil {
+		return nil, errors.Join(fmt.Errorf("create table %s", client.Table), err)
+	}
+	applied, err := Applied(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := Pending(applied, migrations)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range pending {
+		if err := m.Up(ctx, client); err != nil {
+			return pending[:i], errors.Join(fmt.Errorf("migration %d %s", m.Version, m.Name), err)
+		}
+		if err := record(ctx, client, m, time.Now().Unix()); err != nil {
+			return pending[:i], errors.Join(fmt.Errorf("record migration %d %s", m.Version, m.Name), err)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/google/uuid"
+)
+
+func TestTable(t *testing.T) {
+	in := Table("ECOMMERCE")
+	defined := make(map[string]int)
+	for _, def := range in.AttributeDefinitions {
+		if def.AttributeType == "" {
+			t.Fatalf("attribute %s has no type", aws.ToString(def.AttributeName))
+		}
+		defined[aws.ToString(def.AttributeName)]++
+	}
+	for _, name := range []string{storage.Pk, storage.Sk, storage.OrderStatusDate, storage.SoftDeleted, storage.OutboxPending} {
+		if defined[name] != 1 {
+			t.Fatalf("attribute %s is defined %d times", name, defined[name])
+		}
+	}
+	if len(defined) != len(in.AttributeDefinitions) {
+		t.Fatalf("unexpected definitions %+v", in.AttributeDefinitions)
+	}
+
+	indexes := make(map[string]bool)
+	for _, index := range in.GlobalSecondaryIndexes {
+		indexes[aws.ToString(index.IndexName)] = true
+	}
+	for _, index := range in.LocalSecondaryIndexes {
+		indexes[aws.ToString(index.IndexName)] = true
+	}
+	for _, name := range []string{storage.SoftDeletedIndex, storage.OutboxPendingIndex, storage.FilterOrderStatus} {
+		if !indexes[name] {
+			t.Fatalf("index %s is missing", name)
+		}
+	}
+}
+
+func TestPending(t *testing.T) {
+	up := func(context.Context, *storage.DaoClient) error { return nil }
+	migrations := []Migration{{Version: 3, Name: "c", Up: up}, {Version: 1, Name: "a", Up: up}, {Version: 2, Name: "b", Up: up}}
+
+	pending, err := Pending([]Record{{Version: 2}}, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].Version != 1 || pending[1].Version != 3 {
+		t.Fatalf("unexpected pending migrations %+v", pending)
+	}
+
+	for _, invalid := range [][]Migration{
+		append(migrations, Migration{Version: 1, Name: "again", Up: up}),
+		{{Version: 0, Name: "zero", Up: up}},
+		{{Version: 1, Name: "nil"}},
+		{{Version: 1, Up: up}},
+	} {
+		if _, err := Pending(nil, invalid); !errors.Is(err, ErrInvalidMigration) {
+			t.Fatalf("expected %+v to be invalid, got %v", invalid, err)
+		}
+	}
+
+	if _, err := Pending(nil, Migrations); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUp - a new table of DynamoDB Local is created and migrated once, the
+// test is skipped without DYNAMODB_HOST.
+func TestUp(t *testing.T) {
+	host := os.Getenv("DYNAMODB_HOST")
+	if host == "" {
+		t.Skip("DYNAMODB_HOST is not set")
+	}
+	port := uint64(8000)
+	if v := os.Getenv("DYNAMODB_PORT"); v != "" {
+		p, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		port = p
+	}
+	client, err := storage.NewDevLocalClient("MIGRATE_"+uuid.NewString(), host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	t.Cleanup(func() {
+		client.DynamoClient.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(client.Table)})
+	})
+
+	pending, err := Up(ctx, client, Migrations, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(Migrations) {
+		t.Fatalf("expected all migrations to be pending, got %+v", pending)
+	}
+	applied, err := Up(ctx, client, Migrations, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(Migrations) {
+		t.Fatalf("expected all migrations to be applied, got %+v", applied)
+	}
+	records, err := Applied(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(Migrations) || records[0].Version != 1 || records[0].AppliedAt == 0 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if applied, err = Up(ctx, client, Migrations, false); err != nil || len(applied) != 0 {
+		t.Fatalf("expected the second run to apply nothing, got %+v %v", applied, err)
+	}
+}
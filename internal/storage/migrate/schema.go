@@ -0,0 +1,183 @@
+// Package migrate - the schema of the table and its versioned migrations.
+// The table is created with the current schema, the migrations evolve the
+// tables created before and are recorded in the table itself.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// PollInterval - the interval of the status checks of the table and its
+	// indexes
+	PollInterval = 2 * time.Second
+
+	throughput = &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(5),
+		WriteCapacityUnits: aws.Int64(5),
+	}
+)
+
+// attributes - the types of the attributes of the keys of the table and
+// its indexes.
+var attributes = map[string]types.ScalarAttributeType{
+	storage.Pk:              types.ScalarAttributeTypeS,
+	storage.Sk:              types.ScalarAttributeTypeS,
+	storage.OrderStatusDate: types.ScalarAttributeTypeS,
+	storage.SoftDeleted:     types.ScalarAttributeTypeN,
+	storage.OutboxPending:   types.ScalarAttributeTypeN,
+}
+
+// SoftDeletedIndex - the products, they all carry soft_deleted.
+func SoftDeletedIndex() types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(storage.SoftDeletedIndex),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(storage.SoftDeleted), KeyType: types.KeyTypeHash},
+		},
+		Projection: &types.Projection{
+			ProjectionType:   types.ProjectionTypeInclude,
+			NonKeyAttributes: []string{storage.SoftDeleted, "image", "price", "description", "name"},
+		},
+		ProvisionedThroughput: throughput,
+	}
+}
+
+// OutboxPendingIndex - the pending outbox messages, the attribute is
+// removed once the message is sent so the index stays sparse.
+func OutboxPendingIndex() types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(storage.OutboxPendingIndex),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(storage.OutboxPending), KeyType: types.KeyTypeHash},
+		},
+		Projection:            &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		ProvisionedThroughput: throughput,
+	}
+}
+
+// FilterOrderStatusIndex - the orders of the user by status and date. A
+// local index can only be created with the table.
+func FilterOrderStatusIndex() types.LocalSecondaryIndex {
+	return types.LocalSecondaryIndex{
+		IndexName: aws.String(storage.FilterOrderStatus),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(storage.Pk), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(storage.OrderStatusDate), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+	}
+}
+
+// Table - the current schema of the table.
+func Table(name string) *dynamodb.CreateTableInput {
+	in := &dynamodb.CreateTableInput{
+		TableName: aws.String(name),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(storage.Pk), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(storage.Sk), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{SoftDeletedIndex(), OutboxPendingIndex()},
+		LocalSecondaryIndexes:  []types.LocalSecondaryIndex{FilterOrderStatusIndex()},
+		ProvisionedThroughput:  throughput,
+	}
+	schemas := [][]types.KeySchemaElement{in.KeySchema}
+	for _, index := range in.GlobalSecondaryIndexes {
+		schemas = append(schemas, index.KeySchema)
+	}
+	for _, index := range in.LocalSecondaryIndexes {
+		schemas = append(schemas, index.KeySchema)
+	}
+	in.AttributeDefinitions = definitions(schemas...)
+	return in
+}
+
+// definitions - the definitions of the attributes of the key schemas, each
+// attribute once.
+func definitions(schemas ...[]types.KeySchemaElement) []types.AttributeDefinition {
+	var (
+		seen = make(map[string]struct{})
+		defs []types.AttributeDefinition
+	)
+	for _, schema := range schemas {
+		for _, key := range schema {
+			name := aws.ToString(key.AttributeName)
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			defs = append(defs, types.AttributeDefinition{
+				AttributeName: key.AttributeName,
+				AttributeType: attributes[name],
+			})
+		}
+	}
+	return defs
+}
+
+// CreateTable - create the table with the current schema unless it exists,
+// then wait for it and its indexes to become active.
+// @param ctx - context
+// @param client - the client of the table
+// @return created - the table did not exist
+// @return error
+func CreateTable(ctx context.Context, client *storage.DaoClient) (bool, error) {
+	_, err := client.DynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(client.Table),
+	})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case err == nil:
+		return false, WaitActive(ctx, client)
+	case !errors.As(err, &notFound):
+		return false, err
+	}
+	if _, err := client.DynamoClient.CreateTable(ctx, Table(client.Table)); err != nil {
+		return false, err
+	}
+	return true, WaitActive(ctx, client)
+}
+
+// WaitActive - wait until the table and all of its global indexes are
+// active, or the context is done.
+func WaitActive(ctx context.Context, client *storage.DaoClient) error {
+	for {
+		out, err := client.DynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(client.Table),
+		})
+		if err != nil {
+			return err
+		}
+		pending := inactive(out.Table)
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), fmt.Errorf("%v of %s are not active", pending, client.Table))
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// inactive - the table and the global indexes which are not active.
+func inactive(table *types.TableDescription) []string {
+	var pending []string
+	if table.TableStatus != types.TableStatusActive {
+		pending = append(pending, aws.ToString(table.TableName))
+	}
+	for _, index := range table.GlobalSecondaryIndexes {
+		if index.IndexStatus != types.IndexStatusActive {
+			pending = append(pending, aws.ToString(index.IndexName))
+		}
+	}
+	return pending
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// NextCounter - increase the counter atomically and return the new value,
+// the first value is 1.
+// PK: COUNTER#<name>
+// SK: COUNTER#<name>
+func NextCounter(ctx context.Context, client *storage.DaoClient, name string) (uint64, error) {
+	update := expression.Add(expression.Name("value"), expression.Value(1))
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return 0, err
+	}
+	response, err := client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetCounterKey(name),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var counter struct {
+		Value uint64 `dynamodbav:"value"`
+	}
+	if err := attributevalue.UnmarshalMap(response.Attributes, &counter); err != nil {
+		return 0, err
+	}
+	if counter.Value == 0 {
+		return 0, fmt.Errorf("counter %s is not updated", name)
+	}
+	return counter.Value, nil
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// PutIdempotency - claim the idempotency key, an expired key can be claimed again.
+// PK: USER#<public address>
+// SK: IDEMPOTENCY#<key>
+func PutIdempotency(ctx context.Context, client *storage.DaoClient, info protos.Idempotency) error {
+	item, err := attributevalue.MarshalMap(info)
+	if err != nil {
+		return err
+	}
+	item[storage.Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(storage.UserKey, info.PublicAddress),
+	}
+	item[storage.Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(storage.IdemKey, info.Key),
+	}
+
+	condition := expression.Or(
+		expression.AttributeNotExists(expression.Name(storage.Pk)),
+		expression.Name("expire_at").LessThan(expression.Value(time.Now().Unix())))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(client.Table),
+		Item:                      item,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	})
+	return err
+}
+
+// GetIdempotency - get the idempotency record of the user.
+// PK: USER#<public address>
+// SK: IDEMPOTENCY#<key>
+func GetIdempotency(ctx context.Context, client *storage.DaoClient, publicAddress, key string) (*protos.Idempotency, error) {
+	info := new(protos.Idempotency)
+
+	data, err := client.DynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(client.Table),
+		Key:            storage.GetIdempotencyKey(publicAddress, key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return info, err
+	}
+	if data.Item == nil {
+		return info, storage.ErrNotFound
+	}
+	if err := attributevalue.UnmarshalMap(data.Item, info); err != nil {
+		return info, err
+	}
+	info.Key = strings.TrimPrefix(info.Key, fmt.Sprintf(storage.IdemKey, ""))
+	info.PublicAddress = strings.TrimPrefix(info.PublicAddress, fmt.Sprintf(storage.UserKey, ""))
+	return info, nil
+}
+
+// CompleteIdempotency - store the response of the request.
+// PK: USER#<public address>
+// SK: IDEMPOTENCY#<key>
+func CompleteIdempotency(ctx context.Context, client *storage.DaoClient, publicAddress, key, response string) error {
+	update := expression.Set(expression.Name("status"), expression.Value(protos.IdempotencyCompleted))
+	update.Set(expression.Name("response"), expression.Value(response))
+	update.Set(expression.Name("updated_at"), expression.Value(time.Now().Unix()))
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return err
+	}
+	_, err = client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetIdempotencyKey(publicAddress, key),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       aws.String(storage.PkExists),
+	})
+	return err
+}
+
+// DeleteIdempotency - release the idempotency key so the request can be retried.
+// PK: USER#<public address>
+// SK: IDEMPOTENCY#<key>
+func DeleteIdempotency(ctx context.Context, client *storage.DaoClient, publicAddress, key string) error {
+	_, err := client.DynamoClient.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(client.Table),
+		Key:       storage.GetIdempotencyKey(publicAddress, key),
+	})
+	return err
+}
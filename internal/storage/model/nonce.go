@@ -0,0 +1,227 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type nonceItem struct {
+	Next uint64 `dynamodbav:"next"`
+}
+
+// ReserveNonces - hand out the count nonces from the next nonce and insert
+// them reserved in one transaction. The next nonce must not have moved since
+// it was read, so two instances never reserve the same nonce.
+// Nonce Pk: NONCE#<chain_id>#<address>
+// Nonce Sk: NONCE#<chain_id>#<address>
+// Tx Pk: NONCE#<chain_id>#<address>
+// Tx Sk: TX#<nonce>
+func ReserveNonces(ctx context.Context, client *storage.DaoClient, chainId uint64, address string, next, count uint64, now int64) error {
+	update := expression.Set(expression.Name("next"), expression.Value(next+count))
+	condition := expression.Name("next").Equal(expression.Value(next))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+	items := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName:                 aws.String(client.Table),
+				Key:                       storage.GetNonceKey(chainId, address),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				UpdateExpression:          expr.Update(),
+				ConditionExpression:       expr.Condition(),
+			},
+		},
+	}
+	for nonce := next; nonce < next+count; nonce++ {
+		item, err := attributevalue.MarshalMap(protos.NonceTx{
+			ChainId:   chainId,
+			Address:   address,
+			Nonce:     nonce,
+			Status:    protos.NonceReserved,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		for key, val := range storage.GetNonceTxKey(chainId, address, nonce) {
+			item[key] = val
+		}
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(client.Table),
+				Item:      item,
+			},
+		})
+	}
+	_, err = client.DynamoClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	return err
+}
+
+// InitNonce - start the nonces of the address at the next nonce, it fails
+// when the address already has one.
+// PK: NONCE#<chain_id>#<address>
+// SK: NONCE#<chain_id>#<address>
+func InitNonce(ctx context.Context, client *storage.DaoClient, chainId uint64, address string, next uint64) error {
+	item, err := attributevalue.MarshalMap(nonceItem{Next: next})
+	if err != nil {
+		return err
+	}
+	for key, val := range storage.GetNonceKey(chainId, address) {
+		item[key] = val
+	}
+	_, err = client.DynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(client.Table),
+		Item:                item,
+		ConditionExpression: aws.String(storage.PkNotExists),
+	})
+	return err
+}
+
+// GetNextNonce - the next nonce handed out for the address, ErrNotFound when
+// the address has no nonce yet.
+// PK: NONCE#<chain_id>#<address>
+// SK: NONCE#<chain_id>#<address>
+func GetNextNonce(ctx context.Context, client *storage.DaoClient, chainId uint64, address string) (uint64, error) {
+	response, err := client.DynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(client.Table),
+		Key:            storage.GetNonceKey(chainId, address),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(response.Item) == 0 {
+		return 0, storage.ErrNotFound
+	}
+	var item nonceItem
+	if err := attributevalue.UnmarshalMap(response.Item, &item); err != nil {
+		return 0, err
+	}
+	return item.Next, nil
+}
+
+// AdvanceNonce - move the next nonce forward when the chain is ahead of it,
+// it never moves back.
+// PK: NONCE#<chain_id>#<address>
+// SK: NONCE#<chain_id>#<address>
+func AdvanceNonce(ctx context.Context, client *storage.DaoClient, chainId uint64, address string, next uint64) error {
+	update := expression.Set(expression.Name("next"), expression.Value(next))
+	condition := expression.And(
+		expression.AttributeExists(expression.Name(storage.Pk)),
+		expression.Name("next").LessThan(expression.Value(next)))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+	_, err = client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetNonceKey(chainId, address),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if storage.IsConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// GetNonceTx - the transaction of the nonce, ErrNotFound when the nonce is
+// not reserved.
+// PK: NONCE#<chain_id>#<address>
+// SK: TX#<nonce>
+func GetNonceTx(ctx context.Context, client *storage.DaoClient, chainId uint64, address string, nonce uint64) (*protos.NonceTx, error) {
+	response, err := client.DynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(client.Table),
+		Key:       storage.GetNonceTxKey(chainId, address, nonce),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Item) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	tx := new(protos.NonceTx)
+	if err := attributevalue.UnmarshalMap(response.Item, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// UpdateNonceTx - update the fields of the update mask of the transaction.
+// PK: NONCE#<chain_id>#<address>
+// SK: TX#<nonce>
+func UpdateNonceTx(ctx context.Context, client *storage.DaoClient, tx protos.NonceTx, updateMask []string) error {
+	expr, err := storage.GetUpdateExpression(tx, storage.NonceTxFields, updateMask)
+	if err != nil {
+		return err
+	}
+	_, err = client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetNonceTxKey(tx.ChainId, tx.Address, tx.Nonce),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       aws.String(storage.PkExists),
+	})
+	return err
+}
+
+// GetUnminedNonceTxs - the transactions of the address which are not known
+// to be mined, by nonce.
+// PK: NONCE#<chain_id>#<address>
+// SK: Between TX#0 and TX#<max>, status is not mined
+func GetUnminedNonceTxs(ctx context.Context, client *storage.DaoClient, chainId uint64, address string) ([]protos.NonceTx, error) {
+	var (
+		response *dynamodb.QueryOutput
+		txs      []protos.NonceTx
+	)
+
+	keyEx := expression.KeyAnd(
+		expression.Key(storage.Pk).Equal(expression.Value(fmt.Sprintf(storage.NonceKey, chainId, address))),
+		expression.Key(storage.Sk).Between(
+			expression.Value(fmt.Sprintf(storage.NonceTxKey, 0)),
+			expression.Value(fmt.Sprintf(storage.NonceTxKey, uint64(math.MaxUint64)))))
+	filter := expression.Name("status").NotEqual(expression.Value(protos.NonceMined))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyEx).WithFilter(filter).Build()
+	if err != nil {
+		return txs, err
+	}
+	queryPaginator := dynamodb.NewQueryPaginator(client.DynamoClient, &dynamodb.QueryInput{
+		TableName:                 aws.String(client.Table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+	})
+
+	for queryPaginator.HasMorePages() {
+		response, err = queryPaginator.NextPage(ctx)
+		if err != nil {
+			break
+		}
+		var txPage []protos.NonceTx
+		err = attributevalue.UnmarshalListOfMaps(response.Items, &txPage)
+		if err != nil {
+			break
+		}
+		txs = append(txs, txPage...)
+	}
+	return txs, err
+}
@@ -15,11 +15,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-var (
-	pkOrder = "id"
-	skOrder = "from"
-)
-
 // GetUserOrders - get all orders of user
 // PK: USER#<public address>
 // SK: BeginWith ORDER#
@@ -67,6 +62,7 @@ func GetUserOrders(ctx context.Context, client *storage.DaoClient, publicAddress
 // Pk: USER#<public address>
 // Sk: ORDER#<order_id>
 func PutOrder(ctx context.Context, client *storage.DaoClient, order protos.Order) error {
+	order.Version = 1
 	item, err := attributevalue.MarshalMap(order)
 	if err != nil {
 		return err
@@ -110,12 +106,14 @@ func GetOrder(ctx context.Context, client *storage.DaoClient, publicAddress stri
 	return order, nil
 }
 
-// UpdateOrder - update order.
+// UpdateOrder - update order at the version of the order, a zero version
+// updates any version.
 // Pk: USER#<public address>
 // Sk: ORDER#<order_id>
 func UpdateOrder(ctx context.Context, client *storage.DaoClient, publicAddress, orderId string, order protos.Order, updateMask []string) (*protos.Order, error) {
 	newInfo := new(protos.Order)
-	expr, err := storage.GetUpdateExpression(order, pkOrder, skOrder, updateMask)
+	condition := expression.AttributeExists(expression.Name(storage.Pk))
+	expr, err := storage.GetVersionedUpdateExpression(order, storage.OrderFields, updateMask, order.Version, condition)
 	if err != nil {
 		return newInfo, err
 	}
@@ -127,7 +125,7 @@ func UpdateOrder(ctx context.Context, client *storage.DaoClient, publicAddress,
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
 		ReturnValues:              types.ReturnValueAllNew,
-		ConditionExpression:       aws.String(storage.PkExists),
+		ConditionExpression:       expr.Condition(),
 	})
 
 	if err != nil {
@@ -187,3 +185,51 @@ func GetUserOrdersByStatusAndDate(ctx context.Context, client *storage.DaoClient
 	}
 	return orders, err
 }
+
+// GetOrdersByStatus - get orders of all users by status
+// Scan: BeginWith ORDER# and status in <statuses>
+func GetOrdersByStatus(ctx context.Context, client *storage.DaoClient, statuses ...protos.Status) ([]protos.Order, error) {
+	var (
+		response *dynamodb.ScanOutput
+		orders   []protos.Order
+	)
+	if len(statuses) == 0 {
+		return orders, nil
+	}
+
+	values := make([]expression.OperandBuilder, 0, len(statuses)-1)
+	for _, status := range statuses[1:] {
+		values = append(values, expression.Value(status))
+	}
+	filter := expression.And(
+		expression.BeginsWith(expression.Name(storage.Sk), fmt.Sprintf(storage.OrderKey, "")),
+		expression.Name("status").In(expression.Value(statuses[0]), values...))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return orders, err
+	}
+	scanPaginator := dynamodb.NewScanPaginator(client.DynamoClient, &dynamodb.ScanInput{
+		TableName:                 aws.String(client.Table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	})
+
+	for scanPaginator.HasMorePages() {
+		response, err = scanPaginator.NextPage(ctx)
+		if err != nil {
+			break
+		}
+		var orderPage []protos.Order
+		err = attributevalue.UnmarshalListOfMaps(response.Items, &orderPage)
+		if err != nil {
+			break
+		}
+		for _, order := range orderPage {
+			order.Id = strings.TrimPrefix(order.Id, fmt.Sprintf(storage.OrderKey, ""))
+			order.From = strings.TrimPrefix(order.From, fmt.Sprintf(storage.UserKey, ""))
+			orders = append(orders, order)
+		}
+	}
+	return orders, err
+}
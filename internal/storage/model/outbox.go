@@ -0,0 +1,223 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// PayOrderWithOutbox - update the order and insert the monitor request
+// into the outbox in one transaction. The order is only updated while it
+// is still created or paid_failed, at its version unless it is zero.
+// Order Pk: USER#<public address>
+// Order Sk: ORDER#<order_id>
+// Outbox Pk: OUTBOX#<outbox_id>
+// Outbox Sk: MESSAGE#<outbox_id>
+func PayOrderWithOutbox(ctx context.Context, client *storage.DaoClient, publicAddress, orderId string, order protos.Order, updateMask []string, outbox protos.Outbox) error {
+	condition := expression.And(
+		expression.AttributeExists(expression.Name(storage.Pk)),
+		expression.Name("status").In(
+			expression.Value(protos.StatusCreated),
+			expression.Value(protos.StatusPaidFailed)))
+	expr, err := storage.GetVersionedUpdateExpression(order, storage.OrderFields, updateMask, order.Version, condition)
+	if err != nil {
+		return err
+	}
+
+	item, err := outboxItem(outbox)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DynamoClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName:                 aws.String(client.Table),
+					Key:                       storage.GetUserOrderKey(publicAddress, orderId),
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					UpdateExpression:          expr.Update(),
+					ConditionExpression:       expr.Condition(),
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(client.Table),
+					Item:                item,
+					ConditionExpression: aws.String(storage.PkNotExists),
+				},
+			},
+		},
+	})
+	return err
+}
+
+// outboxItem - the item of a pending outbox message.
+func outboxItem(outbox protos.Outbox) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(outbox)
+	if err != nil {
+		return nil, err
+	}
+	item[storage.Pk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(storage.OutboxKey, outbox.Id),
+	}
+	item[storage.Sk] = &types.AttributeValueMemberS{
+		Value: fmt.Sprintf(storage.MessageKey, outbox.Id),
+	}
+	// only pending messages carry this attribute, so the index stays sparse
+	item[storage.OutboxPending] = &types.AttributeValueMemberN{
+		Value: fmt.Sprintf("%d", outbox.CreatedAt),
+	}
+	return item, nil
+}
+
+// CancelOrderPayment - update the order while it is still pending at the
+// payment hash of the order. The message of the payment is cancelled on its
+// own, the relay may have published it already.
+// Pk: USER#<public address>
+// Sk: ORDER#<order_id>
+func CancelOrderPayment(ctx context.Context, client *storage.DaoClient, publicAddress, orderId string, order protos.Order, updateMask []string) error {
+	// the order was written by the payment, it is cancelled at any version
+	condition := expression.And(
+		expression.Name("status").Equal(expression.Value(protos.StatusPending)),
+		expression.Name("payment_hash").Equal(expression.Value(order.PaymentHash)))
+	expr, err := storage.GetVersionedUpdateExpression(order, storage.OrderFields, updateMask, 0, condition)
+	if err != nil {
+		return err
+	}
+	_, err = client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetUserOrderKey(publicAddress, orderId),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	return err
+}
+
+// GetPendingOutbox - get all messages which are not published yet.
+// GSI: outbox_pending_index (outbox_pending)
+func GetPendingOutbox(ctx context.Context, client *storage.DaoClient) ([]protos.Outbox, error) {
+	var (
+		response *dynamodb.ScanOutput
+		items    []protos.Outbox
+		err      error
+	)
+
+	scanPaginator := dynamodb.NewScanPaginator(client.DynamoClient, &dynamodb.ScanInput{
+		TableName: aws.String(client.Table),
+		IndexName: aws.String(storage.OutboxPendingIndex),
+	})
+
+	for scanPaginator.HasMorePages() {
+		response, err = scanPaginator.NextPage(ctx)
+		if err != nil {
+			break
+		}
+		var itemPage []protos.Outbox
+		err = attributevalue.UnmarshalListOfMaps(response.Items, &itemPage)
+		if err != nil {
+			break
+		}
+		for _, item := range itemPage {
+			item.Id = strings.TrimPrefix(item.Id, fmt.Sprintf(storage.MessageKey, ""))
+			items = append(items, item)
+		}
+	}
+	return items, err
+}
+
+// MarkOutboxSent - mark the message as published.
+// Pk: OUTBOX#<outbox_id>
+// Sk: MESSAGE#<outbox_id>
+func MarkOutboxSent(ctx context.Context, client *storage.DaoClient, id string) error {
+	expr, err := getOutboxStatusExpression(protos.OutboxSent)
+	if err != nil {
+		return err
+	}
+	_, err = client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetOutboxKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	return err
+}
+
+// CancelOutbox - cancel the pending message.
+// Pk: OUTBOX#<outbox_id>
+// Sk: MESSAGE#<outbox_id>
+func CancelOutbox(ctx context.Context, client *storage.DaoClient, id string) error {
+	expr, err := getOutboxStatusExpression(protos.OutboxCancelled)
+	if err != nil {
+		return err
+	}
+	_, err = client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetOutboxKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	return err
+}
+
+// MarkOutboxFailed - record the failure of the pending message at the
+// attempts it was read with, the message is dead from the pending index
+// once it failed max attempts times.
+// Pk: OUTBOX#<outbox_id>
+// Sk: MESSAGE#<outbox_id>
+func MarkOutboxFailed(ctx context.Context, client *storage.DaoClient, outbox protos.Outbox, reason string, maxAttempts int) error {
+	attempts := outbox.Attempts + 1
+	update := expression.Set(expression.Name("attempts"), expression.Value(attempts))
+	update.Set(expression.Name("last_error"), expression.Value(reason))
+	update.Set(expression.Name("updated_at"), expression.Value(time.Now().Unix()))
+	if maxAttempts > 0 && attempts >= maxAttempts {
+		update.Set(expression.Name("status"), expression.Value(protos.OutboxDead))
+		update.Remove(expression.Name(storage.OutboxPending))
+	}
+	condition := expression.And(
+		expression.Name("status").Equal(expression.Value(protos.OutboxPending)),
+		expression.Name("attempts").Equal(expression.Value(outbox.Attempts)))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+	_, err = client.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.Table),
+		Key:                       storage.GetOutboxKey(outbox.Id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	return err
+}
+
+// getOutboxStatusExpression - move a pending message to the status and
+// drop it from the pending index.
+func getOutboxStatusExpression(status protos.OutboxStatus) (expression.Expression, error) {
+	now := time.Now().Unix()
+	update := expression.Set(expression.Name("status"), expression.Value(status))
+	update.Set(expression.Name("updated_at"), expression.Value(now))
+	if status == protos.OutboxSent {
+		update.Set(expression.Name("sent_at"), expression.Value(now))
+	}
+	update.Remove(expression.Name(storage.OutboxPending))
+	condition := expression.Name("status").Equal(expression.Value(protos.OutboxPending))
+	return expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+}
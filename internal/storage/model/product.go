@@ -15,10 +15,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-var (
-	pkProduct = "id"
-)
-
 // GetProduct - get product information
 // Pk: PRODUCT#<product_id>
 // Sk: #PROFILE#<product_id>
@@ -50,7 +46,7 @@ func GetProduct(ctx context.Context, client *storage.DaoClient, id string) (*pro
 // Pk: PRODUCT#<product_id>
 // Sk: #PROFILE#<product_id>
 func PutProduct(ctx context.Context, client *storage.DaoClient, data protos.Product) error {
-
+	data.Version = 1
 	item, err := attributevalue.MarshalMap(data)
 	if err != nil {
 		return err
@@ -70,12 +66,14 @@ func PutProduct(ctx context.Context, client *storage.DaoClient, data protos.Prod
 	return err
 }
 
-// UpdateProduct - update product information
+// UpdateProduct - update product information at the version of info, a
+// zero version updates any version.
 // Pk: PRODUCT#<product_id>
 // Sk: #PROFILE#<product_id>
 func UpdateProduct(ctx context.Context, client *storage.DaoClient, id string, info protos.Product, updateMask []string) (*protos.Product, error) {
 	newInfo := new(protos.Product)
-	expr, err := storage.GetUpdateExpression(info, pkProduct, "", updateMask)
+	condition := expression.AttributeExists(expression.Name(storage.Pk))
+	expr, err := storage.GetVersionedUpdateExpression(info, storage.ProductFields, updateMask, info.Version, condition)
 	if err != nil {
 		return newInfo, err
 	}
@@ -87,7 +85,7 @@ func UpdateProduct(ctx context.Context, client *storage.DaoClient, id string, in
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
 		ReturnValues:              types.ReturnValueAllNew,
-		ConditionExpression:       aws.String(storage.PkExists),
+		ConditionExpression:       expr.Condition(),
 	})
 
 	if err != nil {
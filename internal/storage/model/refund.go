@@ -0,0 +1,170 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// RefundOrderWithOutbox - update the order, insert the refund and its
+// monitor request into the outbox in one transaction. The order is only
+// updated while it is in the previous status, so one refund is pending at
+// a time.
+// Order Pk: USER#<public address>
+// Order Sk: ORDER#<order_id>
+// Refund Pk: USER#<public address>
+// Refund Sk: REFUND#<order_id>#<refund_id>
+// Outbox Pk: OUTBOX#<outbox_id>
+// Outbox Sk: MESSAGE#<outbox_id>
+func RefundOrderWithOutbox(ctx context.Context, client *storage.DaoClient, order protos.Order, previous protos.Status, updateMask []string, refund protos.Refund, outbox protos.Outbox) error {
+	condition := expression.And(
+		expression.AttributeExists(expression.Name(storage.Pk)),
+		expression.Name("status").Equal(expression.Value(previous)))
+	expr, err := storage.GetVersionedUpdateExpression(order, storage.OrderFields, updateMask, order.Version, condition)
+	if err != nil {
+		return err
+	}
+
+	refundItem, err := attributevalue.MarshalMap(refund)
+	if err != nil {
+		return err
+	}
+	for key, val := range storage.GetRefundKey(refund.From, refund.OrderId, refund.Id) {
+		refundItem[key] = val
+	}
+	outboxItem, err := outboxItem(outbox)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DynamoClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName:                 aws.String(client.Table),
+					Key:                       storage.GetUserOrderKey(order.From, order.Id),
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					UpdateExpression:          expr.Update(),
+					ConditionExpression:       expr.Condition(),
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(client.Table),
+					Item:                refundItem,
+					ConditionExpression: aws.String(storage.PkNotExists),
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(client.Table),
+					Item:                outboxItem,
+					ConditionExpression: aws.String(storage.PkNotExists),
+				},
+			},
+		},
+	})
+	return err
+}
+
+// FailOrderRefund - restore the order while it is still refund_pending and
+// mark the pending refund failed in one transaction. The message of the
+// refund is cancelled on its own, the relay may have published it already.
+// Order Pk: USER#<public address>
+// Order Sk: ORDER#<order_id>
+// Refund Pk: USER#<public address>
+// Refund Sk: REFUND#<order_id>#<refund_id>
+func FailOrderRefund(ctx context.Context, client *storage.DaoClient, order protos.Order, updateMask []string, refundId string) error {
+	// the order was written by the refund, it is restored at any version
+	orderExpr, err := storage.GetVersionedUpdateExpression(order, storage.OrderFields, updateMask, 0,
+		expression.Name("status").Equal(expression.Value(protos.StatusRefundPending)))
+	if err != nil {
+		return err
+	}
+	update := expression.Set(expression.Name("status"), expression.Value(protos.RefundFailed))
+	update.Set(expression.Name("updated_at"), expression.Value(time.Now().Unix()))
+	refundExpr, err := expression.NewBuilder().WithUpdate(update).
+		WithCondition(expression.Name("status").Equal(expression.Value(protos.RefundPending))).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DynamoClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName:                 aws.String(client.Table),
+					Key:                       storage.GetUserOrderKey(order.From, order.Id),
+					ExpressionAttributeNames:  orderExpr.Names(),
+					ExpressionAttributeValues: orderExpr.Values(),
+					UpdateExpression:          orderExpr.Update(),
+					ConditionExpression:       orderExpr.Condition(),
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName:                 aws.String(client.Table),
+					Key:                       storage.GetRefundKey(order.From, order.Id, refundId),
+					ExpressionAttributeNames:  refundExpr.Names(),
+					ExpressionAttributeValues: refundExpr.Values(),
+					UpdateExpression:          refundExpr.Update(),
+					ConditionExpression:       refundExpr.Condition(),
+				},
+			},
+		},
+	})
+	return err
+}
+
+// GetOrderRefunds - get the refunds of the order.
+// PK: USER#<public address>
+// SK: BeginWith REFUND#<order_id>#
+func GetOrderRefunds(ctx context.Context, client *storage.DaoClient, publicAddress, orderId string) ([]protos.Refund, error) {
+	var (
+		response *dynamodb.QueryOutput
+		refunds  []protos.Refund
+	)
+
+	prefix := fmt.Sprintf(storage.RefundKey, orderId, "")
+	keyEx := expression.KeyAnd(
+		expression.Key(storage.Pk).Equal(expression.Value(fmt.Sprintf(storage.UserKey, publicAddress))),
+		expression.KeyBeginsWith(expression.Key(storage.Sk), prefix))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyEx).Build()
+	if err != nil {
+		return refunds, err
+	}
+	queryPaginator := dynamodb.NewQueryPaginator(client.DynamoClient, &dynamodb.QueryInput{
+		TableName:                 aws.String(client.Table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+	})
+
+	for queryPaginator.HasMorePages() {
+		response, err = queryPaginator.NextPage(ctx)
+		if err != nil {
+			break
+		}
+		var refundPage []protos.Refund
+		err = attributevalue.UnmarshalListOfMaps(response.Items, &refundPage)
+		if err != nil {
+			break
+		}
+		for _, refund := range refundPage {
+			refund.Id = strings.TrimPrefix(refund.Id, prefix)
+			refund.From = strings.TrimPrefix(refund.From, fmt.Sprintf(storage.UserKey, ""))
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, err
+}
@@ -0,0 +1,185 @@
+package model
+
+import (
+	"context"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+)
+
+// users - the users of the table.
+type users struct {
+	client *storage.DaoClient
+}
+
+func NewUserRepository(client *storage.DaoClient) storage.UserRepository {
+	return &users{client: client}
+}
+
+func (r *users) GetUser(ctx context.Context, publicAddress string) (*protos.User, error) {
+	return GetUserInfo(ctx, r.client, publicAddress)
+}
+
+func (r *users) PutUser(ctx context.Context, user protos.User) error {
+	return PutUserInfo(ctx, r.client, user)
+}
+
+func (r *users) UpdateUser(ctx context.Context, publicAddress string, user protos.User, updateMask []string) (*protos.User, error) {
+	return UpdateUserInfo(ctx, r.client, publicAddress, user, updateMask)
+}
+
+// products - the products of the table.
+type products struct {
+	client *storage.DaoClient
+}
+
+func NewProductRepository(client *storage.DaoClient) storage.ProductRepository {
+	return &products{client: client}
+}
+
+func (r *products) GetProduct(ctx context.Context, id string) (*protos.Product, error) {
+	return GetProduct(ctx, r.client, id)
+}
+
+func (r *products) PutProduct(ctx context.Context, product protos.Product) error {
+	return PutProduct(ctx, r.client, product)
+}
+
+func (r *products) UpdateProduct(ctx context.Context, id string, product protos.Product, updateMask []string) (*protos.Product, error) {
+	return UpdateProduct(ctx, r.client, id, product, updateMask)
+}
+
+func (r *products) GetProducts(ctx context.Context) ([]*protos.Product, error) {
+	return GetAllProducts(ctx, r.client)
+}
+
+// orders - the orders of the table.
+type orders struct {
+	client *storage.DaoClient
+}
+
+func NewOrderRepository(client *storage.DaoClient) storage.OrderRepository {
+	return &orders{client: client}
+}
+
+// GetOrder - the empty order of the table is ErrNotFound, as for the users
+// and the products.
+func (r *orders) GetOrder(ctx context.Context, publicAddress, orderId string) (*protos.Order, error) {
+	order, err := GetOrder(ctx, r.client, publicAddress, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.Id == "" {
+		return order, storage.ErrNotFound
+	}
+	return order, nil
+}
+
+func (r *orders) GetUserOrders(ctx context.Context, publicAddress string) ([]protos.Order, error) {
+	return GetUserOrders(ctx, r.client, publicAddress)
+}
+
+func (r *orders) GetOrdersByStatus(ctx context.Context, statuses ...protos.Status) ([]protos.Order, error) {
+	return GetOrdersByStatus(ctx, r.client, statuses...)
+}
+
+func (r *orders) PutOrder(ctx context.Context, order protos.Order) error {
+	return PutOrder(ctx, r.client, order)
+}
+
+func (r *orders) UpdateOrder(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string) (*protos.Order, error) {
+	return UpdateOrder(ctx, r.client, publicAddress, orderId, order, updateMask)
+}
+
+func (r *orders) PayOrder(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string, outbox protos.Outbox) error {
+	return PayOrderWithOutbox(ctx, r.client, publicAddress, orderId, order, updateMask, outbox)
+}
+
+func (r *orders) CancelPayment(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string) error {
+	return CancelOrderPayment(ctx, r.client, publicAddress, orderId, order, updateMask)
+}
+
+func (r *orders) RefundOrder(ctx context.Context, order protos.Order, previous protos.Status, updateMask []string, refund protos.Refund, outbox protos.Outbox) error {
+	return RefundOrderWithOutbox(ctx, r.client, order, previous, updateMask, refund, outbox)
+}
+
+func (r *orders) FailRefund(ctx context.Context, order protos.Order, updateMask []string, refundId string) error {
+	return FailOrderRefund(ctx, r.client, order, updateMask, refundId)
+}
+
+func (r *orders) GetRefunds(ctx context.Context, publicAddress, orderId string) ([]protos.Refund, error) {
+	return GetOrderRefunds(ctx, r.client, publicAddress, orderId)
+}
+
+func (r *orders) GetPendingOutbox(ctx context.Context) ([]protos.Outbox, error) {
+	return GetPendingOutbox(ctx, r.client)
+}
+
+func (r *orders) MarkOutboxSent(ctx context.Context, id string) error {
+	return MarkOutboxSent(ctx, r.client, id)
+}
+
+func (r *orders) CancelOutbox(ctx context.Context, id string) error {
+	return CancelOutbox(ctx, r.client, id)
+}
+
+func (r *orders) MarkOutboxFailed(ctx context.Context, outbox protos.Outbox, reason string, maxAttempts int) error {
+	return MarkOutboxFailed(ctx, r.client, outbox, reason, maxAttempts)
+}
+
+func (r *orders) PutIdempotency(ctx context.Context, info protos.Idempotency) error {
+	return PutIdempotency(ctx, r.client, info)
+}
+
+func (r *orders) GetIdempotency(ctx context.Context, publicAddress, key string) (*protos.Idempotency, error) {
+	return GetIdempotency(ctx, r.client, publicAddress, key)
+}
+
+func (r *orders) CompleteIdempotency(ctx context.Context, publicAddress, key, response string) error {
+	return CompleteIdempotency(ctx, r.client, publicAddress, key, response)
+}
+
+func (r *orders) DeleteIdempotency(ctx context.Context, publicAddress, key string) error {
+	return DeleteIdempotency(ctx, r.client, publicAddress, key)
+}
+
+func (r *orders) NextCounter(ctx context.Context, name string) (uint64, error) {
+	return NextCounter(ctx, r.client, name)
+}
+
+// nonces - the nonces of the table.
+type nonces struct {
+	client *storage.DaoClient
+}
+
+func NewNonceRepository(client *storage.DaoClient) storage.NonceRepository {
+	return &nonces{client: client}
+}
+
+func (r *nonces) GetNextNonce(ctx context.Context, chainId uint64, address string) (uint64, error) {
+	return GetNextNonce(ctx, r.client, chainId, address)
+}
+
+func (r *nonces) InitNonce(ctx context.Context, chainId uint64, address string, next uint64) error {
+	return InitNonce(ctx, r.client, chainId, address, next)
+}
+
+func (r *nonces) ReserveNonces(ctx context.Context, chainId uint64, address string, next, count uint64, now int64) error {
+	return ReserveNonces(ctx, r.client, chainId, address, next, count, now)
+}
+
+func (r *nonces) AdvanceNonce(ctx context.Context, chainId uint64, address string, next uint64) error {
+	return AdvanceNonce(ctx, r.client, chainId, address, next)
+}
+
+func (r *nonces) GetNonceTx(ctx context.Context, chainId uint64, address string, nonce uint64) (*protos.NonceTx, error) {
+	return GetNonceTx(ctx, r.client, chainId, address, nonce)
+}
+
+func (r *nonces) UpdateNonceTx(ctx context.Context, tx protos.NonceTx, updateMask []string) error {
+	return UpdateNonceTx(ctx, r.client, tx, updateMask)
+}
+
+func (r *nonces) GetUnminedNonceTxs(ctx context.Context, chainId uint64, address string) ([]protos.NonceTx, error) {
+	return GetUnminedNonceTxs(ctx, r.client, chainId, address)
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage/storagetest"
+)
+
+// localClient - the table of DynamoDB Local created by make dynamodb-up,
+// the tests are skipped without DYNAMODB_HOST.
+func localClient(t *testing.T) *storage.DaoClient {
+	host := os.Getenv("DYNAMODB_HOST")
+	if host == "" {
+		t.Skip("DYNAMODB_HOST is not set")
+	}
+	port, table := uint64(8000), "ECOMMERCE"
+	if v := os.Getenv("DYNAMODB_PORT"); v != "" {
+		p, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		port = p
+	}
+	if v := os.Getenv("DYNAMODB_TABLE"); v != "" {
+		table = v
+	}
+	client, err := storage.NewDevLocalClient(table, host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestUserRepository(t *testing.T) {
+	storagetest.TestUserRepository(t, NewUserRepository(localClient(t)))
+}
+
+func TestProductRepository(t *testing.T) {
+	storagetest.TestProductRepository(t, NewProductRepository(localClient(t)))
+}
+
+func TestOrderRepository(t *testing.T) {
+	storagetest.TestOrderRepository(t, NewOrderRepository(localClient(t)))
+}
+
+func TestNonceRepository(t *testing.T) {
+	storagetest.TestNonceRepository(t, NewNonceRepository(localClient(t)))
+}
@@ -9,14 +9,11 @@ import (
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-var (
-	pkUser = "public_address"
-)
-
 // GetUserInfo - get user information
 // Pk: USER#<public address>
 // Sk: #PROFILE#<public address>
@@ -48,6 +45,7 @@ func GetUserInfo(ctx context.Context, client *storage.DaoClient, publicAddress s
 // PK: USER#<public address>
 // SK: #PROFILE#<public address>
 func PutUserInfo(ctx context.Context, client *storage.DaoClient, info protos.User) error {
+	info.Version = 1
 	data, err := attributevalue.MarshalMap(info)
 	if err != nil {
 		return err
@@ -82,12 +80,14 @@ func DeleteUserInfo(ctx context.Context, client *storage.DaoClient, publicAddres
 	return err
 }
 
-// UpdateUserInfo - update user information
+// UpdateUserInfo - update user information at the version of info, a zero
+// version updates any version.
 // PK: USER#<public address>
 // SK: #PROFILE#<public address>
 func UpdateUserInfo(ctx context.Context, client *storage.DaoClient, publicAddress string, info protos.User, updateMask []string) (*protos.User, error) {
 	newInfo := new(protos.User)
-	expr, err := storage.GetUpdateExpression(info, pkUser, "", updateMask)
+	condition := expression.AttributeExists(expression.Name(storage.Pk))
+	expr, err := storage.GetVersionedUpdateExpression(info, storage.UserFields, updateMask, info.Version, condition)
 	if err != nil {
 		return newInfo, err
 	}
@@ -99,7 +99,7 @@ func UpdateUserInfo(ctx context.Context, client *storage.DaoClient, publicAddres
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
 		ReturnValues:              types.ReturnValueAllNew,
-		ConditionExpression:       aws.String(storage.PkExists),
+		ConditionExpression:       expr.Condition(),
 	})
 
 	if err != nil {
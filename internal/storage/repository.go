@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+)
+
+// UserRepository - the users by public address.
+type UserRepository interface {
+	// GetUser - get the user.
+	// @param ctx - context
+	// @param publicAddress - address of the user
+	// @return user
+	// @return error - ErrNotFound when the user does not exist
+	GetUser(ctx context.Context, publicAddress string) (*protos.User, error)
+	// PutUser - insert a new user, it fails when the user exists.
+	// @param ctx - context
+	// @param user - user
+	// @return error
+	PutUser(ctx context.Context, user protos.User) error
+	// UpdateUser - update the fields of the update mask of the user.
+	// @param ctx - context
+	// @param publicAddress - address of the user
+	// @param user - the new values
+	// @param updateMask - snake case names of the fields
+	// @return the updated user
+	// @return error - a failed condition when the user does not exist
+	UpdateUser(ctx context.Context, publicAddress string, user protos.User, updateMask []string) (*protos.User, error)
+}
+
+// ProductRepository - the products by id.
+type ProductRepository interface {
+	// GetProduct - get the product.
+	// @param ctx - context
+	// @param id - id of the product
+	// @return product
+	// @return error - ErrNotFound when the product does not exist
+	GetProduct(ctx context.Context, id string) (*protos.Product, error)
+	// PutProduct - insert a new product, it fails when the product exists.
+	// @param ctx - context
+	// @param product - product
+	// @return error
+	PutProduct(ctx context.Context, product protos.Product) error
+	// UpdateProduct - update the fields of the update mask of the product.
+	// @param ctx - context
+	// @param id - id of the product
+	// @param product - the new values
+	// @param updateMask - snake case names of the fields
+	// @return the updated product
+	// @return error - a failed condition when the product does not exist
+	UpdateProduct(ctx context.Context, id string, product protos.Product, updateMask []string) (*protos.Product, error)
+	// GetProducts - get all products.
+	// @param ctx - context
+	// @return products
+	// @return error
+	GetProducts(ctx context.Context) ([]*protos.Product, error)
+}
+
+// OrderRepository - the orders of the users with their payments and
+// refunds, the outbox of the monitor requests of the payments and the
+// refunds, the idempotency keys of the payments and the counters of the
+// orders.
+type OrderRepository interface {
+	// GetOrder - get the order of the user.
+	// @param ctx - context
+	// @param publicAddress - address of the buyer
+	// @param orderId - id of the order
+	// @return order
+	// @return error - ErrNotFound when the order does not exist
+	GetOrder(ctx context.Context, publicAddress, orderId string) (*protos.Order, error)
+	// GetUserOrders - get the orders of the user by id, only the id, the
+	// buyer, the status and the creation time are returned.
+	// @param ctx - context
+	// @param publicAddress - address of the buyer
+	// @return orders
+	// @return error
+	GetUserOrders(ctx context.Context, publicAddress string) ([]protos.Order, error)
+	// GetOrdersByStatus - get the orders of all users in the statuses.
+	// @param ctx - context
+	// @param statuses - statuses of the orders, none returns no order
+	// @return orders
+	// @return error
+	GetOrdersByStatus(ctx context.Context, statuses ...protos.Status) ([]protos.Order, error)
+	// PutOrder - insert a new order, it fails when the order exists.
+	// @param ctx - context
+	// @param order - order
+	// @return error
+	PutOrder(ctx context.Context, order protos.Order) error
+	// UpdateOrder - update the fields of the update mask of the order.
+	// @param ctx - context
+	// @param publicAddress - address of the buyer
+	// @param orderId - id of the order
+	// @param order - the new values
+	// @param updateMask - snake case names of the fields
+	// @return the updated order
+	// @return error - a failed condition when the order does not exist
+	UpdateOrder(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string) (*protos.Order, error)
+	// PayOrder - update the order and insert its monitor request into the
+	// outbox at once, while the order is still created or paid_failed.
+	// @param ctx - context
+	// @param publicAddress - address of the buyer
+	// @param orderId - id of the order
+	// @param order - the new values
+	// @param updateMask - snake case names of the fields
+	// @param outbox - the pending message
+	// @return error - a failed condition when the order cannot be paid
+	PayOrder(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string, outbox protos.Outbox) error
+	// CancelPayment - update the order while it is still pending at the
+	// payment hash of the order.
+	// @param ctx - context
+	// @param publicAddress - address of the buyer
+	// @param orderId - id of the order
+	// @param order - the new values, with the payment hash which was written
+	// @param updateMask - snake case names of the fields
+	// @return error - a failed condition when the order is settled or paid
+	// by another transaction
+	CancelPayment(ctx context.Context, publicAddress, orderId string, order protos.Order, updateMask []string) error
+	// RefundOrder - update the order while it is still in the previous
+	// status, insert the refund and its monitor request into the outbox at
+	// once.
+	// @param ctx - context
+	// @param order - the new values, at its version
+	// @param previous - the status the order was read with
+	// @param updateMask - snake case names of the fields
+	// @param refund - the pending refund
+	// @param outbox - the pending message
+	// @return error - a failed condition when the order moved or the refund
+	// exists
+	RefundOrder(ctx context.Context, order protos.Order, previous protos.Status, updateMask []string, refund protos.Refund, outbox protos.Outbox) error
+	// FailRefund - restore the order while it is still refund_pending and
+	// mark the pending refund failed at once.
+	// @param ctx - context
+	// @param order - the restored values
+	// @param updateMask - snake case names of the fields
+	// @param refundId - id of the refund
+	// @return error - a failed condition when the order or the refund is
+	// settled
+	FailRefund(ctx context.Context, order protos.Order, updateMask []string, refundId string) error
+	// GetRefunds - get the refunds of the order.
+	// @param ctx - context
+	// @param publicAddress - address of the buyer
+	// @param orderId - id of the order
+	// @return refunds
+	// @return error
+	GetRefunds(ctx context.Context, publicAddress, orderId string) ([]protos.Refund, error)
+	// GetPendingOutbox - get the messages which are not published yet.
+	// @param ctx - context
+	// @return messages
+	// @return error
+	GetPendingOutbox(ctx context.Context) ([]protos.Outbox, error)
+	// MarkOutboxSent - mark the pending message published.
+	// @param ctx - context
+	// @param id - id of the message
+	// @return error - a failed condition when the message is not pending
+	MarkOutboxSent(ctx context.Context, id string) error
+	// CancelOutbox - cancel the pending message.
+	// @param ctx - context
+	// @param id - id of the message
+	// @return error - a failed condition when the message is not pending
+	CancelOutbox(ctx context.Context, id string) error
+	// MarkOutboxFailed - record the failed publish of the pending message,
+	// it is dead once it failed max attempts times.
+	// @param ctx - context
+	// @param outbox - the message as it was read
+	// @param reason - the error of the publish
+	// @param maxAttempts - the attempts before the message is dead, zero is no limit
+	// @return error - a failed condition when the message is not pending
+	// at its attempts
+	MarkOutboxFailed(ctx context.Context, outbox protos.Outbox, reason string, maxAttempts int) error
+	// PutIdempotency - claim the idempotency key, an expired key can be
+	// claimed again.
+	// @param ctx - context
+	// @param info - the claim
+	// @return error - a failed condition when the key is claimed
+	PutIdempotency(ctx context.Context, info protos.Idempotency) error
+	// GetIdempotency - get the claim of the idempotency key.
+	// @param ctx - context
+	// @param publicAddress - address of the user
+	// @param key - idempotency key
+	// @return the claim
+	// @return error - ErrNotFound when the key is not claimed
+	GetIdempotency(ctx context.Context, publicAddress, key string) (*protos.Idempotency, error)
+	// CompleteIdempotency - store the response of the claim.
+	// @param ctx - context
+	// @param publicAddress - address of the user
+	// @param key - idempotency key
+	// @param response - response of the request
+	// @return error - a failed condition when the key is not claimed
+	CompleteIdempotency(ctx context.Context, publicAddress, key, response string) error
+	// DeleteIdempotency - release the idempotency key.
+	// @param ctx - context
+	// @param publicAddress - address of the user
+	// @param key - idempotency key
+	// @return error
+	DeleteIdempotency(ctx context.Context, publicAddress, key string) error
+	// NextCounter - increase the counter and return the new value, the
+	// first value is 1.
+	// @param ctx - context
+	// @param name - name of the counter
+	// @return value
+	// @return error
+	NextCounter(ctx context.Context, name string) (uint64, error)
+}
+
+// NonceRepository - the next nonce of the merchant addresses and the
+// transactions of their nonces.
+type NonceRepository interface {
+	// GetNextNonce - get the next nonce handed out for the address.
+	// @param ctx - context
+	// @param chainId - id of the chain
+	// @param address - merchant address
+	// @return next nonce
+	// @return error - ErrNotFound when the address has no nonce yet
+	GetNextNonce(ctx context.Context, chainId uint64, address string) (uint64, error)
+	// InitNonce - start the nonces of the address at the next nonce.
+	// @param ctx - context
+	// @param chainId - id of the chain
+	// @param address - merchant address
+	// @param next - next nonce
+	// @return error - a failed condition when the address has a nonce
+	InitNonce(ctx context.Context, chainId uint64, address string, next uint64) error
+	// ReserveNonces - hand out the count nonces from the next nonce and
+	// insert them reserved at once.
+	// @param ctx - context
+	// @param chainId - id of the chain
+	// @param address - merchant address
+	// @param next - the next nonce as it was read
+	// @param count - count of the nonces
+	// @param now - time of the reservation
+	// @return error - a failed condition when the next nonce moved
+	ReserveNonces(ctx context.Context, chainId uint64, address string, next, count uint64, now int64) error
+	// AdvanceNonce - move the next nonce forward, it never moves back.
+	// @param ctx - context
+	// @param chainId - id of the chain
+	// @param address - merchant address
+	// @param next - next nonce
+	// @return error
+	AdvanceNonce(ctx context.Context, chainId uint64, address string, next uint64) error
+	// GetNonceTx - get the transaction of the nonce.
+	// @param ctx - context
+	// @param chainId - id of the chain
+	// @param address - merchant address
+	// @param nonce - nonce
+	// @return transaction
+	// @return error - ErrNotFound when the nonce is not reserved
+	GetNonceTx(ctx context.Context, chainId uint64, address string, nonce uint64) (*protos.NonceTx, error)
+	// UpdateNonceTx - update the fields of the update mask of the transaction.
+	// @param ctx - context
+	// @param tx - the new values
+	// @param updateMask - snake case names of the fields
+	// @return error - a failed condition when the nonce is not reserved
+	UpdateNonceTx(ctx context.Context, tx protos.NonceTx, updateMask []string) error
+	// GetUnminedNonceTxs - get the transactions of the address which are not
+	// known to be mined.
+	// @param ctx - context
+	// @param chainId - id of the chain
+	// @param address - merchant address
+	// @return transactions by nonce
+	// @return error
+	GetUnminedNonceTxs(ctx context.Context, chainId uint64, address string) ([]protos.NonceTx, error)
+}
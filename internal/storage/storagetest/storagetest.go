@@ -0,0 +1,617 @@
+// Package storagetest - the conformance tests of the repositories of the
+// storage, every implementation runs them to behave the same.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/internal/storage"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/google/uuid"
+)
+
+// TestUserRepository - the users are created once and updated by the mask.
+func TestUserRepository(t *testing.T, repo storage.UserRepository) {
+	ctx := context.Background()
+	address := "0x" + uuid.NewString()
+
+	if missing, err := repo.GetUser(ctx, address); !errors.Is(err, storage.ErrNotFound) || missing == nil || missing.PublicAddress != "" {
+		t.Fatalf("expected the missing user to be empty and not found, got %v", err)
+	}
+	if _, err := repo.UpdateUser(ctx, address, protos.User{Name: "alice"}, []string{"name"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the update of the missing user to fail the condition, got %v", err)
+	}
+
+	user := protos.User{
+		PublicAddress: address,
+		Name:          "alice",
+		Email:         "alice@example.com",
+		Addresses:     map[string]protos.Address{"home": {Title: "home", StreetAddress: "1 Main St", PostalCode: 100, CountryCode: "TW"}},
+		CreatedAt:     time.Now().Unix(),
+		UpdatedAt:     time.Now().Unix(),
+	}
+	if err := repo.PutUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PutUser(ctx, user); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second put to fail the condition, got %v", err)
+	}
+
+	got, err := repo.GetUser(ctx, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PublicAddress != address || got.Name != "alice" || got.Addresses["home"].StreetAddress != "1 Main St" || got.Version != 1 {
+		t.Fatalf("unexpected user %+v", got)
+	}
+
+	for _, mask := range [][]string{{"created_at"}, {"public_address"}, {"version"}, {"nickname"}, {"addresses.office.postal_code"}, {"addresses", "addresses.home"}, {}} {
+		if _, err := repo.UpdateUser(ctx, address, user, mask); !errors.Is(err, storage.ErrInvalidUpdateMask) {
+			t.Fatalf("expected the mask %v to be invalid, got %v", mask, err)
+		}
+	}
+
+	updated, err := repo.UpdateUser(ctx, address, protos.User{Name: "bob", Email: "bob@example.com", Version: 1}, []string{"name", "updated_at"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "bob" || updated.Email != "alice@example.com" || updated.Version != 2 {
+		t.Fatalf("only the name is expected to be updated, got %+v", updated)
+	}
+	if _, err := repo.UpdateUser(ctx, address, protos.User{Name: "carol", Version: 1}, []string{"name"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the update at a stale version to fail the condition, got %v", err)
+	}
+	if updated, err = repo.UpdateUser(ctx, address, protos.User{Name: "carol"}, []string{"name"}); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "carol" || updated.Version != 3 {
+		t.Fatalf("expected the update at any version, got %+v", updated)
+	}
+
+	home := protos.User{Addresses: map[string]protos.Address{"home": {Title: "new home", PostalCode: 200}}}
+	if updated, err = repo.UpdateUser(ctx, address, home, []string{"addresses.home.postal_code", "addresses.home.title"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := updated.Addresses["home"]; got.PostalCode != 200 || got.Title != "new home" || got.StreetAddress != "1 Main St" {
+		t.Fatalf("only the postal code and the title are expected to be updated, got %+v", got)
+	}
+	if updated.UpdatedAt < user.UpdatedAt {
+		t.Fatalf("updated_at is not stamped, got %d", updated.UpdatedAt)
+	}
+}
+
+// TestProductRepository - the products are created once and updated by the
+// mask.
+func TestProductRepository(t *testing.T, repo storage.ProductRepository) {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	if missing, err := repo.GetProduct(ctx, id); !errors.Is(err, storage.ErrNotFound) || missing == nil || missing.Id != "" {
+		t.Fatalf("expected the missing product to be empty and not found, got %v", err)
+	}
+	if _, err := repo.UpdateProduct(ctx, id, protos.Product{Price: 1}, []string{"price"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the update of the missing product to fail the condition, got %v", err)
+	}
+
+	product := protos.Product{
+		Id:        id,
+		Name:      "coffee",
+		Price:     4.5,
+		Currency:  "USD",
+		CreatedAt: time.Now().Unix(),
+		UpdatedAt: time.Now().Unix(),
+	}
+	if err := repo.PutProduct(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PutProduct(ctx, product); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second put to fail the condition, got %v", err)
+	}
+
+	got, err := repo.GetProduct(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != id || got.Name != "coffee" || got.Price != 4.5 || got.Version != 1 {
+		t.Fatalf("unexpected product %+v", got)
+	}
+
+	updated, err := repo.UpdateProduct(ctx, id, protos.Product{Name: "tea", Price: 3, Version: 1}, []string{"price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Price != 3 || updated.Name != "coffee" || updated.Version != 2 {
+		t.Fatalf("only the price is expected to be updated, got %+v", updated)
+	}
+	if _, err := repo.UpdateProduct(ctx, id, protos.Product{Price: 2, Version: 1}, []string{"price"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the update at a stale version to fail the condition, got %v", err)
+	}
+
+	products, err := repo.GetProducts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range products {
+		found = found || p.Id == id
+	}
+	if !found {
+		t.Fatalf("product %s is not listed", id)
+	}
+}
+
+// TestOrderRepository - the orders, the payments through the outbox, the
+// idempotency keys and the counters.
+func TestOrderRepository(t *testing.T, repo storage.OrderRepository) {
+	ctx := context.Background()
+	address := "0x" + uuid.NewString()
+	id := uuid.NewString()
+
+	if missing, err := repo.GetOrder(ctx, address, id); !errors.Is(err, storage.ErrNotFound) || missing == nil || missing.Id != "" {
+		t.Fatalf("expected the missing order to be empty and not found, got %v", err)
+	}
+	if _, err := repo.UpdateOrder(ctx, address, id, protos.Order{Status: protos.StatusPending}, []string{"status"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the update of the missing order to fail the condition, got %v", err)
+	}
+
+	order := protos.Order{
+		Id:         id,
+		From:       address,
+		ProductIds: []protos.OrderProducts{{Id: uuid.NewString(), Price: 2, Quantity: 3}},
+		Address:    "1 Main St",
+		Amount:     6,
+		Status:     protos.StatusCreated,
+		CreatedAt:  time.Now().Unix(),
+		UpdatedAt:  time.Now().Unix(),
+	}
+	if err := repo.PutOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PutOrder(ctx, order); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second put to fail the condition, got %v", err)
+	}
+	byStatus := func(statuses ...protos.Status) *protos.Order {
+		t.Helper()
+		orders, err := repo.GetOrdersByStatus(ctx, statuses...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, order := range orders {
+			if order.From == address && order.Id == id {
+				return &order
+			}
+		}
+		return nil
+	}
+	if got := byStatus(protos.StatusPending, protos.StatusCreated); got == nil || got.Amount != 6 {
+		t.Fatalf("expected the created order by its status, got %+v", got)
+	}
+	if got := byStatus(protos.StatusPaid); got != nil {
+		t.Fatalf("expected the created order not to be paid, got %+v", got)
+	}
+	if got := byStatus(); got != nil {
+		t.Fatalf("expected no order without a status, got %+v", got)
+	}
+
+	got, err := repo.GetOrder(ctx, address, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != id || got.From != address || got.Amount != 6 || len(got.ProductIds) != 1 || got.ProductIds[0].Quantity != 3 || got.Version != 1 {
+		t.Fatalf("unexpected order %+v", got)
+	}
+
+	list, err := repo.GetUserOrders(ctx, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Id != id || list[0].Status != protos.StatusCreated {
+		t.Fatalf("unexpected orders %+v", list)
+	}
+
+	updated, err := repo.UpdateOrder(ctx, address, id, protos.Order{Address: "2 Main St", Amount: 1, Version: 1}, []string{"address"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Address != "2 Main St" || updated.Amount != 6 || updated.Version != 2 {
+		t.Fatalf("only the address is expected to be updated, got %+v", updated)
+	}
+	if _, err := repo.UpdateOrder(ctx, address, id, protos.Order{Address: "3 Main St", Version: 1}, []string{"address"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the update at a stale version to fail the condition, got %v", err)
+	}
+	for _, mask := range [][]string{{"created_at"}, {"from"}, {"id"}, {"product_ids"}, {"deposit_address"}} {
+		if _, err := repo.UpdateOrder(ctx, address, id, order, mask); !errors.Is(err, storage.ErrInvalidUpdateMask) {
+			t.Fatalf("expected the mask %v to be invalid, got %v", mask, err)
+		}
+	}
+
+	testPayment(t, repo, address, id)
+	testRefund(t, repo, address)
+	testIdempotency(t, repo, address, id)
+
+	name := uuid.NewString()
+	for want := uint64(1); want <= 3; want++ {
+		value, err := repo.NextCounter(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != want {
+			t.Fatalf("counter is %d, not %d", value, want)
+		}
+	}
+}
+
+// testPayment - the created order is paid once, the payment is cancelled
+// while its message is pending.
+func testPayment(t *testing.T, repo storage.OrderRepository, address, id string) {
+	ctx := context.Background()
+	now := time.Now().Unix()
+	outbox := protos.Outbox{
+		Id:        uuid.NewString(),
+		Message:   &protos.CreateMonitorRequest{OrderId: id, From: address},
+		Status:    protos.OutboxPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	pending := protos.Order{Status: protos.StatusPending, PaymentHash: "0x01", UpdatedAt: now}
+	mask := []string{"status", "payment_hash", "updated_at"}
+
+	if err := repo.PayOrder(ctx, address, uuid.NewString(), pending, mask, outbox); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the payment of the missing order to fail the condition, got %v", err)
+	}
+	if err := repo.PayOrder(ctx, address, id, pending, mask, outbox); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetOrder(ctx, address, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != protos.StatusPending || got.PaymentHash != "0x01" {
+		t.Fatalf("unexpected order %+v", got)
+	}
+
+	// the order is pending, it cannot be paid again
+	paid := outbox
+	outbox.Id = uuid.NewString()
+	if err := repo.PayOrder(ctx, address, id, pending, mask, outbox); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second payment to fail the condition, got %v", err)
+	}
+
+	failed := protos.Order{Status: protos.StatusPaidFailed, PaymentHash: "0x02", UpdatedAt: now}
+	if err := repo.CancelPayment(ctx, address, id, failed, []string{"status", "updated_at"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the cancel at another payment hash to fail the condition, got %v", err)
+	}
+	if got, err = repo.GetOrder(ctx, address, id); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != protos.StatusPending {
+		t.Fatalf("the failed cancel updated the order to %v", got.Status)
+	}
+
+	testOutbox(t, repo, address, paid)
+
+	// the message is not pending anymore, the payment is cancelled anyway
+	failed.PaymentHash = "0x01"
+	if err := repo.CancelPayment(ctx, address, id, failed, []string{"status", "updated_at"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = repo.GetOrder(ctx, address, id); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != protos.StatusPaidFailed {
+		t.Fatalf("expected the payment cancelled, got %v", got.Status)
+	}
+	if err := repo.CancelPayment(ctx, address, id, failed, []string{"status", "updated_at"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the cancelled payment not to be cancelled again, got %v", err)
+	}
+	if err := repo.CancelOutbox(ctx, paid.Id); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the dead message not to be cancelled, got %v", err)
+	}
+}
+
+// testRefund - one refund of the paid order is pending at a time, a failed
+// refund restores the order and cancels its message.
+func testRefund(t *testing.T, repo storage.OrderRepository, address string) {
+	ctx := context.Background()
+	now := time.Now().Unix()
+	id := uuid.NewString()
+	order := protos.Order{Id: id, From: address, Amount: 2, Status: protos.StatusPaid, CreatedAt: now, UpdatedAt: now}
+	if err := repo.PutOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	refund := protos.Refund{
+		Id:        uuid.NewString(),
+		From:      address,
+		OrderId:   id,
+		To:        address,
+		Amount:    1,
+		TxHash:    "0x01",
+		Status:    protos.RefundPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	outbox := protos.Outbox{
+		Id:        uuid.NewString(),
+		Message:   &protos.CreateMonitorRequest{OrderId: id, From: address},
+		Status:    protos.OutboxPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	mask := []string{"status", "updated_at"}
+	order.Status, order.Version = protos.StatusRefundPending, 1
+	if err := repo.RefundOrder(ctx, order, protos.StatusPaid, mask, refund, outbox); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetOrder(ctx, address, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != protos.StatusRefundPending || got.Version != 2 {
+		t.Fatalf("unexpected order %+v", got)
+	}
+
+	// the order is refund_pending, another refund cannot start
+	second := refund
+	second.Id = uuid.NewString()
+	next := outbox
+	next.Id = uuid.NewString()
+	order.Version = 0
+	if err := repo.RefundOrder(ctx, order, protos.StatusPaid, mask, second, next); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second refund to fail the condition, got %v", err)
+	}
+
+	refunds, err := repo.GetRefunds(ctx, address, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].Id != refund.Id || refunds[0].From != address || refunds[0].Status != protos.RefundPending {
+		t.Fatalf("unexpected refunds %+v", refunds)
+	}
+
+	order.Status = protos.StatusPaid
+	if err := repo.FailRefund(ctx, order, mask, uuid.NewString()); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the fail of an unknown refund to fail the condition, got %v", err)
+	}
+	// the relay published the message first, the refund is failed anyway
+	if err := repo.MarkOutboxSent(ctx, outbox.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.FailRefund(ctx, order, mask, refund.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = repo.GetOrder(ctx, address, id); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != protos.StatusPaid {
+		t.Fatalf("expected the order restored, got %v", got.Status)
+	}
+	if refunds, err = repo.GetRefunds(ctx, address, id); err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].Status != protos.RefundFailed {
+		t.Fatalf("unexpected refunds %+v", refunds)
+	}
+	if err := repo.FailRefund(ctx, order, mask, refund.Id); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the restored order not to be restored again, got %v", err)
+	}
+}
+
+// testOutbox - the pending message is published once, or it is dead after
+// it failed max attempts times.
+func testOutbox(t *testing.T, repo storage.OrderRepository, address string, failing protos.Outbox) {
+	ctx := context.Background()
+	now := time.Now().Unix()
+	pending := func(id string) *protos.Outbox {
+		t.Helper()
+		items, err := repo.GetPendingOutbox(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			if item.Id == id {
+				return &item
+			}
+		}
+		return nil
+	}
+
+	if got := pending(failing.Id); got == nil || got.Attempts != 0 || got.Message == nil || got.Message.OrderId != failing.Message.OrderId {
+		t.Fatalf("unexpected pending message %+v", got)
+	}
+	if err := repo.MarkOutboxFailed(ctx, failing, "timeout", 2); err != nil {
+		t.Fatal(err)
+	}
+	// the failure is recorded at the attempts the message was read with
+	if err := repo.MarkOutboxFailed(ctx, failing, "timeout", 2); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the failure at stale attempts to fail the condition, got %v", err)
+	}
+	got := pending(failing.Id)
+	if got == nil || got.Attempts != 1 || got.LastError != "timeout" {
+		t.Fatalf("unexpected failed message %+v", got)
+	}
+	if err := repo.MarkOutboxFailed(ctx, *got, "timeout", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := pending(failing.Id); got != nil {
+		t.Fatalf("expected the dead message not to be pending, got %+v", got)
+	}
+	if err := repo.MarkOutboxSent(ctx, failing.Id); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the dead message not to be sent, got %v", err)
+	}
+
+	id := uuid.NewString()
+	order := protos.Order{Id: id, From: address, Status: protos.StatusCreated, CreatedAt: now, UpdatedAt: now}
+	if err := repo.PutOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	outbox := protos.Outbox{
+		Id:        uuid.NewString(),
+		Message:   &protos.CreateMonitorRequest{OrderId: id, From: address},
+		Status:    protos.OutboxPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.PayOrder(ctx, address, id, protos.Order{Status: protos.StatusPending}, []string{"status"}, outbox); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkOutboxSent(ctx, outbox.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := pending(outbox.Id); got != nil {
+		t.Fatalf("expected the sent message not to be pending, got %+v", got)
+	}
+	if err := repo.MarkOutboxSent(ctx, outbox.Id); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second send to fail the condition, got %v", err)
+	}
+
+	// a cancelled message is never published
+	order.Id = uuid.NewString()
+	if err := repo.PutOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	outbox.Id = uuid.NewString()
+	if err := repo.PayOrder(ctx, address, order.Id, protos.Order{Status: protos.StatusPending}, []string{"status"}, outbox); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CancelOutbox(ctx, outbox.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := pending(outbox.Id); got != nil {
+		t.Fatalf("expected the cancelled message not to be pending, got %+v", got)
+	}
+	if err := repo.MarkOutboxSent(ctx, outbox.Id); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the cancelled message not to be sent, got %v", err)
+	}
+}
+
+// testIdempotency - the key is claimed once until it expires, completed with
+// the response and released.
+func testIdempotency(t *testing.T, repo storage.OrderRepository, address, id string) {
+	ctx := context.Background()
+	now := time.Now().Unix()
+	key := uuid.NewString()
+
+	if _, err := repo.GetIdempotency(ctx, address, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the missing key not to be found, got %v", err)
+	}
+	if err := repo.CompleteIdempotency(ctx, address, key, "{}"); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the completion of the missing key to fail the condition, got %v", err)
+	}
+
+	claim := protos.Idempotency{
+		Key:           key,
+		PublicAddress: address,
+		OrderId:       id,
+		Status:        protos.IdempotencyInProgress,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		ExpireAt:      now + 3600,
+	}
+	if err := repo.PutIdempotency(ctx, claim); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PutIdempotency(ctx, claim); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second claim to fail the condition, got %v", err)
+	}
+	if err := repo.CompleteIdempotency(ctx, address, key, `{"id":"1"}`); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetIdempotency(ctx, address, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Key != key || got.PublicAddress != address || got.OrderId != id ||
+		got.Status != protos.IdempotencyCompleted || got.Response != `{"id":"1"}` {
+		t.Fatalf("unexpected claim %+v", got)
+	}
+
+	if err := repo.DeleteIdempotency(ctx, address, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetIdempotency(ctx, address, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the released key not to be found, got %v", err)
+	}
+
+	// an expired key is claimed again
+	claim.Key = uuid.NewString()
+	claim.ExpireAt = now - 1
+	if err := repo.PutIdempotency(ctx, claim); err != nil {
+		t.Fatal(err)
+	}
+	claim.ExpireAt = now + 3600
+	if err := repo.PutIdempotency(ctx, claim); err != nil {
+		t.Fatalf("expected the expired key to be claimed, got %v", err)
+	}
+}
+
+// TestNonceRepository - the nonces are started once, reserved from the next
+// nonce as it was read and their transactions are updated by the mask.
+func TestNonceRepository(t *testing.T, repo storage.NonceRepository) {
+	ctx := context.Background()
+	chainId, address := uint64(11155111), "0x"+uuid.NewString()
+
+	if _, err := repo.GetNextNonce(ctx, chainId, address); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the missing nonce not to be found, got %v", err)
+	}
+	if err := repo.InitNonce(ctx, chainId, address, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.InitNonce(ctx, chainId, address, 7); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the second init to fail the condition, got %v", err)
+	}
+
+	if err := repo.ReserveNonces(ctx, chainId, address, 5, 2, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ReserveNonces(ctx, chainId, address, 5, 1, time.Now().Unix()); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the reserve of a stale next nonce to fail the condition, got %v", err)
+	}
+	if next, err := repo.GetNextNonce(ctx, chainId, address); err != nil || next != 7 {
+		t.Fatalf("next nonce is %d %v, not 7", next, err)
+	}
+
+	tx, err := repo.GetNonceTx(ctx, chainId, address, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Nonce != 6 || tx.Status != protos.NonceReserved {
+		t.Fatalf("unexpected transaction %+v", tx)
+	}
+	if _, err := repo.GetNonceTx(ctx, chainId, address, 7); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the nonce which is not reserved not to be found, got %v", err)
+	}
+	missing := protos.NonceTx{ChainId: chainId, Address: address, Nonce: 7, Status: protos.NonceFailed}
+	if err := repo.UpdateNonceTx(ctx, missing, []string{"status"}); !storage.IsConditionalCheckFailed(err) {
+		t.Fatalf("expected the update of the nonce which is not reserved to fail the condition, got %v", err)
+	}
+
+	tx.TxHash, tx.Status, tx.Replaced = "0x01", protos.NoncePending, []string{"0x00"}
+	if err := repo.UpdateNonceTx(ctx, *tx, []string{"tx_hash", "status", "replaced", "updated_at"}); err != nil {
+		t.Fatal(err)
+	}
+	mined := protos.NonceTx{ChainId: chainId, Address: address, Nonce: 5, Status: protos.NonceMined}
+	if err := repo.UpdateNonceTx(ctx, mined, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	unmined, err := repo.GetUnminedNonceTxs(ctx, chainId, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unmined) != 1 || unmined[0].Nonce != 6 || unmined[0].TxHash != "0x01" || len(unmined[0].Replaced) != 1 {
+		t.Fatalf("unexpected unmined transactions %+v", unmined)
+	}
+
+	// the next nonce only moves forward
+	if err := repo.AdvanceNonce(ctx, chainId, address, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AdvanceNonce(ctx, chainId, address, 9); err != nil {
+		t.Fatal(err)
+	}
+	if next, err := repo.GetNextNonce(ctx, chainId, address); err != nil || next != 9 {
+		t.Fatalf("next nonce is %d %v, not 9", next, err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package chaintest - a simulated chain for the tests of the services which
+// depend on chain.Client, it runs in process and mines a block on Commit.
+//
+// The backend links github.com/fjl/memsize through go-ethereum, which go
+// 1.23 and later refuse to link by default, so the tests using it carry the
+// simulated build tag and run with make test.
+package chaintest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Funds - the ether of each account in the genesis.
+var Funds = new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))
+
+// Backend - a simulated chain, the accounts are funded in the genesis.
+type Backend struct {
+	*simulated.Backend
+	Client   chain.Client
+	ChainId  *big.Int
+	Accounts []*ecdsa.PrivateKey
+}
+
+// NewBackend - start a chain with the count funded accounts, it is closed
+// with the test.
+func NewBackend(t testing.TB, count int) *Backend {
+	t.Helper()
+	alloc := make(types.GenesisAlloc, count)
+	accounts := make([]*ecdsa.PrivateKey, count)
+	for i := range accounts {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		accounts[i] = key
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = types.Account{Balance: Funds}
+	}
+	sim := simulated.NewBackend(alloc)
+	t.Cleanup(func() { sim.Close() })
+
+	b := &Backend{Backend: sim, Client: sim.Client(), Accounts: accounts}
+	chainId, err := b.Client.ChainID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ChainId = chainId
+	return b
+}
+
+// Address - the address of the account.
+func (b *Backend) Address(i int) common.Address {
+	return crypto.PubkeyToAddress(b.Accounts[i].PublicKey)
+}
+
+// Nonce - the pending nonce of the account.
+func (b *Backend) Nonce(t testing.TB, account int) uint64 {
+	t.Helper()
+	nonce, err := b.Client.PendingNonceAt(context.Background(), b.Address(account))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nonce
+}
+
+// Deploy - deploy the token from the account and mine it, the supply is
+// minted to the account.
+func (b *Backend) Deploy(t testing.TB, account int, token Token) common.Address {
+	t.Helper()
+	code, err := token.Code()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := b.Send(t, account, nil, nil, code)
+	receipt := b.Mine(t, tx)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("deploy %s failed", token.Symbol)
+	}
+	return receipt.ContractAddress
+}
+
+// Send - sign and send the transaction of the account with the suggested
+// fees, it is pending until the next block.
+func (b *Backend) Send(t testing.TB, account int, to *common.Address, value *big.Int, data []byte) *types.Transaction {
+	t.Helper()
+	ctx := context.Background()
+	tip, err := b.Client.SuggestGasTipCap(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := b.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	tx, err := types.SignNewTx(b.Accounts[account], types.LatestSignerForChainID(b.ChainId), &types.DynamicFeeTx{
+		ChainID:   b.ChainId,
+		Nonce:     b.Nonce(t, account),
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       1_000_000,
+		To:        to,
+		Value:     value,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// Mine - mine the pending transactions and return the receipt of the
+// transaction.
+func (b *Backend) Mine(t testing.TB, tx *types.Transaction) *types.Receipt {
+	t.Helper()
+	b.Commit()
+	receipt, err := b.Receipt(tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return receipt
+}
+
+// Receipt - the receipt of the mined transaction.
+func (b *Backend) Receipt(hash common.Hash) (*types.Receipt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		receipt, err := b.Client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return receipt, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(fmt.Errorf("receipt of %s not found", hash), err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package chaintest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20/binding"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Token - a mock ERC-20 token, the supply is minted to the deployer.
+//
+// The contract is assembled from the opcodes, so no compiler is needed. The
+// balances are stored at the slot of the address, the allowances at the slot
+// of keccak256(owner, spender) and the total supply at the slot 0. Transfers
+// and approvals emit the events of the ERC-20 abi.
+type Token struct {
+	Name     string
+	Symbol   string
+	Decimals uint8
+	Supply   *big.Int
+}
+
+// Code - the creation code of the token.
+func (t Token) Code() ([]byte, error) {
+	tokenABI, err := binding.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	name, err := tokenABI.Methods["name"].Outputs.Pack(t.Name)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := tokenABI.Methods["symbol"].Outputs.Pack(t.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	transferTopic := tokenABI.Events["Transfer"].ID
+	approvalTopic := tokenABI.Events["Approval"].ID
+
+	// the arguments of the calls
+	arg := func(i uint64) func(*program) {
+		return func(p *program) { p.pushInt(4 + 32*i); p.op(vm.CALLDATALOAD) }
+	}
+	caller := func(p *program) { p.op(vm.CALLER) }
+
+	r := newProgram()
+	r.pushInt(4)
+	r.op(vm.CALLDATASIZE, vm.LT)
+	r.jumpi("revert")
+	r.pushInt(0)
+	r.op(vm.CALLDATALOAD)
+	r.pushInt(224)
+	r.op(vm.SHR)
+	for _, name := range []string{"name", "symbol", "decimals", "totalSupply", "balanceOf", "allowance", "transfer", "approve", "transferFrom"} {
+		r.op(vm.DUP1)
+		r.push(tokenABI.Methods[name].ID)
+		r.op(vm.EQ)
+		r.jumpi(name)
+	}
+	r.jump("revert")
+
+	r.dest("revert")
+	r.pushInt(0)
+	r.op(vm.DUP1, vm.REVERT)
+
+	r.dest("name")
+	r.returnData("name.data", len(name))
+	r.dest("symbol")
+	r.returnData("symbol.data", len(symbol))
+
+	r.dest("decimals")
+	r.pushInt(uint64(t.Decimals))
+	r.returnWord()
+
+	r.dest("totalSupply")
+	r.pushInt(0)
+	r.op(vm.SLOAD)
+	r.returnWord()
+
+	r.dest("balanceOf")
+	arg(0)(r)
+	r.op(vm.SLOAD)
+	r.returnWord()
+
+	r.dest("allowance")
+	r.pushInt(64)
+	r.pushInt(4)
+	r.pushInt(0)
+	r.op(vm.CALLDATACOPY)
+	r.pushInt(64)
+	r.pushInt(0)
+	r.op(vm.KECCAK256, vm.SLOAD)
+	r.returnWord()
+
+	r.dest("transfer")
+	r.transfer(caller, arg(0), arg(1), transferTopic)
+	r.pushInt(1)
+	r.returnWord()
+
+	r.dest("approve")
+	r.allowanceSlot(caller, arg(0))
+	arg(1)(r)
+	r.op(vm.SWAP1, vm.SSTORE)
+	r.log(arg(1), caller, arg(0), approvalTopic)
+	r.pushInt(1)
+	r.returnWord()
+
+	r.dest("transferFrom")
+	// the allowance of the caller must cover the amount
+	arg(2)(r)
+	r.allowanceSlot(arg(0), caller)
+	r.op(vm.SLOAD, vm.LT)
+	r.jumpi("revert")
+	arg(2)(r)
+	r.allowanceSlot(arg(0), caller)
+	r.op(vm.SLOAD, vm.SUB)
+	r.allowanceSlot(arg(0), caller)
+	r.op(vm.SSTORE)
+	r.transfer(arg(0), arg(1), arg(2), transferTopic)
+	r.pushInt(1)
+	r.returnWord()
+
+	r.data("name.data", name)
+	r.data("symbol.data", symbol)
+	runtime, err := r.assemble()
+	if err != nil {
+		return nil, err
+	}
+
+	supply := func(p *program) { p.push(common.BigToHash(t.Supply).Bytes()) }
+	i := newProgram()
+	supply(i)
+	i.op(vm.DUP1, vm.CALLER, vm.SSTORE)
+	i.pushInt(0)
+	i.op(vm.SSTORE)
+	i.log(supply, func(p *program) { p.pushInt(0) }, caller, transferTopic)
+	i.pushInt(uint64(len(runtime)))
+	i.pushLabel("runtime")
+	i.pushInt(0)
+	i.op(vm.CODECOPY)
+	i.pushInt(uint64(len(runtime)))
+	i.pushInt(0)
+	i.op(vm.RETURN)
+	i.data("runtime", runtime)
+	return i.assemble()
+}
+
+// program - the opcodes of a contract, the jumps and the data are resolved
+// by label when it is assembled.
+type program struct {
+	code   []byte
+	labels map[string]int
+	refs   map[int]string
+}
+
+func newProgram() *program {
+	return &program{labels: make(map[string]int), refs: make(map[int]string)}
+}
+
+func (p *program) op(ops ...vm.OpCode) {
+	for _, op := range ops {
+		p.code = append(p.code, byte(op))
+	}
+}
+
+// push - push the value, it is at most 32 bytes.
+func (p *program) push(val []byte) {
+	if len(val) == 0 {
+		val = []byte{0}
+	}
+	p.op(vm.PUSH1 + vm.OpCode(len(val)-1))
+	p.code = append(p.code, val...)
+}
+
+func (p *program) pushInt(val uint64) {
+	p.push(new(big.Int).SetUint64(val).Bytes())
+}
+
+// pushLabel - push the position of the label.
+func (p *program) pushLabel(label string) {
+	p.op(vm.PUSH2)
+	p.refs[len(p.code)] = label
+	p.code = append(p.code, 0, 0)
+}
+
+func (p *program) jump(label string) {
+	p.pushLabel(label)
+	p.op(vm.JUMP)
+}
+
+func (p *program) jumpi(label string) {
+	p.pushLabel(label)
+	p.op(vm.JUMPI)
+}
+
+// dest - the jump destination of the label.
+func (p *program) dest(label string) {
+	p.labels[label] = len(p.code)
+	p.op(vm.JUMPDEST)
+}
+
+// data - the bytes of the label, they are appended after the opcodes.
+func (p *program) data(label string, data []byte) {
+	p.labels[label] = len(p.code)
+	p.code = append(p.code, data...)
+}
+
+// returnWord - return the word on the top of the stack.
+func (p *program) returnWord() {
+	p.pushInt(0)
+	p.op(vm.MSTORE)
+	p.pushInt(32)
+	p.pushInt(0)
+	p.op(vm.RETURN)
+}
+
+// returnData - return the bytes of the label.
+func (p *program) returnData(label string, size int) {
+	p.pushInt(uint64(size))
+	p.pushLabel(label)
+	p.pushInt(0)
+	p.op(vm.CODECOPY)
+	p.pushInt(uint64(size))
+	p.pushInt(0)
+	p.op(vm.RETURN)
+}
+
+// allowanceSlot - push keccak256(owner, spender).
+func (p *program) allowanceSlot(owner, spender func(*program)) {
+	owner(p)
+	p.pushInt(0)
+	p.op(vm.MSTORE)
+	spender(p)
+	p.pushInt(32)
+	p.op(vm.MSTORE)
+	p.pushInt(64)
+	p.pushInt(0)
+	p.op(vm.KECCAK256)
+}
+
+// transfer - move the amount from the balance of from to the balance of to
+// and emit the Transfer event, it reverts when the balance is short.
+func (p *program) transfer(from, to, amount func(*program), topic common.Hash) {
+	amount(p)
+	from(p)
+	p.op(vm.SLOAD, vm.LT)
+	p.jumpi("revert")
+	amount(p)
+	from(p)
+	p.op(vm.SLOAD, vm.SUB)
+	from(p)
+	p.op(vm.SSTORE)
+	amount(p)
+	to(p)
+	p.op(vm.SLOAD, vm.ADD)
+	to(p)
+	p.op(vm.SSTORE)
+	p.log(amount, from, to, topic)
+}
+
+// log - emit the event of the topic with the two indexed addresses and the
+// value in the data.
+func (p *program) log(value, first, second func(*program), topic common.Hash) {
+	value(p)
+	p.pushInt(0)
+	p.op(vm.MSTORE)
+	second(p)
+	first(p)
+	p.push(topic.Bytes())
+	p.pushInt(32)
+	p.pushInt(0)
+	p.op(vm.LOG3)
+}
+
+// assemble - the code with the labels resolved.
+func (p *program) assemble() ([]byte, error) {
+	code := make([]byte, len(p.code))
+	copy(code, p.code)
+	for pos, label := range p.refs {
+		target, ok := p.labels[label]
+		if !ok {
+			return nil, fmt.Errorf("label %s not found", label)
+		}
+		if target > 0xffff {
+			return nil, fmt.Errorf("label %s is out of range", label)
+		}
+		binary.BigEndian.PutUint16(code[pos:], uint16(target))
+	}
+	return code, nil
+}
@@ -0,0 +1,24 @@
+package chain
+
+import (
+	"github.com/ethereum/go-ethereum"
+)
+
+// Client - the ethereum client used by the services, it is implemented by
+// *ethclient.Client and by the client of the simulated backend.
+type Client interface {
+	ethereum.BlockNumberReader
+	ethereum.ChainReader
+	ethereum.ChainStateReader
+	ethereum.ContractCaller
+	ethereum.GasEstimator
+	ethereum.GasPricer
+	ethereum.GasPricer1559
+	ethereum.FeeHistoryReader
+	ethereum.LogFilterer
+	ethereum.PendingStateReader
+	ethereum.PendingContractCaller
+	ethereum.TransactionReader
+	ethereum.TransactionSender
+	ethereum.ChainIDReader
+}
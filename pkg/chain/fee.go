@@ -0,0 +1,19 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+)
+
+// BaseFee - the base fee of the latest block. The blocks before London and
+// the heads of some L2 rpcs have none, the suggested gas price is used then.
+func BaseFee(ctx context.Context, client Client) (*big.Int, error) {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if head.BaseFee != nil {
+		return head.BaseFee, nil
+	}
+	return client.SuggestGasPrice(ctx)
+}
@@ -0,0 +1,41 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// headClient - a chain whose head has the base fee, or none before London.
+type headClient struct {
+	Client
+	baseFee *big.Int
+}
+
+func (c *headClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: c.baseFee}, nil
+}
+
+func (c *headClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(5e9), nil
+}
+
+func TestBaseFee(t *testing.T) {
+	for _, tt := range []struct {
+		baseFee *big.Int
+		want    int64
+	}{
+		{baseFee: big.NewInt(1e9), want: 1e9},
+		{baseFee: nil, want: 5e9},
+	} {
+		got, err := BaseFee(context.Background(), &headClient{baseFee: tt.baseFee})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Int64() != tt.want {
+			t.Errorf("base fee %v is %s, want %d", tt.baseFee, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package chain
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
+)
+
+// rejections - the errors of a transaction which the node never accepts,
+// the rpc returns them by message only.
+var rejections = []error{
+	txpool.ErrInvalidSender,
+	core.ErrNonceTooLow,
+	core.ErrInsufficientFunds,
+	txpool.ErrUnderpriced,
+	txpool.ErrReplaceUnderpriced,
+}
+
+// IsRejected - the send failed definitively, so the transaction is not in
+// any pool. Other failures, e.g. a timeout, may follow the broadcast.
+func IsRejected(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, rejection := range rejections {
+		if errors.Is(err, rejection) || strings.Contains(err.Error(), rejection.Error()) {
+			return true
+		}
+	}
+	return false
+}
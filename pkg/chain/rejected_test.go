@@ -0,0 +1,31 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: fmt.Errorf("send: %w", core.ErrNonceTooLow), want: true},
+		{err: errors.New("nonce too low: address 0x01, tx: 1 state: 2"), want: true},
+		{err: errors.New("insufficient funds for gas * price + value: balance 0"), want: true},
+		{err: errors.New("replacement transaction underpriced"), want: true},
+		{err: errors.New("invalid sender"), want: true},
+		{err: context.DeadlineExceeded, want: false},
+		{err: errors.New("already known"), want: false},
+		{err: nil, want: false},
+	}
+	for _, tt := range tests {
+		if got := IsRejected(tt.err); got != tt.want {
+			t.Errorf("IsRejected(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
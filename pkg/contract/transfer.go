@@ -0,0 +1,84 @@
+package contract
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	EventTransfer  = "Transfer"
+	MethodTransfer = "transfer"
+)
+
+var (
+	ErrInvalidLog   = errors.New("invalid transfer log")
+	ErrInvalidInput = errors.New("invalid transfer input")
+)
+
+type Transfer struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+}
+
+// ParseTransferLog - decode an ERC-20 Transfer(from, to, value) log.
+func ParseTransferLog(contractABI abi.ABI, vLog types.Log) (*Transfer, error) {
+	event, ok := contractABI.Events[EventTransfer]
+	if !ok {
+		return nil, errors.Join(ErrInvalidLog, fmt.Errorf("event %s not found in abi", EventTransfer))
+	}
+	if len(vLog.Topics) != 3 || vLog.Topics[0] != event.ID {
+		return nil, errors.Join(ErrInvalidLog, fmt.Errorf("the event topics error"))
+	}
+	values, err := event.Inputs.NonIndexed().Unpack(vLog.Data)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidLog, err)
+	}
+	if len(values) != 1 {
+		return nil, errors.Join(ErrInvalidLog, fmt.Errorf("the event data error"))
+	}
+	value, ok := values[0].(*big.Int)
+	if !ok {
+		return nil, errors.Join(ErrInvalidLog, fmt.Errorf("the event value error"))
+	}
+	return &Transfer{
+		From:  common.BytesToAddress(vLog.Topics[1].Bytes()),
+		To:    common.BytesToAddress(vLog.Topics[2].Bytes()),
+		Value: value,
+	}, nil
+}
+
+// ParseTransferInput - decode the calldata of an ERC-20 transfer(to, value).
+func ParseTransferInput(contractABI abi.ABI, data []byte) (common.Address, *big.Int, error) {
+	if len(data) < 4 {
+		return common.Address{}, nil, errors.Join(ErrInvalidInput, fmt.Errorf("the input is too short"))
+	}
+	method, err := contractABI.MethodById(data[:4])
+	if err != nil {
+		return common.Address{}, nil, errors.Join(ErrInvalidInput, err)
+	}
+	if method.Name != MethodTransfer {
+		return common.Address{}, nil, errors.Join(ErrInvalidInput, fmt.Errorf("method %s is not %s", method.Name, MethodTransfer))
+	}
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return common.Address{}, nil, errors.Join(ErrInvalidInput, err)
+	}
+	if len(values) != 2 {
+		return common.Address{}, nil, errors.Join(ErrInvalidInput, fmt.Errorf("the input arguments error"))
+	}
+	to, ok := values[0].(common.Address)
+	if !ok {
+		return common.Address{}, nil, errors.Join(ErrInvalidInput, fmt.Errorf("the recipient error"))
+	}
+	value, ok := values[1].(*big.Int)
+	if !ok {
+		return common.Address{}, nil, errors.Join(ErrInvalidInput, fmt.Errorf("the value error"))
+	}
+	return to, value, nil
+}
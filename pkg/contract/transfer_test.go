@@ -0,0 +1,74 @@
+package contract
+
+import (
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestParseTransferLog(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	token, err := CreateContract("ERC20", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	to := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	value := ToWei(1.5, 6)
+
+	vLog := types.Log{
+		Address: token.Address,
+		Topics: []common.Hash{
+			token.ABI.Events[EventTransfer].ID,
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: common.LeftPadBytes(value.Bytes(), 32),
+	}
+	transfer, err := ParseTransferLog(token.ABI, vLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.From != from || transfer.To != to {
+		t.Fatalf("unexpected addresses %s -> %s", transfer.From.Hex(), transfer.To.Hex())
+	}
+	if transfer.Value.Cmp(big.NewInt(1_500_000)) != 0 {
+		t.Fatalf("unexpected value %s", transfer.Value)
+	}
+
+	vLog.Topics = vLog.Topics[:2]
+	if _, err := ParseTransferLog(token.ABI, vLog); err == nil {
+		t.Fatal("expected error for missing topic")
+	}
+}
+
+func TestParseTransferInput(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	token, err := CreateContract("ERC20", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	input, err := token.ABI.Pack(MethodTransfer, to, ToWei(2, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, value, err := ParseTransferInput(token.ABI, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipient != to || value.Cmp(big.NewInt(2_000_000)) != 0 {
+		t.Fatalf("unexpected transfer %s %s", recipient.Hex(), value)
+	}
+
+	approve, err := token.ABI.Pack("approve", to, ToWei(2, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseTransferInput(token.ABI, approve); err == nil {
+		t.Fatal("expected error for approve input")
+	}
+}
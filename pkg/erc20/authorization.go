@@ -0,0 +1,177 @@
+package erc20
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const (
+	TRANSFER_WITH_AUTHORIZATION = "transferWithAuthorization"
+	AUTHORIZATION_STATE         = "authorizationState"
+
+	EVENT_AUTHORIZATION_USED = "AuthorizationUsed"
+
+	// AUTHORIZATION_ABI - the EIP-3009 functions and events, which are not in
+	// the erc20 abi.
+	AUTHORIZATION_ABI = `[
+	{"inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"validAfter","type":"uint256"},{"name":"validBefore","type":"uint256"},{"name":"nonce","type":"bytes32"},{"name":"v","type":"uint8"},{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"}],"name":"transferWithAuthorization","outputs":[],"stateMutability":"nonpayable","type":"function"},
+	{"inputs":[{"name":"authorizer","type":"address"},{"name":"nonce","type":"bytes32"}],"name":"authorizationState","outputs":[{"name":"","type":"bool"}],"stateMutability":"view","type":"function"},
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"authorizer","type":"address"},{"indexed":true,"name":"nonce","type":"bytes32"}],"name":"AuthorizationUsed","type":"event"}
+]`
+)
+
+var authorizationABI abi.ABI
+
+func init() {
+	var err error
+	if authorizationABI, err = abi.JSON(strings.NewReader(AUTHORIZATION_ABI)); err != nil {
+		panic(err)
+	}
+}
+
+// AuthorizationUsedTopic - the topic of the AuthorizationUsed(authorizer, nonce) event.
+func AuthorizationUsedTopic() common.Hash {
+	return authorizationABI.Events[EVENT_AUTHORIZATION_USED].ID
+}
+
+// Authorization - an EIP-3009 transfer authorization, the value is in the
+// smallest unit of the token and the window is in unix time.
+type Authorization struct {
+	From        common.Address
+	To          common.Address
+	Value       *big.Int
+	ValidAfter  *big.Int
+	ValidBefore *big.Int
+	Nonce       common.Hash
+}
+
+// AuthorizationTypedData - the EIP-712 typed data of an EIP-3009 transfer authorization.
+func AuthorizationTypedData(domain Domain, chainId *big.Int, token common.Address, auth *Authorization) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainTypes,
+			"TransferWithAuthorization": {
+				{Name: "from", Type: "address"},
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "validAfter", Type: "uint256"},
+				{Name: "validBefore", Type: "uint256"},
+				{Name: "nonce", Type: "bytes32"},
+			},
+		},
+		PrimaryType: "TransferWithAuthorization",
+		Domain:      domain.typedDataDomain(chainId, token),
+		Message: apitypes.TypedDataMessage{
+			"from":        auth.From.Hex(),
+			"to":          auth.To.Hex(),
+			"value":       auth.Value.String(),
+			"validAfter":  auth.ValidAfter.String(),
+			"validBefore": auth.ValidBefore.String(),
+			"nonce":       auth.Nonce.Hex(),
+		},
+	}
+}
+
+func (s *service) PrepareAuthorization(domain Domain, from, to string, amount float64, validAfter, validBefore int64) (*apitypes.TypedData, error) {
+	if utils.IsEmpty(from) || !utils.IsValidAddress(from) {
+		return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("from address is invalid"))
+	}
+	if utils.IsEmpty(to) || !utils.IsValidAddress(to) {
+		return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("to address is invalid"))
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	// the nonces of EIP-3009 are random, not sequential
+	var nonce common.Hash
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	typedData := AuthorizationTypedData(domain, s.chainId, s.contract.Address, &Authorization{
+		From:        common.HexToAddress(from),
+		To:          common.HexToAddress(to),
+		Value:       contract.ToWei(amount, s.decimals),
+		ValidAfter:  big.NewInt(validAfter),
+		ValidBefore: big.NewInt(validBefore),
+		Nonce:       nonce,
+	})
+	return &typedData, nil
+}
+
+func (s *service) CheckAuthorization(ctx context.Context, domain Domain, auth *Authorization, to string, amount float64, signature []byte) ([]byte, error) {
+	if !utils.IsValidAddress(to) || auth.To != common.HexToAddress(to) {
+		return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("authorization is not sent to %s", to))
+	}
+	if value := contract.ToWei(amount, s.decimals); auth.Value == nil || auth.Value.Cmp(value) != 0 {
+		return nil, errors.Join(ErrInvalidAmount, fmt.Errorf("authorization value %s is not %s", auth.Value, value))
+	}
+	now := big.NewInt(time.Now().Unix())
+	if auth.ValidAfter == nil || auth.ValidBefore == nil || now.Cmp(auth.ValidAfter) <= 0 || now.Cmp(auth.ValidBefore) >= 0 {
+		return nil, errors.Join(ErrInvaildField, fmt.Errorf("authorization is not valid now"))
+	}
+
+	typedData := AuthorizationTypedData(domain, s.chainId, s.contract.Address, auth)
+	signer, v, r, sig, err := RecoverTypedData(typedData, signature)
+	if err != nil {
+		return nil, err
+	}
+	if signer != auth.From {
+		return nil, errors.Join(ErrInvalidSignature, fmt.Errorf("authorization is signed by %s", signer.Hex()))
+	}
+	used, err := s.AuthorizationState(ctx, auth.From.Hex(), auth.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, errors.Join(ErrInvalidNonce, fmt.Errorf("authorization %s is used", auth.Nonce.Hex()))
+	}
+
+	input, err := authorizationABI.Pack(TRANSFER_WITH_AUTHORIZATION, auth.From, auth.To, auth.Value,
+		auth.ValidAfter, auth.ValidBefore, auth.Nonce, v, r, sig)
+	if err != nil {
+		return nil, errors.Join(ErrContractPack, err)
+	}
+	return input, nil
+}
+
+func (s *service) AuthorizationState(ctx context.Context, authorizer string, nonce common.Hash) (bool, error) {
+	input, err := authorizationABI.Pack(AUTHORIZATION_STATE, common.HexToAddress(authorizer), nonce)
+	if err != nil {
+		return false, errors.Join(ErrContractPack, err)
+	}
+	data, err := s.client.CallContract(ctx, ethereum.CallMsg{To: &s.contract.Address, Data: input}, nil)
+	if err != nil {
+		return false, errors.Join(ErrEthClient, err)
+	}
+	out, err := authorizationABI.Unpack(AUTHORIZATION_STATE, data)
+	if err != nil {
+		return false, errors.Join(ErrContractUnpack, err)
+	}
+	used, ok := out[0].(bool)
+	if !ok {
+		return false, errors.Join(ErrContractUnpack, fmt.Errorf("field state not found"))
+	}
+	return used, nil
+}
+
+// ParseNonce - the bytes32 nonce of an authorization in hex.
+func ParseNonce(nonce string) (common.Hash, error) {
+	b, err := hexutil.Decode(nonce)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, errors.Join(ErrInvalidNonce, fmt.Errorf("nonce %s is not bytes32", nonce))
+	}
+	return common.BytesToHash(b), nil
+}
@@ -0,0 +1,96 @@
+package erc20
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+func TestCheckAuthorization(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	token, err := contract.CreateContract("ERC20", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &nonceClient{}
+	srv, err := NewERC20Service(client, token, big.NewInt(11155111), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain := Domain{Name: "USDC", Version: "2"}
+	treasury := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	validBefore := time.Now().Add(time.Hour).Unix()
+	typedData, err := srv.PrepareAuthorization(domain, from.Hex(), treasury, 12.5, 0, validBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := ParseNonce(typedData.Message["nonce"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := apitypes.TypedDataAndHash(*typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &Authorization{
+		From:        from,
+		To:          common.HexToAddress(treasury),
+		Value:       big.NewInt(12500000),
+		ValidAfter:  big.NewInt(0),
+		ValidBefore: big.NewInt(validBefore),
+		Nonce:       nonce,
+	}
+	input, err := srv.CheckAuthorization(context.Background(), domain, auth, treasury, 12.5, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := authorizationABI.Methods[TRANSFER_WITH_AUTHORIZATION].Inputs.Unpack(input[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0].(common.Address) != from || args[5].([32]byte) != nonce {
+		t.Fatalf("unexpected call %v", args)
+	}
+
+	cases := []struct {
+		name   string
+		auth   Authorization
+		to     string
+		amount float64
+		err    error
+	}{
+		{"other recipient", *auth, "0x000000000000000000000000000000000000dEaD", 12.5, ErrInvalidAddress},
+		{"other amount", *auth, treasury, 12, ErrInvalidAmount},
+		{"expired", Authorization{From: from, To: auth.To, Value: auth.Value, ValidAfter: big.NewInt(0), ValidBefore: big.NewInt(1), Nonce: nonce}, treasury, 12.5, ErrInvaildField},
+		{"other signer", Authorization{From: common.HexToAddress(treasury), To: auth.To, Value: auth.Value, ValidAfter: big.NewInt(0), ValidBefore: auth.ValidBefore, Nonce: nonce}, treasury, 12.5, ErrInvalidSignature},
+	}
+	for _, c := range cases {
+		if _, err := srv.CheckAuthorization(context.Background(), domain, &c.auth, c.to, c.amount, sig); !errors.Is(err, c.err) {
+			t.Fatalf("%s: expected %s, got %v", c.name, c.err, err)
+		}
+	}
+
+	client.used = true
+	if _, err := srv.CheckAuthorization(context.Background(), domain, auth, treasury, 12.5, sig); !errors.Is(err, ErrInvalidNonce) {
+		t.Fatalf("expected used nonce, got %v", err)
+	}
+}
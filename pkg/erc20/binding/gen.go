@@ -0,0 +1,4 @@
+// Package binding - the typed bindings of the ERC-20 ABI generated by abigen.
+package binding
+
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen --abi ../../../deployment/abi/erc-20.json --pkg binding --type ERC20 --out erc20.go
@@ -0,0 +1,60 @@
+package erc20
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Domain - the EIP-712 domain of a token signing the permits and the
+// authorizations, it must match the name and the version of the contract.
+type Domain struct {
+	Name    string
+	Version string
+}
+
+var domainTypes = []apitypes.Type{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+}
+
+func (d Domain) typedDataDomain(chainId *big.Int, token common.Address) apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name:              d.Name,
+		Version:           d.Version,
+		ChainId:           (*math.HexOrDecimal256)(chainId),
+		VerifyingContract: token.Hex(),
+	}
+}
+
+// RecoverTypedData - the signer of the typed data and the v, r, s of the signature.
+func RecoverTypedData(typedData apitypes.TypedData, signature []byte) (common.Address, uint8, [32]byte, [32]byte, error) {
+	var r, s [32]byte
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, 0, r, s, errors.Join(ErrInvalidSignature, fmt.Errorf("signature length %d", len(signature)))
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Address{}, 0, r, s, errors.Join(ErrSign, err)
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, signature)
+	// wallets return v as 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, 0, r, s, errors.Join(ErrInvalidSignature, err)
+	}
+	copy(r[:], sig[:32])
+	copy(s[:], sig[32:64])
+	return crypto.PubkeyToAddress(*pub), sig[crypto.RecoveryIDOffset] + 27, r, s, nil
+}
@@ -1,13 +1,17 @@
 package erc20
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/transfer"
+)
 
 var (
 	// ErrInvalidAddress is returned when an invalid address is provided.
-	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidAddress = transfer.ErrInvalidAddress
 
 	// ErrInvalidAmount is returned when an invalid amount is provided.
-	ErrInvalidAmount = errors.New("invalid amount")
+	ErrInvalidAmount = transfer.ErrInvalidAmount
 
 	// ErrInvalidGasLimit is returned when an invalid gas limit is provided.
 	ErrInvalidGasLimit = errors.New("invalid gas limit")
@@ -22,16 +26,16 @@ var (
 	ErrInvalidGasPrice = errors.New("invalid gas price")
 
 	// ErrInvalidSignature is returned when an invalid signature is provided.
-	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidSignature = transfer.ErrInvalidSignature
 
 	// ErrInvalidNonce is returned when an invalid nonce is provided.
 	ErrInvalidNonce = errors.New("invalid nonce")
 
 	// ErrEthClient is returned when ethereum client error.
-	ErrEthClient = errors.New("ethereum client error")
+	ErrEthClient = transfer.ErrEthClient
 
 	// ErrSign is returned when sign error.
-	ErrSign = errors.New("sign error")
+	ErrSign = transfer.ErrSign
 
 	// ErrContractPack is returned when contract pack error.
 	ErrContractPack = errors.New("contract pack error")
@@ -39,6 +43,15 @@ var (
 	// ErrContractUnpack is returned when contract unpack error.
 	ErrContractUnpack = errors.New("contract unpack error")
 
+	// ErrInvalidTransaction is returned when an invalid transaction is provided.
+	ErrInvalidTransaction = transfer.ErrInvalidTransaction
+
+	// ErrTokenNotFound is returned when the token is not registered.
+	ErrTokenNotFound = errors.New("token not found")
+
+	// ErrTokenRegistered is returned when the token is already registered.
+	ErrTokenRegistered = errors.New("token already registered")
+
 	// ErrInvaildField is returned when invaild field.
 	ErrInvaildField = errors.New("invaild field")
 )
@@ -0,0 +1,149 @@
+package erc20
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const (
+	TRANSFER_FROM = "transferFrom"
+	PERMIT        = "permit"
+	NONCES        = "nonces"
+
+	// TRANSFER_FROM_GAS - the gas limit of a transferFrom relayed with its
+	// permit, it can not be estimated before the permit is mined.
+	TRANSFER_FROM_GAS uint64 = 100000
+
+	// PERMIT_ABI - the EIP-2612 functions, which are not in the erc20 abi.
+	PERMIT_ABI = `[
+	{"inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"},{"name":"value","type":"uint256"},{"name":"deadline","type":"uint256"},{"name":"v","type":"uint8"},{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"}],"name":"permit","outputs":[],"stateMutability":"nonpayable","type":"function"},
+	{"inputs":[{"name":"owner","type":"address"}],"name":"nonces","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`
+)
+
+var permitABI abi.ABI
+
+func init() {
+	var err error
+	if permitABI, err = abi.JSON(strings.NewReader(PERMIT_ABI)); err != nil {
+		panic(err)
+	}
+}
+
+// PermitCalls - the calls of the spender pulling the tokens with a permit.
+type PermitCalls struct {
+	Permit       []byte
+	TransferFrom []byte
+}
+
+// PermitTypedData - the EIP-712 typed data of an EIP-2612 permit.
+func PermitTypedData(domain Domain, chainId *big.Int, token, owner, spender common.Address, value, nonce, deadline *big.Int) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainTypes,
+			"Permit": {
+				{Name: "owner", Type: "address"},
+				{Name: "spender", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Permit",
+		Domain:      domain.typedDataDomain(chainId, token),
+		Message: apitypes.TypedDataMessage{
+			"owner":    owner.Hex(),
+			"spender":  spender.Hex(),
+			"value":    value.String(),
+			"nonce":    nonce.String(),
+			"deadline": deadline.String(),
+		},
+	}
+}
+
+func (s *service) PreparePermit(ctx context.Context, domain Domain, owner, spender string, amount float64, deadline int64) (*apitypes.TypedData, error) {
+	typedData, err := s.permitTypedData(ctx, domain, owner, spender, amount, deadline)
+	if err != nil {
+		return nil, err
+	}
+	return &typedData, nil
+}
+
+func (s *service) CheckPermit(ctx context.Context, domain Domain, owner, spender, to string, amount float64, deadline int64, signature []byte) (*PermitCalls, error) {
+	if utils.IsEmpty(to) || !utils.IsValidAddress(to) {
+		return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("to address is invalid"))
+	}
+	typedData, err := s.permitTypedData(ctx, domain, owner, spender, amount, deadline)
+	if err != nil {
+		return nil, err
+	}
+	signer, v, r, sig, err := RecoverTypedData(typedData, signature)
+	if err != nil {
+		return nil, err
+	}
+	ownerAddr := common.HexToAddress(owner)
+	if signer != ownerAddr {
+		return nil, errors.Join(ErrInvalidSignature, fmt.Errorf("permit is signed by %s", signer.Hex()))
+	}
+
+	value := contract.ToWei(amount, s.decimals)
+	permit, err := permitABI.Pack(PERMIT, ownerAddr, common.HexToAddress(spender), value, big.NewInt(deadline), v, r, sig)
+	if err != nil {
+		return nil, errors.Join(ErrContractPack, err)
+	}
+	transferFrom, err := s.contract.ABI.Pack(TRANSFER_FROM, ownerAddr, common.HexToAddress(to), value)
+	if err != nil {
+		return nil, errors.Join(ErrContractPack, err)
+	}
+	return &PermitCalls{Permit: permit, TransferFrom: transferFrom}, nil
+}
+
+// permitTypedData - the permit of the amount with the current nonce of the owner.
+func (s *service) permitTypedData(ctx context.Context, domain Domain, owner, spender string, amount float64, deadline int64) (apitypes.TypedData, error) {
+	if utils.IsEmpty(owner) || !utils.IsValidAddress(owner) {
+		return apitypes.TypedData{}, errors.Join(ErrInvalidAddress, fmt.Errorf("owner address is invalid"))
+	}
+	if utils.IsEmpty(spender) || !utils.IsValidAddress(spender) {
+		return apitypes.TypedData{}, errors.Join(ErrInvalidAddress, fmt.Errorf("spender address is invalid"))
+	}
+	if amount <= 0 {
+		return apitypes.TypedData{}, ErrInvalidAmount
+	}
+	ownerAddr := common.HexToAddress(owner)
+	nonce, err := s.permitNonce(ctx, ownerAddr)
+	if err != nil {
+		return apitypes.TypedData{}, err
+	}
+	return PermitTypedData(domain, s.chainId, s.contract.Address, ownerAddr, common.HexToAddress(spender),
+		contract.ToWei(amount, s.decimals), nonce, big.NewInt(deadline)), nil
+}
+
+func (s *service) permitNonce(ctx context.Context, owner common.Address) (*big.Int, error) {
+	input, err := permitABI.Pack(NONCES, owner)
+	if err != nil {
+		return nil, errors.Join(ErrContractPack, err)
+	}
+	data, err := s.client.CallContract(ctx, ethereum.CallMsg{To: &s.contract.Address, Data: input}, nil)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	out, err := permitABI.Unpack(NONCES, data)
+	if err != nil {
+		return nil, errors.Join(ErrContractUnpack, err)
+	}
+	nonce, ok := out[0].(*big.Int)
+	if !ok {
+		return nil, errors.Join(ErrContractUnpack, fmt.Errorf("field nonce not found"))
+	}
+	return nonce, nil
+}
@@ -0,0 +1,118 @@
+package erc20
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// nonceClient - a client answering the nonces of the permits and the state
+// of the authorizations.
+type nonceClient struct {
+	chain.Client
+	nonce *big.Int
+	used  bool
+}
+
+func (c *nonceClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if method, err := authorizationABI.MethodById(call.Data); err == nil && method.Name == AUTHORIZATION_STATE {
+		return method.Outputs.Pack(c.used)
+	}
+	return permitABI.Methods[NONCES].Outputs.Pack(c.nonce)
+}
+
+// signPermit - sign the permit with a new key.
+func signPermit(t *testing.T, typedData *apitypes.TypedData) []byte {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := apitypes.TypedDataAndHash(*typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as a wallet returns it
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig
+}
+
+func TestCheckPermit(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	token, err := contract.CreateContract("ERC20", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewERC20Service(&nonceClient{nonce: big.NewInt(3)}, token, big.NewInt(11155111), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain := Domain{Name: "USDC", Version: "2"}
+	spender := "0x8ba1f109551bD432803012645Ac136ddd64DBA72"
+	treasury := "0x000000000000000000000000000000000000dEaD"
+	deadline := time.Now().Add(time.Hour).Unix()
+
+	// the owner is unknown before signing, so the typed data is rebuilt
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	typedData, err := srv.PreparePermit(context.Background(), domain, owner, spender, 12.5, deadline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typedData.Message["value"] != "12500000" || typedData.Message["nonce"] != "3" {
+		t.Fatalf("unexpected message %v", typedData.Message)
+	}
+	hash, _, err := apitypes.TypedDataAndHash(*typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+
+	calls, err := srv.CheckPermit(context.Background(), domain, owner, spender, treasury, 12.5, deadline, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := permitABI.Methods[PERMIT].Inputs.Unpack(calls.Permit[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0].(common.Address).Hex() != owner || args[2].(*big.Int).Int64() != 12500000 || args[4].(uint8) != sig[crypto.RecoveryIDOffset] {
+		t.Fatalf("unexpected permit %v", args)
+	}
+	args, err = token.ABI.Methods[TRANSFER_FROM].Inputs.Unpack(calls.TransferFrom[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0].(common.Address).Hex() != owner || args[1].(common.Address) != common.HexToAddress(treasury) {
+		t.Fatalf("unexpected transferFrom %v", args)
+	}
+
+	// a permit of another amount or another signer is rejected
+	if _, err := srv.CheckPermit(context.Background(), domain, owner, spender, treasury, 12, deadline, sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+	other := signPermit(t, typedData)
+	if _, err := srv.CheckPermit(context.Background(), domain, owner, spender, treasury, 12.5, deadline, other); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+}
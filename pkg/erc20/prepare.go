@@ -0,0 +1,119 @@
+package erc20
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/transfer"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// transfer - the transfer of the amount from the sender, a call of the
+// token contract.
+func (s *service) transfer(from, to string, amount float64) (transfer.Transfer, error) {
+	input, err := s.checkCommonRequest(protos.CommonRequest{From: from, To: to, Amount: amount}, TRANSFER)
+	if err != nil {
+		return transfer.Transfer{}, err
+	}
+	if amount == 0 {
+		return transfer.Transfer{}, errors.Join(ErrInvalidAmount, fmt.Errorf("amount field is 0"))
+	}
+	return transfer.Transfer{
+		From: common.HexToAddress(from),
+		To:   s.contract.Address,
+		Data: input,
+	}, nil
+}
+
+// EstimateTransfer - the gas of the transfer of the amount from the sender.
+func (s *service) EstimateTransfer(ctx context.Context, from, to string, amount float64) (uint64, error) {
+	t, err := s.transfer(from, to, amount)
+	if err != nil {
+		return 0, err
+	}
+	return transfer.Estimate(ctx, s.client, t)
+}
+
+// PrepareTransfer - build the unsigned EIP-1559 transfer with estimated gas,
+// suggested fees and the pending nonce of the sender.
+func (s *service) PrepareTransfer(ctx context.Context, from, to string, amount float64) (*types.Transaction, error) {
+	t, err := s.transfer(from, to, amount)
+	if err != nil {
+		return nil, err
+	}
+	return transfer.Prepare(ctx, s.client, s.chainId, t)
+}
+
+// SignPrepared - attach the signature to the prepared transaction and make
+// sure it was signed by the sender.
+func (s *service) SignPrepared(prepared *types.Transaction, from string, signature []byte) (*types.Transaction, error) {
+	return transfer.SignPrepared(s.chainId, prepared, from, signature)
+}
+
+// CheckSignedTransfer - make sure the signed transaction is a transfer of
+// the token to the recipient for the exact amount, signed by the sender.
+func (s *service) CheckSignedTransfer(signTx *types.Transaction, from, to string, amount float64) (*types.Transaction, error) {
+	t, err := s.transfer(from, to, amount)
+	if err != nil {
+		return nil, err
+	}
+	if signTx.To() != nil && *signTx.To() == s.contract.Address {
+		recipient, value, err := contract.ParseTransferInput(*s.abi, signTx.Data())
+		if err != nil {
+			return nil, errors.Join(ErrInvalidTransaction, err)
+		}
+		if recipient != common.HexToAddress(to) {
+			return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("transfer recipient is %s", recipient.Hex()))
+		}
+		if value.Cmp(contract.ToWei(amount, s.decimals)) != 0 {
+			return nil, errors.Join(ErrInvalidAmount, fmt.Errorf("transfer amount is %s", value))
+		}
+	}
+	return transfer.CheckSigned(s.chainId, signTx, t)
+}
+
+// ToTransactionArgs - the JSON form of the unsigned transaction, as used by
+// eth_signTransaction and eth_sendTransaction.
+func ToTransactionArgs(tx *types.Transaction, from string) protos.TransactionArgs {
+	args := protos.TransactionArgs{
+		Type:                 hexutil.EncodeUint64(uint64(tx.Type())),
+		ChainId:              (*hexutil.Big)(tx.ChainId()).String(),
+		From:                 common.HexToAddress(from).Hex(),
+		Nonce:                hexutil.EncodeUint64(tx.Nonce()),
+		Gas:                  hexutil.EncodeUint64(tx.Gas()),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tx.GasTipCap()).String(),
+		MaxFeePerGas:         (*hexutil.Big)(tx.GasFeeCap()).String(),
+		Value:                (*hexutil.Big)(tx.Value()).String(),
+		Data:                 hexutil.Encode(tx.Data()),
+	}
+	if tx.To() != nil {
+		args.To = tx.To().Hex()
+	}
+	return args
+}
+
+// UnsignedPayload - the EIP-2718 payload of the unsigned transaction, its
+// keccak256 hash is what the wallet signs.
+func UnsignedPayload(tx *types.Transaction) ([]byte, error) {
+	payload, err := rlp.EncodeToBytes([]interface{}{
+		tx.ChainId(),
+		tx.Nonce(),
+		tx.GasTipCap(),
+		tx.GasFeeCap(),
+		tx.Gas(),
+		tx.To(),
+		tx.Value(),
+		tx.Data(),
+		tx.AccessList(),
+	})
+	if err != nil {
+		return nil, errors.Join(ErrInvalidTransaction, err)
+	}
+	return append([]byte{tx.Type()}, payload...), nil
+}
@@ -0,0 +1,85 @@
+package erc20
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Token - an accepted token and the service of its contract.
+type Token struct {
+	Symbol   string
+	Address  common.Address
+	Decimals int
+	Service  ERC20Service
+	// Domain - the EIP-712 domain, nil when the token signs no typed data
+	Domain *Domain
+	// Permit - the token supports EIP-2612
+	Permit bool
+	// Authorization - the token supports EIP-3009
+	Authorization bool
+}
+
+// Registry - the accepted tokens by symbol, the first registered token is
+// the default one.
+type Registry struct {
+	tokens  map[string]*Token
+	symbols []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		tokens: make(map[string]*Token),
+	}
+}
+
+// Register - add a token, the symbol and the address must be unique.
+func (r *Registry) Register(token *Token) error {
+	if token == nil || token.Service == nil || token.Symbol == "" {
+		return errors.Join(ErrInvaildField, fmt.Errorf("token is incomplete"))
+	}
+	symbol := strings.ToUpper(token.Symbol)
+	if _, ok := r.tokens[symbol]; ok {
+		return errors.Join(ErrTokenRegistered, fmt.Errorf("symbol %s", symbol))
+	}
+	for _, val := range r.tokens {
+		if val.Address == token.Address {
+			return errors.Join(ErrTokenRegistered, fmt.Errorf("address %s", token.Address.Hex()))
+		}
+	}
+	token.Symbol = symbol
+	r.tokens[symbol] = token
+	r.symbols = append(r.symbols, symbol)
+	return nil
+}
+
+// Get - get the token by symbol, an empty symbol is the default token.
+func (r *Registry) Get(symbol string) (*Token, error) {
+	if symbol == "" {
+		return r.Default()
+	}
+	token, ok := r.tokens[strings.ToUpper(symbol)]
+	if !ok {
+		return nil, errors.Join(ErrTokenNotFound, fmt.Errorf("symbol %s", symbol))
+	}
+	return token, nil
+}
+
+// Default - the first registered token.
+func (r *Registry) Default() (*Token, error) {
+	if len(r.symbols) == 0 {
+		return nil, ErrTokenNotFound
+	}
+	return r.tokens[r.symbols[0]], nil
+}
+
+// List - the tokens in the registered order.
+func (r *Registry) List() []*Token {
+	tokens := make([]*Token, 0, len(r.symbols))
+	for _, symbol := range r.symbols {
+		tokens = append(tokens, r.tokens[symbol])
+	}
+	return tokens
+}
@@ -0,0 +1,38 @@
+package erc20
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRegistry(t *testing.T) {
+	tokens := NewRegistry()
+	usdc := &Token{Symbol: "usdc", Address: common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"), Decimals: 6, Service: &service{}}
+	dai := &Token{Symbol: "DAI", Address: common.HexToAddress("0x3e622317f8C93f7328350cF0B56d9eD4C620C5d6"), Decimals: 18, Service: &service{}}
+	if err := tokens.Register(usdc); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Register(dai); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Register(&Token{Symbol: "USDT", Address: usdc.Address, Service: &service{}}); !errors.Is(err, ErrTokenRegistered) {
+		t.Fatalf("expected duplicate address, got %v", err)
+	}
+
+	token, err := tokens.Get("")
+	if err != nil || token.Symbol != "USDC" {
+		t.Fatalf("unexpected default token %v %v", token, err)
+	}
+	token, err = tokens.Get("dai")
+	if err != nil || token.Decimals != 18 {
+		t.Fatalf("unexpected token %v %v", token, err)
+	}
+	if _, err := tokens.Get("USDT"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("expected token not found, got %v", err)
+	}
+	if len(tokens.List()) != 2 {
+		t.Fatalf("unexpected tokens %d", len(tokens.List()))
+	}
+}
@@ -10,15 +10,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
 	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20/binding"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
 	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
 const (
@@ -38,12 +41,61 @@ type ERC20Service interface {
 	// @return transaction
 	// @return error
 	TransferWithPrivateKey(ctx context.Context, trans protos.CommonRequest, privateKey *ecdsa.PrivateKey) (*types.Transaction, error)
+	// TransferWithSigner - send a transaction signed by the signer.
+	// @param ctx - context
+	// @param trans - common request
+	// @param signer - signer of the sender
+	// @return transaction
+	// @return error
+	TransferWithSigner(ctx context.Context, trans protos.CommonRequest, signer Signer) (*types.Transaction, error)
 	// TransferWithSign - send a transaction with sign.
 	// @param ctx - context
 	// @param trans - common request
 	// @return transaction
 	// @return error
 	TransferWithSign(ctx context.Context, trans protos.CommonRequest) (*types.Transaction, error)
+	// SignTransfer - build a signed transfer transaction without sending it.
+	// @param ctx - context
+	// @param trans - common request
+	// @return transaction
+	// @return error
+	SignTransfer(ctx context.Context, trans protos.CommonRequest) (*types.Transaction, error)
+	// PrepareTransfer - build an unsigned transfer transaction for the wallet to sign.
+	// @param ctx - context
+	// @param from - sender address
+	// @param to - recipient address
+	// @param amount - amount of token
+	// @return transaction
+	// @return error
+	PrepareTransfer(ctx context.Context, from, to string, amount float64) (*types.Transaction, error)
+	// EstimateTransfer - estimate the gas of a transfer from the sender.
+	// @param ctx - context
+	// @param from - sender address
+	// @param to - recipient address
+	// @param amount - amount of token
+	// @return gas
+	// @return error
+	EstimateTransfer(ctx context.Context, from, to string, amount float64) (uint64, error)
+	// SignPrepared - attach the signature of the sender to a prepared transaction.
+	// @param prepared - prepared transaction
+	// @param from - sender address
+	// @param signature - signature
+	// @return transaction
+	// @return error
+	SignPrepared(prepared *types.Transaction, from string, signature []byte) (*types.Transaction, error)
+	// CheckSignedTransfer - check a signed raw transaction is the expected transfer.
+	// @param signTx - signed transaction
+	// @param from - sender address
+	// @param to - recipient address
+	// @param amount - amount of token
+	// @return transaction
+	// @return error
+	CheckSignedTransfer(signTx *types.Transaction, from, to string, amount float64) (*types.Transaction, error)
+	// SendTransaction - broadcast a signed transaction.
+	// @param ctx - context
+	// @param tx - signed transaction
+	// @return error
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
 	// BalanceOf - get balance of an address.
 	// @param ctx - context
 	// @param address - address
@@ -70,6 +122,13 @@ type ERC20Service interface {
 	// @return transaction
 	// @return error
 	ApproveWithPrivateKey(ctx context.Context, request protos.CommonRequest, privateKey *ecdsa.PrivateKey) (*types.Transaction, error)
+	// ApproveWithSigner - approve an address to transfer token with the signer.
+	// @param ctx - context
+	// @param request - common request
+	// @param signer - signer of the owner
+	// @return transaction
+	// @return error
+	ApproveWithSigner(ctx context.Context, request protos.CommonRequest, signer Signer) (*types.Transaction, error)
 	// ApproveWithSign - approve an address to transfer token with sign.
 	// @param ctx - context
 	// @param request - common request
@@ -78,33 +137,114 @@ type ERC20Service interface {
 	ApproveWithSign(ctx context.Context, request protos.CommonRequest) (*types.Transaction, error)
 	// CheckAllowance - check allowance of an address.
 	// @param ctx - context
-	// @param request - common request
+	// @param request - the owner in from and the spender in to
 	// @return allowance
 	// @return error
 	CheckAllowance(ctx context.Context, request protos.CheckAllowanceRequest) (*big.Int, error)
+	// Metadata - read the metadata of the token contract.
+	// @param ctx - context
+	// @return metadata
+	// @return error
+	Metadata(ctx context.Context) (*Metadata, error)
+	// PreparePermit - build the EIP-2612 permit of the owner for the wallet to sign.
+	// @param ctx - context
+	// @param domain - domain of the token
+	// @param owner - owner address
+	// @param spender - spender address
+	// @param amount - amount of token
+	// @param deadline - unix time the permit expires
+	// @return typed data
+	// @return error
+	PreparePermit(ctx context.Context, domain Domain, owner, spender string, amount float64, deadline int64) (*apitypes.TypedData, error)
+	// CheckPermit - check the permit is signed by the owner and pack the calls
+	// of the spender pulling the amount to the recipient.
+	// @param ctx - context
+	// @param domain - domain of the token
+	// @param owner - owner address
+	// @param spender - spender address
+	// @param to - recipient address
+	// @param amount - amount of token
+	// @param deadline - unix time the permit expires
+	// @param signature - signature of the typed data
+	// @return permit calls
+	// @return error
+	CheckPermit(ctx context.Context, domain Domain, owner, spender, to string, amount float64, deadline int64, signature []byte) (*PermitCalls, error)
+	// PrepareAuthorization - build the EIP-3009 transfer authorization with a
+	// random nonce for the wallet to sign.
+	// @param domain - domain of the token
+	// @param from - sender address
+	// @param to - recipient address
+	// @param amount - amount of token
+	// @param validAfter - unix time the authorization is valid after
+	// @param validBefore - unix time the authorization is valid before
+	// @return typed data
+	// @return error
+	PrepareAuthorization(domain Domain, from, to string, amount float64, validAfter, validBefore int64) (*apitypes.TypedData, error)
+	// CheckAuthorization - check the authorization is signed by the sender,
+	// sends the amount to the recipient, is valid now and is not used, then
+	// pack the transferWithAuthorization call.
+	// @param ctx - context
+	// @param domain - domain of the token
+	// @param auth - authorization
+	// @param to - recipient address
+	// @param amount - amount of token
+	// @param signature - signature of the typed data
+	// @return call data
+	// @return error
+	CheckAuthorization(ctx context.Context, domain Domain, auth *Authorization, to string, amount float64, signature []byte) ([]byte, error)
+	// AuthorizationState - whether the nonce of the authorizer is used.
+	// @param ctx - context
+	// @param authorizer - authorizer address
+	// @param nonce - nonce
+	// @return used
+	// @return error
+	AuthorizationState(ctx context.Context, authorizer string, nonce common.Hash) (bool, error)
 	// GetABI - get contract of abi
 	// @return abi
 	GetABI() abi.ABI
 }
 
 type service struct {
-	client   *ethclient.Client
+	client   chain.Client
 	contract *contract.Contract
+	caller   *binding.ERC20Caller
+	abi      *abi.ABI
 	chainId  *big.Int
 	decimals int
 }
 
-func NewERC20Service(client *ethclient.Client, contract *contract.Contract, chainId *big.Int, decimals int) ERC20Service {
+// Metadata - the metadata read from the token contract.
+type Metadata struct {
+	Name        string
+	Symbol      string
+	Decimals    uint8
+	TotalSupply *big.Int
+}
 
+func NewERC20Service(client chain.Client, contract *contract.Contract, chainId *big.Int, decimals int) (ERC20Service, error) {
+	caller, err := binding.NewERC20Caller(contract.Address, client)
+	if err != nil {
+		return nil, errors.Join(ErrContractUnpack, err)
+	}
+	parsed, err := binding.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, errors.Join(ErrContractUnpack, err)
+	}
 	return &service{
 		client:   client,
 		contract: contract,
+		caller:   caller,
+		abi:      parsed,
 		chainId:  chainId,
 		decimals: decimals,
-	}
+	}, nil
 }
 
 func (s *service) TransferWithPrivateKey(ctx context.Context, trans protos.CommonRequest, privateKey *ecdsa.PrivateKey) (*types.Transaction, error) {
+	return s.TransferWithSigner(ctx, trans, NewKeySigner(privateKey))
+}
+
+func (s *service) TransferWithSigner(ctx context.Context, trans protos.CommonRequest, signer Signer) (*types.Transaction, error) {
 	input, err := s.checkCommonRequest(trans, TRANSFER)
 	if err != nil {
 		return nil, err
@@ -168,10 +308,21 @@ func (s *service) TransferWithPrivateKey(ctx context.Context, trans protos.Commo
 	}
 	params.GasTipCap = gasTipCap
 
-	return s.transaction(ctx, trans.Nonce, *params, nil, privateKey)
+	return s.transaction(ctx, trans.Nonce, *params, nil, signer)
 }
 
 func (s *service) TransferWithSign(ctx context.Context, trans protos.CommonRequest) (*types.Transaction, error) {
+	signTx, err := s.SignTransfer(ctx, trans)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SendTransaction(ctx, signTx); err != nil {
+		return nil, err
+	}
+	return signTx, nil
+}
+
+func (s *service) SignTransfer(ctx context.Context, trans protos.CommonRequest) (*types.Transaction, error) {
 	input, err := s.checkCommonRequest(trans, TRANSFER)
 	if err != nil {
 		return nil, err
@@ -229,37 +380,41 @@ func (s *service) TransferWithSign(ctx context.Context, trans protos.CommonReque
 		signature = []byte(trans.Signature)
 	}
 
-	return s.transaction(ctx, trans.Nonce, *params, signature, nil)
+	return s.signWithSignature(trans.Nonce, *params, signature)
 }
 
 func (s *service) BalanceOf(ctx context.Context, address string) (*big.Int, error) {
-	if utils.IsEmpty(address) || utils.IsValidAddress(address) {
+	if utils.IsEmpty(address) || !utils.IsValidAddress(address) {
 		return nil, ErrInvalidAddress
 	}
-	addr := common.HexToAddress(address)
-	input, err := s.contract.ABI.Pack(BALANCE_OF, addr)
+	balance, err := s.caller.BalanceOf(&bind.CallOpts{Context: ctx}, common.HexToAddress(address))
 	if err != nil {
-		return nil, errors.Join(ErrContractPack, err)
+		return nil, errors.Join(ErrEthClient, err)
 	}
-	callParams := ethereum.CallMsg{
-		From: addr,
-		To:   &s.contract.Address,
-		Data: input,
+	return balance, nil
+}
+
+// Metadata - read the name, the symbol, the decimals and the total supply of
+// the token.
+func (s *service) Metadata(ctx context.Context) (*Metadata, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	var (
+		meta = new(Metadata)
+		err  error
+	)
+	if meta.Name, err = s.caller.Name(opts); err != nil {
+		return nil, errors.Join(ErrEthClient, err)
 	}
-	data, err := s.client.CallContract(ctx, callParams, nil)
-	if err != nil {
+	if meta.Symbol, err = s.caller.Symbol(opts); err != nil {
 		return nil, errors.Join(ErrEthClient, err)
 	}
-	outputs := make(map[string]interface{})
-	err = s.contract.ABI.UnpackIntoMap(outputs, BALANCE_OF, data)
-	if err != nil {
-		return nil, errors.Join(ErrContractUnpack, err)
+	if meta.Decimals, err = s.caller.Decimals(opts); err != nil {
+		return nil, errors.Join(ErrEthClient, err)
 	}
-
-	if balance, ok := outputs["balance"].(*big.Int); ok {
-		return balance, nil
+	if meta.TotalSupply, err = s.caller.TotalSupply(opts); err != nil {
+		return nil, errors.Join(ErrEthClient, err)
 	}
-	return nil, errors.Join(ErrContractUnpack, fmt.Errorf("field balance not found"))
+	return meta, nil
 }
 
 func (s *service) SubscribeTransfer(process func(types.Log), fromBlock *big.Int) (func(), <-chan error) {
@@ -356,6 +511,10 @@ func (s *service) SubscribeTransferWithFilter(process func(types.Log), fromBlock
 }
 
 func (s *service) ApproveWithPrivateKey(ctx context.Context, request protos.CommonRequest, privateKey *ecdsa.PrivateKey) (*types.Transaction, error) {
+	return s.ApproveWithSigner(ctx, request, NewKeySigner(privateKey))
+}
+
+func (s *service) ApproveWithSigner(ctx context.Context, request protos.CommonRequest, signer Signer) (*types.Transaction, error) {
 	input, err := s.checkCommonRequest(request, APPROVE)
 	if err != nil {
 		return nil, err
@@ -415,7 +574,7 @@ func (s *service) ApproveWithPrivateKey(ctx context.Context, request protos.Comm
 	}
 	params.GasTipCap = gasTipCap
 
-	return s.transaction(ctx, request.Nonce, *params, nil, privateKey)
+	return s.transaction(ctx, request.Nonce, *params, nil, signer)
 }
 
 func (s *service) ApproveWithSign(ctx context.Context, request protos.CommonRequest) (*types.Transaction, error) {
@@ -469,38 +628,18 @@ func (s *service) ApproveWithSign(ctx context.Context, request protos.CommonRequ
 }
 
 func (s *service) CheckAllowance(ctx context.Context, request protos.CheckAllowanceRequest) (*big.Int, error) {
-	if utils.IsEmpty(request.From) || utils.IsValidAddress(request.From) {
+	if utils.IsEmpty(request.From) || !utils.IsValidAddress(request.From) {
 		return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("from address is invalid"))
 	}
-
-	if utils.IsEmpty(request.To) || utils.IsValidAddress(request.To) {
+	if utils.IsEmpty(request.To) || !utils.IsValidAddress(request.To) {
 		return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("to address is invalid"))
 	}
-	from := common.HexToAddress(request.From)
-	to := common.HexToAddress(request.To)
-	input, err := s.contract.ABI.Pack(ALLOWANCE, from, to)
-	if err != nil {
-		return nil, errors.Join(ErrContractPack, err)
-	}
-	callParams := ethereum.CallMsg{
-		From: from,
-		To:   &s.contract.Address,
-		Data: input,
-	}
-	data, err := s.client.CallContract(ctx, callParams, nil)
+	allowance, err := s.caller.Allowance(&bind.CallOpts{Context: ctx},
+		common.HexToAddress(request.From), common.HexToAddress(request.To))
 	if err != nil {
 		return nil, errors.Join(ErrEthClient, err)
 	}
-	var output interface{}
-	err = s.contract.ABI.UnpackIntoInterface(output, ALLOWANCE, data)
-	if err != nil {
-		return nil, errors.Join(ErrContractUnpack, err)
-	}
-
-	if num, ok := output.(*big.Int); ok {
-		return num, nil
-	}
-	return nil, nil
+	return allowance, nil
 }
 
 func (s *service) GetABI() abi.ABI {
@@ -520,59 +659,78 @@ func (s *service) checkCommonRequest(request protos.CommonRequest, method string
 	if request.Amount < 0 {
 		return nil, ErrInvalidAmount
 	}
-	amount := contract.ToWei(request.Amount, 6)
+	amount := contract.ToWei(request.Amount, s.decimals)
 
-	input, err := s.contract.ABI.Pack(method, to, amount)
+	input, err := s.abi.Pack(method, to, amount)
 	if err != nil {
 		return nil, errors.Join(ErrContractPack, err)
 	}
 	return input, nil
 }
 
-func (s *service) transaction(ctx context.Context, nonce uint64, callParams ethereum.CallMsg, sign []byte, privateKey *ecdsa.PrivateKey) (*types.Transaction, error) {
-	tx := types.NewTx(&types.DynamicFeeTx{
-		Nonce:     nonce,
-		GasTipCap: callParams.GasTipCap,
-		GasFeeCap: callParams.GasFeeCap,
-		Gas:       callParams.Gas,
-		To:        callParams.To,
-		Data:      callParams.Data,
-		Value:     callParams.Value,
-	})
-
-	signer := types.NewLondonSigner(s.chainId)
+func (s *service) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := s.client.SendTransaction(ctx, tx); err != nil {
+		return errors.Join(ErrEthClient, err)
+	}
+	return nil
+}
 
-	if privateKey != nil {
-		signTx, err := types.SignTx(tx, signer, privateKey)
+func (s *service) transaction(ctx context.Context, nonce uint64, callParams ethereum.CallMsg, sign []byte, signer Signer) (*types.Transaction, error) {
+	var (
+		signTx *types.Transaction
+		err    error
+	)
+	switch {
+	case signer != nil:
+		if signer.Address() != callParams.From {
+			return nil, errors.Join(ErrInvalidSignature, fmt.Errorf("signer %s is not %s", signer.Address(), callParams.From))
+		}
+		signTx, err = signer.SignTx(ctx, newDynamicFeeTx(nonce, callParams), s.chainId)
 		if err != nil {
-			return nil, errors.Join(ErrSign, err)
+			return nil, err
 		}
-		err = s.client.SendTransaction(ctx, signTx)
+	case sign != nil:
+		signTx, err = s.signWithSignature(nonce, callParams, sign)
 		if err != nil {
-			return nil, errors.Join(ErrEthClient, err)
+			return nil, err
 		}
-		return signTx, nil
+	default:
+		return nil, ErrInvalidSignature
 	}
 
-	if sign != nil {
-		signTx, err := tx.WithSignature(signer, sign)
-		if err != nil {
-			return nil, errors.Join(ErrSign, err)
-		}
+	if err := s.SendTransaction(ctx, signTx); err != nil {
+		return nil, err
+	}
+	return signTx, nil
+}
 
-		sigPublicKey, err := signer.Sender(signTx)
-		if err != nil {
-			return nil, errors.Join(ErrSign, err)
-		}
-		if matches := bytes.Equal(sigPublicKey.Bytes(), callParams.From.Bytes()); !matches {
-			return nil, ErrInvalidSignature
-		}
+// signWithSignature - attach the signature to the transaction and make sure
+// it was signed by the sender of the call.
+func (s *service) signWithSignature(nonce uint64, callParams ethereum.CallMsg, sign []byte) (*types.Transaction, error) {
+	signer := types.NewLondonSigner(s.chainId)
+	signTx, err := newDynamicFeeTx(nonce, callParams).WithSignature(signer, sign)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
 
-		err = s.client.SendTransaction(ctx, signTx)
-		if err != nil {
-			return nil, errors.Join(ErrEthClient, err)
-		}
-		return signTx, nil
+	sigPublicKey, err := signer.Sender(signTx)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	if matches := bytes.Equal(sigPublicKey.Bytes(), callParams.From.Bytes()); !matches {
+		return nil, ErrInvalidSignature
 	}
-	return nil, ErrInvalidSignature
+	return signTx, nil
+}
+
+func newDynamicFeeTx(nonce uint64, callParams ethereum.CallMsg) *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		Nonce:     nonce,
+		GasTipCap: callParams.GasTipCap,
+		GasFeeCap: callParams.GasFeeCap,
+		Gas:       callParams.Gas,
+		To:        callParams.To,
+		Data:      callParams.Data,
+		Value:     callParams.Value,
+	})
 }
@@ -2,127 +2,99 @@ package erc20
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"testing"
 
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
 	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20/binding"
 	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
-	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
-func TestTransferWithSign(t *testing.T) {
-	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
-	os.Setenv("PRIVATE_KEY", "./private_key")
-	os.Setenv("RPC", "wss://ethereum-sepolia-rpc.publicnode.com")
-	os.Setenv("TO", "./to")
-	os.Setenv("USDC", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+// tokenClient - a client answering the reads of the token contract.
+type tokenClient struct {
+	chain.Client
+	balances   map[common.Address]*big.Int
+	allowances map[[2]common.Address]*big.Int
+}
 
-	pk, err := os.ReadFile(os.Getenv("PRIVATE_KEY"))
-	if err != nil {
-		t.Fatal(errors.Join(errors.New("private key not found"), err))
-	}
-	privateKey, err := crypto.HexToECDSA(string(pk))
+func (c *tokenClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	tokenABI, err := binding.ERC20MetaData.GetAbi()
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("invalid private key"), err))
+		return nil, err
 	}
-	client, err := ethclient.Dial(os.Getenv("RPC"))
+	method, err := tokenABI.MethodById(call.Data)
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("connect ethereum error"), err))
-	}
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		t.Fatal("error casting public key to ECDSA")
+		return nil, err
 	}
-	from := crypto.PubkeyToAddress(*publicKeyECDSA)
-	ctx := context.Background()
-	toByte, err := os.ReadFile(os.Getenv("TO"))
+	args, err := method.Inputs.Unpack(call.Data[4:])
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("to address not found"), err))
-	}
-	to := common.HexToAddress(string(toByte))
-	nonce, err := client.PendingNonceAt(ctx, from)
-	if err != nil {
-		t.Fatal(errors.Join(errors.New("get nonce error"), err))
-	}
-
-	req := protos.CommonRequest{
-		From:   from.Hex(),
-		To:     to.Hex(),
-		Amount: 0.1,
-		Nonce:  nonce,
-	}
+		return nil, err
+	}
+	switch method.Name {
+	case "name":
+		return method.Outputs.Pack("USD Coin")
+	case "symbol":
+		return method.Outputs.Pack("USDC")
+	case "decimals":
+		return method.Outputs.Pack(uint8(6))
+	case "totalSupply":
+		return method.Outputs.Pack(big.NewInt(1e12))
+	case BALANCE_OF:
+		return method.Outputs.Pack(c.balances[args[0].(common.Address)])
+	case ALLOWANCE:
+		return method.Outputs.Pack(c.allowances[[2]common.Address{args[0].(common.Address), args[1].(common.Address)}])
+	}
+	return nil, fmt.Errorf("method %s not supported", method.Name)
+}
 
-	usdc, err := contract.CreateContract("ERC20", os.Getenv("USDC"))
+func TestReads(t *testing.T) {
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	token, err := contract.CreateContract("ERC20", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("create abi error"), err))
+		t.Fatal(err)
 	}
-
-	amount := contract.ToWei(req.Amount, 6)
-	input, err := usdc.ABI.Pack(TRANSFER, to, amount)
-	if err != nil {
-		t.Fatal(errors.Join(ErrContractPack, err))
+	owner := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	spender := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	client := &tokenClient{
+		balances:   map[common.Address]*big.Int{owner: big.NewInt(5_000_000)},
+		allowances: map[[2]common.Address]*big.Int{{owner, spender}: big.NewInt(1_000_000)},
 	}
-	token := common.HexToAddress(os.Getenv("USDC"))
-	params := new(ethereum.CallMsg)
-	params.From = from
-	params.To = &token
-	params.Data = input
-	gas, err := client.EstimateGas(ctx, *params)
+	srv, err := NewERC20Service(client, token, big.NewInt(11155111), 6)
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("get gas error"), err))
+		t.Fatal(err)
 	}
-	params.Gas = gas
-	req.Gas = fmt.Sprintf("%d", gas)
-	gasPrice, err := client.SuggestGasPrice(ctx)
+	ctx := context.Background()
+
+	balance, err := srv.BalanceOf(ctx, owner.Hex())
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("get gas price error"), err))
+		t.Fatal(err)
 	}
-	params.GasFeeCap = gasPrice
-	req.GasFeeCap = fmt.Sprintf("%d", gasPrice)
-	gasTipCap, err := client.SuggestGasTipCap(ctx)
-	if err != nil {
-		t.Fatal(errors.Join(errors.New("get gas tip error"), err))
+	if balance.Cmp(big.NewInt(5_000_000)) != 0 {
+		t.Fatalf("unexpected balance %s", balance)
 	}
-	params.GasTipCap = gasTipCap
-	req.GasTipCap = fmt.Sprintf("%d", gasTipCap)
-	chain, err := client.ChainID(ctx)
-	if err != nil {
-		t.Fatal(errors.Join(errors.New("get chain id error"), err))
+	if _, err := srv.BalanceOf(ctx, "0x1234"); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("expected invalid address, got %v", err)
 	}
 
-	tx := types.NewTx(&types.DynamicFeeTx{
-		Nonce:     nonce,
-		GasTipCap: params.GasTipCap,
-		GasFeeCap: params.GasFeeCap,
-		Gas:       params.Gas,
-		To:        params.To,
-		Data:      params.Data,
-		Value:     params.Value,
-	})
-	signer := types.NewLondonSigner(chain)
-	signTx, err := types.SignTx(tx, signer, privateKey)
+	allowance, err := srv.CheckAllowance(ctx, protos.CheckAllowanceRequest{From: owner.Hex(), To: spender.Hex()})
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("signature error"), err))
+		t.Fatal(err)
+	}
+	if allowance.Cmp(big.NewInt(1_000_000)) != 0 {
+		t.Fatalf("unexpected allowance %s", allowance)
 	}
 
-	v, r, s := signTx.RawSignatureValues()
-	msg, err := utils.GetSignFromDynamicFeeTxType(r, s, v, true)
+	meta, err := srv.Metadata(ctx)
 	if err != nil {
-		t.Fatal(errors.Join(errors.New("get raw signature error"), err))
+		t.Fatal(err)
 	}
-	req.Signature = hexutil.Encode(msg)
-	_, err = NewERC20Service(client, usdc, chain, 6).TransferWithSign(ctx, req)
-	if err != nil {
-		t.Fatal(errors.Join(errors.New("TransferWithSign error"), err))
+	if meta.Name != "USD Coin" || meta.Symbol != "USDC" || meta.Decimals != 6 || meta.TotalSupply.Cmp(big.NewInt(1e12)) != 0 {
+		t.Fatalf("unexpected metadata %+v", meta)
 	}
 }
@@ -0,0 +1,173 @@
+package erc20
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// CLEF_SIGN_TRANSACTION - the method of clef signing a transaction
+	CLEF_SIGN_TRANSACTION = "account_signTransaction"
+	// ETH_SIGN_TRANSACTION - the method of web3signer signing a transaction
+	ETH_SIGN_TRANSACTION = "eth_signTransaction"
+)
+
+// Signer - sign the transactions of a merchant address, the callers never
+// hold the key.
+type Signer interface {
+	// Address - the address of the signer.
+	// @return address
+	Address() common.Address
+	// SignTx - sign the transaction for the chain.
+	// @param ctx - context
+	// @param tx - unsigned transaction
+	// @param chainId - chain id
+	// @return signed transaction
+	// @return error
+	SignTx(ctx context.Context, tx *types.Transaction, chainId *big.Int) (*types.Transaction, error)
+}
+
+type keySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewKeySigner - sign with a key in memory, for the tests and the keys
+// derived at runtime.
+func NewKeySigner(key *ecdsa.PrivateKey) Signer {
+	return &keySigner{
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey),
+	}
+}
+
+func (s *keySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *keySigner) SignTx(ctx context.Context, tx *types.Transaction, chainId *big.Int) (*types.Transaction, error) {
+	signTx, err := types.SignTx(tx, types.NewLondonSigner(chainId), s.key)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	return signTx, nil
+}
+
+// NewKeystoreSigner - sign with the key of an encrypted keystore file of
+// go-ethereum, the key is decrypted once with the passphrase.
+func NewKeystoreSigner(keyjson []byte, passphrase string) (Signer, error) {
+	key, err := keystore.DecryptKey(keyjson, passphrase)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	return NewKeySigner(key.PrivateKey), nil
+}
+
+type remoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+	method  string
+}
+
+// remoteTx - the transaction arguments of clef and web3signer.
+type remoteTx struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainId              *hexutil.Big    `json:"chainId"`
+}
+
+// NewRemoteSigner - sign with a remote signer over json-rpc, the method is
+// CLEF_SIGN_TRANSACTION for clef and ETH_SIGN_TRANSACTION for web3signer,
+// empty is clef.
+func NewRemoteSigner(ctx context.Context, url string, address common.Address, method string) (Signer, error) {
+	if method == "" {
+		method = CLEF_SIGN_TRANSACTION
+	}
+	if method != CLEF_SIGN_TRANSACTION && method != ETH_SIGN_TRANSACTION {
+		return nil, errors.Join(ErrSign, fmt.Errorf("method %s is not supported", method))
+	}
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	return &remoteSigner{
+		client:  client,
+		address: address,
+		method:  method,
+	}, nil
+}
+
+func (s *remoteSigner) Address() common.Address {
+	return s.address
+}
+
+// SignTx - the signed transaction must be the transaction signed by the
+// address, the remote signer is not trusted to change it.
+func (s *remoteSigner) SignTx(ctx context.Context, tx *types.Transaction, chainId *big.Int) (*types.Transaction, error) {
+	args := remoteTx{
+		From:    s.address,
+		To:      tx.To(),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   (*hexutil.Big)(tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainId: (*hexutil.Big)(chainId),
+	}
+	if tx.Type() == types.LegacyTxType {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	var result json.RawMessage
+	if err := s.client.CallContext(ctx, &result, s.method, args); err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	// clef returns the raw transaction in an object, web3signer returns it alone
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err != nil {
+		var res struct {
+			Raw hexutil.Bytes `json:"raw"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			return nil, errors.Join(ErrSign, err)
+		}
+		raw = res.Raw
+	}
+	signTx := new(types.Transaction)
+	if err := signTx.UnmarshalBinary(raw); err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+
+	signer := types.NewLondonSigner(chainId)
+	if signer.Hash(signTx) != signer.Hash(tx) {
+		return nil, errors.Join(ErrSign, fmt.Errorf("remote signer changed the transaction"))
+	}
+	from, err := types.Sender(signer, signTx)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	if from != s.address {
+		return nil, errors.Join(ErrInvalidSignature, fmt.Errorf("signed by %s, not %s", from, s.address))
+	}
+	return signTx, nil
+}
@@ -0,0 +1,168 @@
+package erc20
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/google/uuid"
+)
+
+// remoteApi - a clef or web3signer signing the transactions with the key,
+// the nonce is shifted to play a signer changing the transaction.
+type remoteApi struct {
+	key   *ecdsa.PrivateKey
+	shift uint64
+}
+
+func (a *remoteApi) sign(args remoteTx) (hexutil.Bytes, error) {
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   (*big.Int)(args.ChainId),
+		Nonce:     uint64(args.Nonce) + a.shift,
+		GasTipCap: (*big.Int)(args.MaxPriorityFeePerGas),
+		GasFeeCap: (*big.Int)(args.MaxFeePerGas),
+		Gas:       uint64(args.Gas),
+		To:        args.To,
+		Value:     (*big.Int)(args.Value),
+		Data:      args.Data,
+	})
+	signTx, err := types.SignTx(tx, types.NewLondonSigner((*big.Int)(args.ChainId)), a.key)
+	if err != nil {
+		return nil, err
+	}
+	return signTx.MarshalBinary()
+}
+
+type clefApi struct{ *remoteApi }
+
+func (a *clefApi) SignTransaction(args remoteTx) (map[string]interface{}, error) {
+	raw, err := a.sign(args)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"raw": raw}, nil
+}
+
+type web3signerApi struct{ *remoteApi }
+
+func (a *web3signerApi) SignTransaction(args remoteTx) (hexutil.Bytes, error) {
+	return a.sign(args)
+}
+
+func unsignedTx() *types.Transaction {
+	to := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	return types.NewTx(&types.DynamicFeeTx{
+		Nonce:     3,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(3e9),
+		Gas:       60000,
+		To:        &to,
+		Data:      []byte{0xa9, 0x05, 0x9c, 0xbb},
+	})
+}
+
+func checkSigned(t *testing.T, signer Signer, chainId *big.Int) {
+	t.Helper()
+	tx := unsignedTx()
+	signTx, err := signer.SignTx(context.Background(), tx, chainId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := types.Sender(types.NewLondonSigner(chainId), signTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != signer.Address() || signTx.Nonce() != tx.Nonce() || signTx.ChainId().Cmp(chainId) != 0 {
+		t.Fatalf("unexpected signed transaction from %s nonce %d chain %s", from, signTx.Nonce(), signTx.ChainId())
+	}
+}
+
+func TestKeystoreSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyjson, err := keystore.EncryptKey(&keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeystoreSigner(keyjson, "wrong"); !errors.Is(err, ErrSign) {
+		t.Fatalf("wrong passphrase should fail, got %v", err)
+	}
+	signer, err := NewKeystoreSigner(keyjson, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.Address() != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("unexpected address %s", signer.Address())
+	}
+	checkSigned(t, signer, big.NewInt(11155111))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainId := big.NewInt(11155111)
+	ctx := context.Background()
+
+	remote := &remoteApi{key: key}
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &clefApi{remote}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("eth", &web3signerApi{remote}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	for _, method := range []string{"", ETH_SIGN_TRANSACTION} {
+		signer, err := NewRemoteSigner(ctx, srv.URL, address, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSigned(t, signer, chainId)
+	}
+
+	signer, err := NewRemoteSigner(ctx, srv.URL, crypto.PubkeyToAddress(other.PublicKey), CLEF_SIGN_TRANSACTION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.SignTx(ctx, unsignedTx(), chainId); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("other signer should fail, got %v", err)
+	}
+
+	remote.shift = 1
+	signer, err = NewRemoteSigner(ctx, srv.URL, address, CLEF_SIGN_TRANSACTION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.SignTx(ctx, unsignedTx(), chainId); !errors.Is(err, ErrSign) {
+		t.Fatalf("changed transaction should fail, got %v", err)
+	}
+
+	if _, err := NewRemoteSigner(ctx, srv.URL, address, "personal_sign"); !errors.Is(err, ErrSign) {
+		t.Fatalf("unsupported method should fail, got %v", err)
+	}
+}
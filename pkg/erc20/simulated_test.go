@@ -0,0 +1,281 @@
+//go:build simulated
+
+package erc20
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain/chaintest"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/contract"
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// simulatedToken - a chain with three accounts and a token of 6 decimals
+// deployed by the first one.
+func simulatedToken(t *testing.T) (*chaintest.Backend, ERC20Service) {
+	t.Helper()
+	os.Setenv("ERC20", "./../../deployment/abi/erc-20.json")
+	b := chaintest.NewBackend(t, 3)
+	address := b.Deploy(t, 0, chaintest.Token{Name: "USD Coin", Symbol: "USDC", Decimals: 6, Supply: big.NewInt(1e12)})
+	token, err := contract.CreateContract("ERC20", address.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewERC20Service(b.Client, token, b.ChainId, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, srv
+}
+
+func checkBalance(t *testing.T, srv ERC20Service, address string, want int64) {
+	t.Helper()
+	balance, err := srv.BalanceOf(context.Background(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(big.NewInt(want)) != 0 {
+		t.Fatalf("balance of %s is %s, not %d", address, balance, want)
+	}
+}
+
+func TestSimulatedTransfer(t *testing.T) {
+	b, srv := simulatedToken(t)
+	ctx := context.Background()
+	owner, buyer := b.Address(0).Hex(), b.Address(1).Hex()
+
+	meta, err := srv.Metadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Symbol != "USDC" || meta.Decimals != 6 || meta.TotalSupply.Cmp(big.NewInt(1e12)) != 0 {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	checkBalance(t, srv, owner, 1e12)
+
+	tx, err := srv.TransferWithPrivateKey(ctx, protos.CommonRequest{From: owner, To: buyer, Amount: 1.5, Nonce: b.Nonce(t, 0)}, b.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt := b.Mine(t, tx); receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("transfer failed")
+	}
+	checkBalance(t, srv, owner, 1e12-1_500_000)
+	checkBalance(t, srv, buyer, 1_500_000)
+
+	// the balance is short, the transfer is not estimated
+	if _, err := srv.TransferWithPrivateKey(ctx, protos.CommonRequest{From: buyer, To: owner, Amount: 2, Nonce: b.Nonce(t, 1)}, b.Accounts[1]); err == nil {
+		t.Fatal("expected the transfer over the balance to fail")
+	}
+}
+
+func TestSimulatedApprove(t *testing.T) {
+	b, srv := simulatedToken(t)
+	ctx := context.Background()
+	owner, spender, recipient := b.Address(0).Hex(), b.Address(1).Hex(), b.Address(2).Hex()
+
+	tx, err := srv.ApproveWithPrivateKey(ctx, protos.CommonRequest{From: owner, To: spender, Amount: 10, Nonce: b.Nonce(t, 0)}, b.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt := b.Mine(t, tx); receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("approve failed")
+	}
+	allowance, err := srv.CheckAllowance(ctx, protos.CheckAllowanceRequest{From: owner, To: spender})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowance.Cmp(big.NewInt(10_000_000)) != 0 {
+		t.Fatalf("unexpected allowance %s", allowance)
+	}
+
+	data, err := srv.GetABI().Pack("transferFrom", b.Address(0), b.Address(2), big.NewInt(4_000_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := srv.(*service).contract.Address
+	if receipt := b.Mine(t, b.Send(t, 1, &address, nil, data)); receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("transfer from failed")
+	}
+	checkBalance(t, srv, recipient, 4_000_000)
+	allowance, err = srv.CheckAllowance(ctx, protos.CheckAllowanceRequest{From: owner, To: spender})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowance.Cmp(big.NewInt(6_000_000)) != 0 {
+		t.Fatalf("unexpected allowance %s", allowance)
+	}
+
+	// the allowance is short
+	data, err = srv.GetABI().Pack("transferFrom", b.Address(0), b.Address(2), big.NewInt(7_000_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt := b.Mine(t, b.Send(t, 1, &address, nil, data)); receipt.Status != types.ReceiptStatusFailed {
+		t.Fatal("expected the transfer over the allowance to revert")
+	}
+}
+
+func TestSimulatedSignedTransfer(t *testing.T) {
+	b, srv := simulatedToken(t)
+	ctx := context.Background()
+	owner, buyer := b.Address(0).Hex(), b.Address(1).Hex()
+	signer := types.NewLondonSigner(b.ChainId)
+
+	// the wallet signs the prepared transaction
+	prepared, err := srv.PrepareTransfer(ctx, owner, buyer, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := types.SignTx(prepared, signer, b.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, r, s := signed.RawSignatureValues()
+	sig, err := utils.GetSignFromDynamicFeeTxType(r, s, v, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := srv.SignPrepared(prepared, owner, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.CheckSignedTransfer(tx, owner, buyer, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.CheckSignedTransfer(tx, owner, buyer, 3); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("expected invalid amount, got %v", err)
+	}
+	if _, err := srv.CheckSignedTransfer(tx, owner, b.Address(2).Hex(), 2); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("expected invalid address, got %v", err)
+	}
+	if err := srv.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	b.Mine(t, tx)
+	checkBalance(t, srv, buyer, 2_000_000)
+
+	// the wallet signs the transfer of the request
+	nonce := b.Nonce(t, 0)
+	params := *prepared
+	input, err := srv.GetABI().Pack(TRANSFER, b.Address(1), big.NewInt(500_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := types.NewTx(&types.DynamicFeeTx{
+		Nonce:     nonce,
+		GasTipCap: params.GasTipCap(),
+		GasFeeCap: params.GasFeeCap(),
+		Gas:       params.Gas(),
+		To:        params.To(),
+		Data:      input,
+	})
+	signed, err = types.SignTx(unsigned, signer, b.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, r, s = signed.RawSignatureValues()
+	sig, err = utils.GetSignFromDynamicFeeTxType(r, s, v, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := protos.CommonRequest{
+		From:      owner,
+		To:        buyer,
+		Amount:    0.5,
+		Nonce:     nonce,
+		Gas:       fmt.Sprintf("%d", params.Gas()),
+		GasFeeCap: params.GasFeeCap().String(),
+		GasTipCap: params.GasTipCap().String(),
+		Signature: hexutil.Encode(sig),
+	}
+	tx, err = srv.TransferWithSign(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Mine(t, tx)
+	checkBalance(t, srv, buyer, 2_500_000)
+
+	// the signature of another key
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed, err = types.SignTx(prepared, signer, other); err != nil {
+		t.Fatal(err)
+	}
+	v, r, s = signed.RawSignatureValues()
+	if sig, err = utils.GetSignFromDynamicFeeTxType(r, s, v, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.SignPrepared(prepared, owner, sig); err == nil {
+		t.Fatal("expected the signature of another key to be rejected")
+	}
+}
+
+func TestSimulatedSubscribeTransfer(t *testing.T) {
+	b, srv := simulatedToken(t)
+	ctx := context.Background()
+	owner, buyer := b.Address(0).Hex(), b.Address(1).Hex()
+
+	logs := make(chan types.Log, 1)
+	stop, errChan := srv.SubscribeTransfer(func(vLog types.Log) { logs <- vLog }, big.NewInt(0))
+
+	tx, err := srv.TransferWithPrivateKey(ctx, protos.CommonRequest{From: owner, To: buyer, Amount: 3, Nonce: b.Nonce(t, 0)}, b.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Mine(t, tx)
+
+	received := func(tx *types.Transaction, to int, value int64) {
+		t.Helper()
+		select {
+		case vLog := <-logs:
+			if vLog.TxHash != tx.Hash() {
+				t.Fatalf("unexpected log of %s", vLog.TxHash)
+			}
+			transfer, err := contract.ParseTransferLog(srv.GetABI(), vLog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transfer.From != b.Address(0) || transfer.To != b.Address(to) || transfer.Value.Cmp(big.NewInt(value)) != 0 {
+				t.Fatalf("unexpected transfer %+v", transfer)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("transfer log not received")
+		}
+	}
+	received(tx, 1, 3_000_000)
+	stop()
+
+	// the filter only matches the transfers to the last account
+	logs = make(chan types.Log, 2)
+	filter := ethereum.FilterQuery{Topics: [][]common.Hash{
+		{srv.GetABI().Events[EVENT_TRANSFER].ID}, nil, {common.BytesToHash(b.Address(2).Bytes())}}}
+	stop, errChan = srv.SubscribeTransferWithFilter(func(vLog types.Log) { logs <- vLog }, big.NewInt(0), filter)
+	defer stop()
+
+	if tx, err = srv.TransferWithPrivateKey(ctx, protos.CommonRequest{From: owner, To: buyer, Amount: 1, Nonce: b.Nonce(t, 0)}, b.Accounts[0]); err != nil {
+		t.Fatal(err)
+	}
+	b.Mine(t, tx)
+	if tx, err = srv.TransferWithPrivateKey(ctx, protos.CommonRequest{From: owner, To: b.Address(2).Hex(), Amount: 2, Nonce: b.Nonce(t, 0)}, b.Accounts[0]); err != nil {
+		t.Fatal(err)
+	}
+	b.Mine(t, tx)
+	received(tx, 2, 2_000_000)
+}
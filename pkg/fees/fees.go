@@ -0,0 +1,146 @@
+package fees
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum"
+)
+
+var (
+	// ErrEthClient is returned when ethereum client error.
+	ErrEthClient = errors.New("ethereum client error")
+
+	// Percentiles - the tip percentiles of the slow, standard and fast fees.
+	Percentiles = []float64{10, 50, 90}
+)
+
+const (
+	// DefaultTTL - the fees are cached about a block.
+	DefaultTTL = 12 * time.Second
+	// HistoryBlocks - the blocks of the fee history.
+	HistoryBlocks uint64 = 20
+)
+
+// Fee - the EIP-1559 fees of a transaction in wei.
+type Fee struct {
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
+// Suggestion - the base fee of the next block and the fees by speed. The
+// fee caps leave room for the base fee to double before the transaction is
+// mined.
+type Suggestion struct {
+	BaseFee  *big.Int
+	Slow     Fee
+	Standard Fee
+	Fast     Fee
+}
+
+// Estimator - suggest the fees of a chain from the tips paid in the recent
+// blocks, a suggestion is cached for the ttl.
+type Estimator struct {
+	client chain.Client
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	cached    *Suggestion
+	expiredAt time.Time
+}
+
+func NewEstimator(client chain.Client, ttl time.Duration) *Estimator {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Estimator{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// Suggest - the cached suggestion, it is built again once it expires.
+func (e *Estimator) Suggest(ctx context.Context) (*Suggestion, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cached != nil && time.Now().Before(e.expiredAt) {
+		return e.cached, nil
+	}
+	history, err := e.client.FeeHistory(ctx, HistoryBlocks, nil, Percentiles)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	suggestion, ok := Suggest(history)
+	if !ok {
+		// the recent blocks are empty, every speed pays the suggested tip
+		tip, err := e.client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, errors.Join(ErrEthClient, err)
+		}
+		fee := withTip(tip, suggestion.BaseFee)
+		suggestion.Slow, suggestion.Standard, suggestion.Fast = fee, fee, fee
+	}
+	e.cached, e.expiredAt = suggestion, time.Now().Add(e.ttl)
+	return suggestion, nil
+}
+
+// Suggest - the tip of each speed is the median of its percentile over the
+// blocks which have transactions. ok is false when none of them has.
+func Suggest(history *ethereum.FeeHistory) (*Suggestion, bool) {
+	suggestion := &Suggestion{BaseFee: big.NewInt(0)}
+	if n := len(history.BaseFee); n > 0 {
+		// the last base fee is the one of the next block
+		suggestion.BaseFee = new(big.Int).Set(history.BaseFee[n-1])
+	}
+	tips := make([][]*big.Int, len(Percentiles))
+	for i, rewards := range history.Reward {
+		if i < len(history.GasUsedRatio) && history.GasUsedRatio[i] == 0 {
+			continue
+		}
+		if len(rewards) != len(Percentiles) {
+			continue
+		}
+		for p, reward := range rewards {
+			tips[p] = append(tips[p], reward)
+		}
+	}
+	if len(tips[0]) == 0 {
+		return suggestion, false
+	}
+	fees := make([]Fee, len(Percentiles))
+	var previous *big.Int
+	for p := range Percentiles {
+		tip := median(tips[p])
+		// a faster speed never pays less
+		if previous != nil && tip.Cmp(previous) < 0 {
+			tip = previous
+		}
+		fees[p], previous = withTip(tip, suggestion.BaseFee), tip
+	}
+	suggestion.Slow, suggestion.Standard, suggestion.Fast = fees[0], fees[1], fees[2]
+	return suggestion, true
+}
+
+func withTip(tip, baseFee *big.Int) Fee {
+	return Fee{
+		GasTipCap: new(big.Int).Set(tip),
+		GasFeeCap: new(big.Int).Add(tip, new(big.Int).Mul(baseFee, big.NewInt(2))),
+	}
+}
+
+func median(values []*big.Int) *big.Int {
+	sorted := make([]*big.Int, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return new(big.Int).Set(sorted[mid])
+	}
+	sum := new(big.Int).Add(sorted[mid-1], sorted[mid])
+	return sum.Div(sum, big.NewInt(2))
+}
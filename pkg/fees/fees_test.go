@@ -0,0 +1,103 @@
+package fees
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum"
+)
+
+func rewards(tips ...int64) []*big.Int {
+	out := make([]*big.Int, len(tips))
+	for i, tip := range tips {
+		out[i] = big.NewInt(tip)
+	}
+	return out
+}
+
+func TestSuggest(t *testing.T) {
+	history := &ethereum.FeeHistory{
+		Reward: [][]*big.Int{
+			rewards(1e9, 2e9, 5e9),
+			rewards(0, 0, 0),
+			rewards(3e9, 2e9, 4e9),
+			rewards(2e9, 3e9, 3e9),
+		},
+		BaseFee:      rewards(9e9, 10e9, 11e9, 12e9, 10e9),
+		GasUsedRatio: []float64{0.5, 0, 0.4, 0.6},
+	}
+	suggestion, ok := Suggest(history)
+	if !ok {
+		t.Fatal("expected a suggestion")
+	}
+	if suggestion.BaseFee.Int64() != 10e9 {
+		t.Fatalf("unexpected base fee %s", suggestion.BaseFee)
+	}
+	// the empty block is skipped, the medians are 2, 2 and 4 gwei
+	for name, c := range map[string]struct {
+		fee       Fee
+		tip, fcap int64
+	}{
+		"slow":     {suggestion.Slow, 2e9, 22e9},
+		"standard": {suggestion.Standard, 2e9, 22e9},
+		"fast":     {suggestion.Fast, 4e9, 24e9},
+	} {
+		if c.fee.GasTipCap.Int64() != c.tip || c.fee.GasFeeCap.Int64() != c.fcap {
+			t.Fatalf("%s: got tip %s fee cap %s", name, c.fee.GasTipCap, c.fee.GasFeeCap)
+		}
+	}
+
+	if _, ok := Suggest(&ethereum.FeeHistory{
+		Reward:       [][]*big.Int{rewards(0, 0, 0)},
+		BaseFee:      rewards(1e9, 1e9),
+		GasUsedRatio: []float64{0},
+	}); ok {
+		t.Fatal("empty blocks should not suggest")
+	}
+}
+
+// historyClient - a client counting the fee history calls.
+type historyClient struct {
+	chain.Client
+	calls int
+}
+
+func (c *historyClient) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, percentiles []float64) (*ethereum.FeeHistory, error) {
+	c.calls++
+	return &ethereum.FeeHistory{
+		Reward:       [][]*big.Int{rewards(0, 0, 0)},
+		BaseFee:      rewards(1e9, 1e9),
+		GasUsedRatio: []float64{0},
+	}, nil
+}
+
+func (c *historyClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e8), nil
+}
+
+func TestEstimatorCache(t *testing.T) {
+	client := &historyClient{}
+	e := NewEstimator(client, 50*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		suggestion, err := e.Suggest(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if suggestion.Fast.GasTipCap.Int64() != 1e8 || suggestion.Fast.GasFeeCap.Int64() != 21e8 {
+			t.Fatalf("expected the suggested tip, got %s %s", suggestion.Fast.GasTipCap, suggestion.Fast.GasFeeCap)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("expected one fee history call, got %d", client.calls)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := e.Suggest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 2 {
+		t.Fatalf("expired suggestion should be built again, got %d calls", client.calls)
+	}
+}
@@ -0,0 +1,69 @@
+package hdwallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var radix = big.NewInt(58)
+
+// encodeCheck - base58 of the payload followed by the first 4 bytes of its
+// double sha256.
+func encodeCheck(payload []byte) string {
+	data := append(append([]byte{}, payload...), checksum(payload)...)
+	num := new(big.Int).SetBytes(data)
+	mod := new(big.Int)
+	var out []byte
+	for num.Sign() > 0 {
+		num.DivMod(num, radix, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// decodeCheck - the payload of a base58 string, the checksum must match.
+func decodeCheck(s string) ([]byte, error) {
+	num := new(big.Int)
+	for _, c := range s {
+		idx := strings.IndexRune(alphabet, c)
+		if idx < 0 {
+			return nil, errors.Join(ErrInvalidKey, fmt.Errorf("invalid base58 character %q", c))
+		}
+		num.Mul(num, radix)
+		num.Add(num, big.NewInt(int64(idx)))
+	}
+	var zeros int
+	for zeros < len(s) && s[zeros] == alphabet[0] {
+		zeros++
+	}
+	data := append(make([]byte, zeros), num.Bytes()...)
+	if len(data) < 4 {
+		return nil, errors.Join(ErrInvalidKey, fmt.Errorf("key is too short"))
+	}
+	payload, sum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(checksum(payload), sum) {
+		return nil, errors.Join(ErrInvalidKey, fmt.Errorf("checksum mismatch"))
+	}
+	return payload, nil
+}
+
+func checksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:4]
+}
@@ -0,0 +1,239 @@
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// HardenedOffset - the first index of the hardened children, they can only
+// be derived from a private key.
+const HardenedOffset uint32 = 0x80000000
+
+// serializedLen - version, depth, parent fingerprint, child number, chain
+// code and key of BIP-32.
+const serializedLen = 78
+
+var (
+	// ErrInvalidKey is returned when the extended key cannot be parsed.
+	ErrInvalidKey = errors.New("invalid extended key")
+
+	// ErrHardenedPublic is returned when a hardened child is derived from a public key.
+	ErrHardenedPublic = errors.New("cannot derive a hardened child from a public key")
+
+	// ErrDerive is returned when the child key is invalid, the next index should be used.
+	ErrDerive = errors.New("invalid child key")
+)
+
+// versions - the private and the public versions of the mainnet and the testnet.
+var versions = map[[4]byte][4]byte{
+	{0x04, 0x88, 0xad, 0xe4}: {0x04, 0x88, 0xb2, 0x1e}, // xprv -> xpub
+	{0x04, 0x35, 0x83, 0x94}: {0x04, 0x35, 0x87, 0xcf}, // tprv -> tpub
+}
+
+// ExtendedKey - a BIP-32 node, the key is the 33-byte compressed public key
+// or the 32-byte private key.
+type ExtendedKey struct {
+	version   [4]byte
+	depth     uint8
+	parentFP  [4]byte
+	childNum  uint32
+	chainCode []byte
+	key       []byte
+	private   bool
+}
+
+// ParseExtendedKey - parse a base58 xpub or xprv.
+func ParseExtendedKey(s string) (*ExtendedKey, error) {
+	data, err := decodeCheck(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != serializedLen {
+		return nil, errors.Join(ErrInvalidKey, fmt.Errorf("length %d", len(data)))
+	}
+	k := &ExtendedKey{
+		depth:     data[4],
+		childNum:  binary.BigEndian.Uint32(data[9:13]),
+		chainCode: data[13:45],
+	}
+	copy(k.version[:], data[:4])
+	copy(k.parentFP[:], data[5:9])
+	_, k.private = versions[k.version]
+	if !k.private && !isPublicVersion(k.version) {
+		return nil, errors.Join(ErrInvalidKey, fmt.Errorf("unknown version %x", k.version))
+	}
+
+	if k.private {
+		if data[45] != 0 {
+			return nil, errors.Join(ErrInvalidKey, fmt.Errorf("private key is not padded"))
+		}
+		k.key = data[46:]
+		num := new(big.Int).SetBytes(k.key)
+		if num.Sign() == 0 || num.Cmp(crypto.S256().Params().N) >= 0 {
+			return nil, errors.Join(ErrInvalidKey, fmt.Errorf("private key is out of range"))
+		}
+		return k, nil
+	}
+	k.key = data[45:]
+	if _, err := crypto.DecompressPubkey(k.key); err != nil {
+		return nil, errors.Join(ErrInvalidKey, err)
+	}
+	return k, nil
+}
+
+func isPublicVersion(version [4]byte) bool {
+	for _, pub := range versions {
+		if pub == version {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivate - whether the private keys of the children can be derived.
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.private
+}
+
+// Child - derive the child at the index, the index is hardened when it is
+// not less than HardenedOffset.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	hardened := index >= HardenedOffset
+	if hardened && !k.private {
+		return nil, ErrHardenedPublic
+	}
+
+	pub := k.publicKey()
+	data := make([]byte, 0, 37)
+	if hardened {
+		data = append(append(data, 0), k.key...)
+	} else {
+		data = append(data, pub...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	curve := crypto.S256()
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.Join(ErrDerive, fmt.Errorf("index %d", index))
+	}
+	child := &ExtendedKey{
+		version:   k.version,
+		depth:     k.depth + 1,
+		childNum:  index,
+		chainCode: sum[32:],
+		private:   k.private,
+	}
+	copy(child.parentFP[:], hash160(pub)[:4])
+
+	if k.private {
+		num := il.Add(il, new(big.Int).SetBytes(k.key))
+		num.Mod(num, curve.Params().N)
+		if num.Sign() == 0 {
+			return nil, errors.Join(ErrDerive, fmt.Errorf("index %d", index))
+		}
+		child.key = common.LeftPadBytes(num.Bytes(), 32)
+		return child, nil
+	}
+
+	parent, err := crypto.DecompressPubkey(k.key)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidKey, err)
+	}
+	x, y := curve.ScalarBaseMult(sum[:32])
+	x, y = curve.Add(x, y, parent.X, parent.Y)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.Join(ErrDerive, fmt.Errorf("index %d", index))
+	}
+	child.key = crypto.CompressPubkey(&ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+	return child, nil
+}
+
+// Derive - derive the descendant by the indexes from this node.
+func (k *ExtendedKey) Derive(path ...uint32) (*ExtendedKey, error) {
+	var err error
+	child := k
+	for _, index := range path {
+		if child, err = child.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return child, nil
+}
+
+// Neuter - the public node of the key, it derives the same addresses
+// without the private keys.
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	if !k.private {
+		return k
+	}
+	return &ExtendedKey{
+		version:   versions[k.version],
+		depth:     k.depth,
+		parentFP:  k.parentFP,
+		childNum:  k.childNum,
+		chainCode: k.chainCode,
+		key:       k.publicKey(),
+	}
+}
+
+// Address - the ethereum address of the node.
+func (k *ExtendedKey) Address() (common.Address, error) {
+	pub, err := crypto.DecompressPubkey(k.publicKey())
+	if err != nil {
+		return common.Address{}, errors.Join(ErrInvalidKey, err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// PrivateKey - the signing key of the node, only a private node has one.
+func (k *ExtendedKey) PrivateKey() (*ecdsa.PrivateKey, error) {
+	if !k.private {
+		return nil, errors.Join(ErrInvalidKey, fmt.Errorf("key is public"))
+	}
+	return crypto.ToECDSA(k.key)
+}
+
+// String - the base58 serialization of the node.
+func (k *ExtendedKey) String() string {
+	data := make([]byte, 0, serializedLen)
+	data = append(data, k.version[:]...)
+	data = append(data, k.depth)
+	data = append(data, k.parentFP[:]...)
+	data = binary.BigEndian.AppendUint32(data, k.childNum)
+	data = append(data, k.chainCode...)
+	if k.private {
+		data = append(data, 0)
+	}
+	data = append(data, k.key...)
+	return encodeCheck(data)
+}
+
+// publicKey - the compressed public key of the node.
+func (k *ExtendedKey) publicKey() []byte {
+	if !k.private {
+		return k.key
+	}
+	x, y := crypto.S256().ScalarBaseMult(k.key)
+	return crypto.CompressPubkey(&ecdsa.PublicKey{Curve: crypto.S256(), X: x, Y: y})
+}
+
+func hash160(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	h := ripemd160.New()
+	h.Write(sum[:])
+	return h.Sum(nil)
+}
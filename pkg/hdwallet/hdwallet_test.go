@@ -0,0 +1,75 @@
+package hdwallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// the test vector 1 of BIP-32
+const (
+	masterPrv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	masterPub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+	accPrv    = "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7"
+	accPub    = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+	childPub  = "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ"
+)
+
+func TestDerive(t *testing.T) {
+	master, err := ParseExtendedKey(masterPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := master.Neuter().String(); got != masterPub {
+		t.Fatalf("unexpected master public key %s", got)
+	}
+	acc, err := master.Child(HardenedOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.String() != accPrv || acc.Neuter().String() != accPub {
+		t.Fatalf("unexpected account key %s", acc)
+	}
+
+	// the public derivation of the xpub matches the private one of the sweeper
+	pub, err := ParseExtendedKey(accPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.IsPrivate() {
+		t.Fatal("xpub should be public")
+	}
+	child, err := pub.Child(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.String() != childPub {
+		t.Fatalf("unexpected child public key %s", child)
+	}
+	signer, err := acc.Child(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := signer.PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := child.Address()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("address %s does not match the private key", address)
+	}
+
+	if _, err := pub.Child(HardenedOffset); !errors.Is(err, ErrHardenedPublic) {
+		t.Fatalf("expected hardened public error, got %v", err)
+	}
+	if _, err := child.PrivateKey(); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected invalid key, got %v", err)
+	}
+	if _, err := ParseExtendedKey(accPub[:len(accPub)-1] + "x"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package native
+
+import "github.com/0x726f6f6b6965/web3-ecommerce/pkg/transfer"
+
+// the errors of the transfers are shared with the tokens
+var (
+	// ErrInvalidAddress is returned when an invalid address is provided.
+	ErrInvalidAddress = transfer.ErrInvalidAddress
+
+	// ErrInvalidAmount is returned when an invalid amount is provided.
+	ErrInvalidAmount = transfer.ErrInvalidAmount
+
+	// ErrInvalidSignature is returned when an invalid signature is provided.
+	ErrInvalidSignature = transfer.ErrInvalidSignature
+
+	// ErrInvalidTransaction is returned when an invalid transaction is provided.
+	ErrInvalidTransaction = transfer.ErrInvalidTransaction
+
+	// ErrEthClient is returned when ethereum client error.
+	ErrEthClient = transfer.ErrEthClient
+
+	// ErrSign is returned when sign error.
+	ErrSign = transfer.ErrSign
+)
@@ -0,0 +1,142 @@
+package native
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/transfer"
+	"github.com/0x726f6f6b6965/web3-ecommerce/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type NativeService interface {
+	// PrepareTransfer - build an unsigned value transfer for the wallet to sign.
+	// @param ctx - context
+	// @param from - sender address
+	// @param to - recipient address
+	// @param value - value in wei
+	// @return transaction
+	// @return error
+	PrepareTransfer(ctx context.Context, from, to string, value *big.Int) (*types.Transaction, error)
+	// EstimateTransfer - estimate the gas of a value transfer from the sender.
+	// @param ctx - context
+	// @param from - sender address
+	// @param to - recipient address
+	// @param value - value in wei
+	// @return gas
+	// @return error
+	EstimateTransfer(ctx context.Context, from, to string, value *big.Int) (uint64, error)
+	// SignPrepared - attach the signature of the sender to a prepared transaction.
+	// @param prepared - prepared transaction
+	// @param from - sender address
+	// @param signature - signature
+	// @return transaction
+	// @return error
+	SignPrepared(prepared *types.Transaction, from string, signature []byte) (*types.Transaction, error)
+	// CheckSignedTransfer - check a signed raw transaction is the expected value transfer.
+	// @param signTx - signed transaction
+	// @param from - sender address
+	// @param to - recipient address
+	// @param value - value in wei
+	// @return transaction
+	// @return error
+	CheckSignedTransfer(signTx *types.Transaction, from, to string, value *big.Int) (*types.Transaction, error)
+	// SendTransaction - broadcast a signed transaction.
+	// @param ctx - context
+	// @param tx - signed transaction
+	// @return error
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	// BalanceOf - get balance of an address.
+	// @param ctx - context
+	// @param address - address
+	// @return balance
+	// @return error
+	BalanceOf(ctx context.Context, address string) (*big.Int, error)
+}
+
+// Currency - the native currency of the chain.
+type Currency struct {
+	Symbol   string
+	Decimals int
+	Service  NativeService
+}
+
+type service struct {
+	client  chain.Client
+	chainId *big.Int
+}
+
+func NewNativeService(client chain.Client, chainId *big.Int) NativeService {
+	return &service{
+		client:  client,
+		chainId: chainId,
+	}
+}
+
+// transferOf - the value transfer from the sender.
+func transferOf(from, to string, value *big.Int) (transfer.Transfer, error) {
+	if utils.IsEmpty(from) || !utils.IsValidAddress(from) {
+		return transfer.Transfer{}, errors.Join(ErrInvalidAddress, fmt.Errorf("from address is invalid"))
+	}
+	if utils.IsEmpty(to) || !utils.IsValidAddress(to) {
+		return transfer.Transfer{}, errors.Join(ErrInvalidAddress, fmt.Errorf("to address is invalid"))
+	}
+	if value == nil || value.Sign() <= 0 {
+		return transfer.Transfer{}, ErrInvalidAmount
+	}
+	return transfer.Transfer{
+		From:  common.HexToAddress(from),
+		To:    common.HexToAddress(to),
+		Value: value,
+	}, nil
+}
+
+func (s *service) EstimateTransfer(ctx context.Context, from, to string, value *big.Int) (uint64, error) {
+	t, err := transferOf(from, to, value)
+	if err != nil {
+		return 0, err
+	}
+	return transfer.Estimate(ctx, s.client, t)
+}
+
+func (s *service) PrepareTransfer(ctx context.Context, from, to string, value *big.Int) (*types.Transaction, error) {
+	t, err := transferOf(from, to, value)
+	if err != nil {
+		return nil, err
+	}
+	return transfer.Prepare(ctx, s.client, s.chainId, t)
+}
+
+func (s *service) SignPrepared(prepared *types.Transaction, from string, signature []byte) (*types.Transaction, error) {
+	return transfer.SignPrepared(s.chainId, prepared, from, signature)
+}
+
+func (s *service) CheckSignedTransfer(signTx *types.Transaction, from, to string, value *big.Int) (*types.Transaction, error) {
+	t, err := transferOf(from, to, value)
+	if err != nil {
+		return nil, err
+	}
+	return transfer.CheckSigned(s.chainId, signTx, t)
+}
+
+func (s *service) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := s.client.SendTransaction(ctx, tx); err != nil {
+		return errors.Join(ErrEthClient, err)
+	}
+	return nil
+}
+
+func (s *service) BalanceOf(ctx context.Context, address string) (*big.Int, error) {
+	if utils.IsEmpty(address) || !utils.IsValidAddress(address) {
+		return nil, ErrInvalidAddress
+	}
+	balance, err := s.client.BalanceAt(ctx, common.HexToAddress(address), nil)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	return balance, nil
+}
@@ -0,0 +1,49 @@
+package native
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestCheckSignedTransfer(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(privateKey.PublicKey)
+	to := common.HexToAddress("0x8ba1f109551bD432803012645Ac136ddd64DBA72")
+	chainId := big.NewInt(11155111)
+	value := big.NewInt(5e17)
+
+	signTx, err := types.SignNewTx(privateKey, types.NewLondonSigner(chainId), &types.DynamicFeeTx{
+		ChainID:   chainId,
+		Nonce:     1,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(2e9),
+		Gas:       21000,
+		To:        &to,
+		Value:     value,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewNativeService(nil, chainId)
+	if _, err := srv.CheckSignedTransfer(signTx, from.Hex(), to.Hex(), value); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.CheckSignedTransfer(signTx, from.Hex(), from.Hex(), value); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("expected invalid address, got %v", err)
+	}
+	if _, err := srv.CheckSignedTransfer(signTx, from.Hex(), to.Hex(), big.NewInt(1)); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("expected invalid amount, got %v", err)
+	}
+	if _, err := srv.CheckSignedTransfer(signTx, to.Hex(), to.Hex(), value); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	DECIMALS          = "decimals"
+	LATEST_ROUND_DATA = "latestRoundData"
+
+	// aggregatorABI - the view functions of the AggregatorV3Interface.
+	aggregatorABI = `[
+	{"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"latestRoundData","outputs":[{"name":"roundId","type":"uint80"},{"name":"answer","type":"int256"},{"name":"startedAt","type":"uint256"},{"name":"updatedAt","type":"uint256"},{"name":"answeredInRound","type":"uint80"}],"stateMutability":"view","type":"function"}
+]`
+)
+
+// ChainlinkOracle - read the prices from the Chainlink aggregators.
+type ChainlinkOracle struct {
+	client chain.Client
+	abi    abi.ABI
+	feeds  map[string]common.Address
+	maxAge time.Duration
+}
+
+// NewChainlinkOracle - the feeds are the aggregator addresses keyed by
+// pair, e.g. "ETH/USD", a price older than the max age is rejected.
+func NewChainlinkOracle(client chain.Client, feeds map[string]string, maxAge time.Duration) (*ChainlinkOracle, error) {
+	aggregator, err := abi.JSON(strings.NewReader(aggregatorABI))
+	if err != nil {
+		return nil, err
+	}
+	o := &ChainlinkOracle{
+		client: client,
+		abi:    aggregator,
+		feeds:  make(map[string]common.Address, len(feeds)),
+		maxAge: maxAge,
+	}
+	for key, address := range feeds {
+		symbol, currency, _ := strings.Cut(key, "/")
+		if symbol == "" || currency == "" || !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid feed %s: %s", key, address)
+		}
+		o.feeds[Pair(symbol, currency)] = common.HexToAddress(address)
+	}
+	return o, nil
+}
+
+func (o *ChainlinkOracle) Price(ctx context.Context, symbol, currency string) (float64, error) {
+	feed, ok := o.feeds[Pair(symbol, currency)]
+	if !ok {
+		return 0, errors.Join(ErrPriceNotFound, fmt.Errorf("pair %s", Pair(symbol, currency)))
+	}
+
+	out, err := o.call(ctx, feed, DECIMALS)
+	if err != nil {
+		return 0, err
+	}
+	decimals, ok := out[0].(uint8)
+	if !ok {
+		return 0, fmt.Errorf("unexpected decimals %v", out[0])
+	}
+
+	out, err = o.call(ctx, feed, LATEST_ROUND_DATA)
+	if err != nil {
+		return 0, err
+	}
+	answer, ok := out[1].(*big.Int)
+	if !ok || answer.Sign() <= 0 {
+		return 0, errors.Join(ErrInvalidPrice, fmt.Errorf("answer %v", out[1]))
+	}
+	updatedAt, ok := out[3].(*big.Int)
+	if !ok {
+		return 0, fmt.Errorf("unexpected updated at %v", out[3])
+	}
+	if o.maxAge > 0 && time.Since(time.Unix(updatedAt.Int64(), 0)) > o.maxAge {
+		return 0, errors.Join(ErrStalePrice, fmt.Errorf("pair %s updated at %s", Pair(symbol, currency), updatedAt))
+	}
+
+	price, _ := decimal.NewFromBigInt(answer, -int32(decimals)).Float64()
+	return price, nil
+}
+
+func (o *ChainlinkOracle) call(ctx context.Context, feed common.Address, method string) ([]interface{}, error) {
+	input, err := o.abi.Pack(method)
+	if err != nil {
+		return nil, err
+	}
+	data, err := o.client.CallContract(ctx, ethereum.CallMsg{To: &feed, Data: input}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return o.abi.Unpack(method, data)
+}
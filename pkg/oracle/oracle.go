@@ -0,0 +1,64 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+var (
+	// ErrPriceNotFound is returned when the oracle has no price of the pair.
+	ErrPriceNotFound = errors.New("price not found")
+
+	// ErrInvalidPrice is returned when the price is not positive.
+	ErrInvalidPrice = errors.New("invalid price")
+
+	// ErrStalePrice is returned when the price is older than the max age.
+	ErrStalePrice = errors.New("stale price")
+)
+
+type Oracle interface {
+	// Price - the price of one unit of the token in the fiat currency.
+	// @param ctx - context
+	// @param symbol - token symbol
+	// @param currency - fiat currency
+	// @return price
+	// @return error
+	Price(ctx context.Context, symbol, currency string) (float64, error)
+}
+
+// Pair - the key of the price of the token in the currency, e.g. ETH/USD.
+func Pair(symbol, currency string) string {
+	return fmt.Sprintf("%s/%s", strings.ToUpper(symbol), strings.ToUpper(currency))
+}
+
+type fallback struct {
+	oracles []Oracle
+}
+
+// NewFallback - ask the oracles in order, the first price found is used.
+// Every price taken from an oracle after the first is logged with the
+// errors of the oracles before it.
+func NewFallback(oracles ...Oracle) Oracle {
+	return &fallback{oracles: oracles}
+}
+
+func (f *fallback) Price(ctx context.Context, symbol, currency string) (float64, error) {
+	errs := make([]error, 0, len(f.oracles))
+	for i, o := range f.oracles {
+		price, err := o.Price(ctx, symbol, currency)
+		if err == nil {
+			if i > 0 {
+				log.Printf("oracle: price of %s from fallback %d: %s", Pair(symbol, currency), i, errors.Join(errs...))
+			}
+			return price, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return 0, errors.Join(ErrPriceNotFound, fmt.Errorf("pair %s", Pair(symbol, currency)))
+	}
+	return 0, errors.Join(errs...)
+}
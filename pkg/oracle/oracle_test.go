@@ -0,0 +1,122 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum"
+)
+
+// feedClient - a client answering the calls of an aggregator.
+type feedClient struct {
+	chain.Client
+	oracle    *ChainlinkOracle
+	answer    *big.Int
+	updatedAt time.Time
+}
+
+func (c *feedClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	method, err := c.oracle.abi.MethodById(call.Data)
+	if err != nil {
+		return nil, err
+	}
+	if method.Name == DECIMALS {
+		return method.Outputs.Pack(uint8(8))
+	}
+	return method.Outputs.Pack(big.NewInt(1), c.answer, big.NewInt(0), big.NewInt(c.updatedAt.Unix()), big.NewInt(1))
+}
+
+func TestStaticOracle(t *testing.T) {
+	o, err := NewStaticOracle(map[string]float64{"eth/usd": 3000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	price, err := o.Price(context.Background(), "ETH", "usd")
+	if err != nil || price != 3000 {
+		t.Fatalf("unexpected price %v, %v", price, err)
+	}
+	if _, err := o.Price(context.Background(), "ETH", "EUR"); !errors.Is(err, ErrPriceNotFound) {
+		t.Fatalf("expected price not found, got %v", err)
+	}
+	if _, err := NewStaticOracle(map[string]float64{"ETH/USD": 0}); !errors.Is(err, ErrInvalidPrice) {
+		t.Fatalf("expected invalid price, got %v", err)
+	}
+	if _, err := NewStaticOracle(map[string]float64{"ETH": 1}); !errors.Is(err, ErrInvalidPrice) {
+		t.Fatalf("expected invalid pair, got %v", err)
+	}
+}
+
+func TestChainlinkOracle(t *testing.T) {
+	client := &feedClient{answer: big.NewInt(300012345678), updatedAt: time.Now()}
+	o, err := NewChainlinkOracle(client, map[string]string{"ETH/USD": "0x694AA1769357215DE4FAC081bf1f309aDC325306"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.oracle = o
+
+	price, err := o.Price(context.Background(), "eth", "usd")
+	if err != nil || price != 3000.12345678 {
+		t.Fatalf("unexpected price %v, %v", price, err)
+	}
+	client.updatedAt = time.Now().Add(-2 * time.Hour)
+	if _, err := o.Price(context.Background(), "ETH", "USD"); !errors.Is(err, ErrStalePrice) {
+		t.Fatalf("expected stale price, got %v", err)
+	}
+	client.answer, client.updatedAt = big.NewInt(-1), time.Now()
+	if _, err := o.Price(context.Background(), "ETH", "USD"); !errors.Is(err, ErrInvalidPrice) {
+		t.Fatalf("expected invalid price, got %v", err)
+	}
+	if _, err := o.Price(context.Background(), "BTC", "USD"); !errors.Is(err, ErrPriceNotFound) {
+		t.Fatalf("expected price not found, got %v", err)
+	}
+
+	// the static prices are used when the feed is stale
+	client.answer, client.updatedAt = big.NewInt(300000000000), time.Now().Add(-2*time.Hour)
+	static, _ := NewStaticOracle(map[string]float64{"ETH/USD": 2900})
+	price, err = NewFallback(o, static).Price(context.Background(), "ETH", "USD")
+	if err != nil || price != 2900 {
+		t.Fatalf("unexpected price %v, %v", price, err)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	o, _ := NewStaticOracle(map[string]float64{"ETH/USD": 3000, "USDC/USD": 1})
+	quoter := NewQuoter(o, 15*time.Minute)
+
+	quote, err := quoter.Quote(context.Background(), "ETH", 18, "USD", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Amount != 0.5 || quote.Value != "500000000000000000" || quote.Rate != 3000 {
+		t.Fatalf("unexpected quote %+v", quote)
+	}
+	if quote.Expired(time.Now().Unix()) || !quote.Expired(time.Now().Add(time.Hour).Unix()) {
+		t.Fatalf("unexpected expiry %d", quote.ExpireAt)
+	}
+
+	quote, err = quoter.Quote(context.Background(), "USDC", 6, "USD", 12.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Amount != 12.5 || quote.Value != "12500000" {
+		t.Fatalf("unexpected quote %+v", quote)
+	}
+
+	// an amount which can not be divided is rounded up
+	if amount, value := Convert(10, 3, 6); amount != 3.333334 || value.String() != "3333334" {
+		t.Fatalf("unexpected amount %v %s", amount, value)
+	}
+	if amount, value := Convert(1, 3, 0); amount != 1 || value.String() != "1" {
+		t.Fatalf("unexpected amount %v %s", amount, value)
+	}
+	if _, err := quoter.Quote(context.Background(), "ETH", 18, "EUR", 10); !errors.Is(err, ErrPriceNotFound) {
+		t.Fatalf("expected price not found, got %v", err)
+	}
+	if _, err := quoter.Quote(context.Background(), "ETH", 18, "USD", 0); !errors.Is(err, ErrInvalidPrice) {
+		t.Fatalf("expected invalid price, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/protos"
+	"github.com/shopspring/decimal"
+)
+
+// Precision - the max decimals of a quoted amount.
+const Precision = 6
+
+// Quoter - lock the rate of the token for the ttl.
+type Quoter struct {
+	oracle Oracle
+	ttl    time.Duration
+}
+
+func NewQuoter(oracle Oracle, ttl time.Duration) *Quoter {
+	return &Quoter{
+		oracle: oracle,
+		ttl:    ttl,
+	}
+}
+
+// Quote - convert the total in the fiat currency into the token, the amount
+// is rounded up so the order is never underpaid.
+func (q *Quoter) Quote(ctx context.Context, symbol string, decimals int, currency string, total float64) (*protos.Quote, error) {
+	if total <= 0 {
+		return nil, errors.Join(ErrInvalidPrice, fmt.Errorf("total %v", total))
+	}
+	rate, err := q.oracle.Price(ctx, symbol, currency)
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		return nil, errors.Join(ErrInvalidPrice, fmt.Errorf("pair %s", Pair(symbol, currency)))
+	}
+	amount, value := Convert(total, rate, decimals)
+
+	now := time.Now()
+	return &protos.Quote{
+		Symbol:    symbol,
+		Currency:  currency,
+		Rate:      rate,
+		Total:     total,
+		Amount:    amount,
+		Value:     value.String(),
+		CreatedAt: now.Unix(),
+		ExpireAt:  now.Add(q.ttl).Unix(),
+	}, nil
+}
+
+// Convert - the amount of the token for the total at the rate, rounded up
+// to the precision, and the same amount in the smallest unit of the token.
+func Convert(total, rate float64, decimals int) (float64, decimal.Decimal) {
+	places := int32(min(Precision, decimals))
+	amount := decimal.NewFromFloat(total).Div(decimal.NewFromFloat(rate)).RoundCeil(places)
+	value := amount.Shift(int32(decimals))
+	result, _ := amount.Float64()
+	return result, value
+}
@@ -0,0 +1,53 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StaticOracle - fixed prices by pair, for the tests and the local runs.
+type StaticOracle struct {
+	prices map[string]float64
+}
+
+// NewStaticOracle - the prices are keyed by pair, e.g. "ETH/USD": 3000.
+func NewStaticOracle(prices map[string]float64) (*StaticOracle, error) {
+	o := &StaticOracle{prices: make(map[string]float64, len(prices))}
+	for key, price := range prices {
+		if price <= 0 {
+			return nil, errors.Join(ErrInvalidPrice, fmt.Errorf("pair %s", key))
+		}
+		symbol, currency, _ := strings.Cut(key, "/")
+		if symbol == "" || currency == "" {
+			return nil, errors.Join(ErrInvalidPrice, fmt.Errorf("pair %s is not symbol/currency", key))
+		}
+		o.prices[Pair(symbol, currency)] = price
+	}
+	return o, nil
+}
+
+// LoadStaticOracle - read the prices from a yaml file of pairs.
+func LoadStaticOracle(path string) (*StaticOracle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]float64)
+	if err := yaml.Unmarshal(data, &prices); err != nil {
+		return nil, err
+	}
+	return NewStaticOracle(prices)
+}
+
+func (o *StaticOracle) Price(ctx context.Context, symbol, currency string) (float64, error) {
+	price, ok := o.prices[Pair(symbol, currency)]
+	if !ok {
+		return 0, errors.Join(ErrPriceNotFound, fmt.Errorf("pair %s", Pair(symbol, currency)))
+	}
+	return price, nil
+}
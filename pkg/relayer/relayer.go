@@ -0,0 +1,265 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrGasBudget is returned when the gas of the calls exceeds the budget.
+	ErrGasBudget = errors.New("gas budget exceeded")
+
+	// ErrEthClient is returned when ethereum client error.
+	ErrEthClient = errors.New("ethereum client error")
+
+	// ErrSign is returned when sign error.
+	ErrSign = errors.New("sign error")
+)
+
+// Budget - the limits of the gas paid by the relayer, zero is unlimited.
+// The max gas is per transaction, the max fee cap is per gas and the daily
+// budget is in wei.
+type Budget struct {
+	MaxGas    uint64
+	MaxFeeCap *big.Int
+	Daily     *big.Int
+}
+
+// Call - a contract call sent by the relayer, the gas is estimated when it
+// is zero. The value is in wei, nil sends none.
+type Call struct {
+	To    common.Address
+	Data  []byte
+	Gas   uint64
+	Value *big.Int
+}
+
+// Nonces - the nonces of the relayer shared with the other instances.
+type Nonces interface {
+	// Sign - sign the transaction with the next nonce.
+	// @param ctx context.Context
+	// @param tx *types.Transaction: the nonce is replaced
+	// @return *types.Transaction: the signed transaction
+	// @return error
+	Sign(ctx context.Context, tx *types.Transaction) (*types.Transaction, error)
+	// Send - broadcast the signed transaction.
+	// @param ctx context.Context
+	// @param tx *types.Transaction
+	// @return error
+	Send(ctx context.Context, tx *types.Transaction) error
+	// Abandon - release the nonce of the signed transaction which is never
+	// sent.
+	// @param ctx context.Context
+	// @param tx *types.Transaction
+	// @return error
+	Abandon(ctx context.Context, tx *types.Transaction) error
+}
+
+// Relayer - the merchant signer sending the transactions of the buyers, it
+// manages its own nonce so concurrent payments do not collide, or uses the
+// shared nonces when the relayer runs on several instances.
+type Relayer struct {
+	client  chain.Client
+	chainId *big.Int
+	signer  erc20.Signer
+	address common.Address
+	budget  Budget
+	nonces  Nonces
+
+	mu     sync.Mutex
+	nonce  uint64
+	synced bool
+	spent  *big.Int
+	day    int64
+}
+
+func NewRelayer(client chain.Client, chainId *big.Int, signer erc20.Signer, budget Budget) *Relayer {
+	return &Relayer{
+		client:  client,
+		chainId: chainId,
+		signer:  signer,
+		address: signer.Address(),
+		budget:  budget,
+		spent:   big.NewInt(0),
+	}
+}
+
+// Address - the address of the relayer, the spender of the permits.
+func (r *Relayer) Address() common.Address {
+	return r.address
+}
+
+// Signer - the signer of the relayer.
+func (r *Relayer) Signer() erc20.Signer {
+	return r.signer
+}
+
+// UseNonces - sign and send with the shared nonces instead of the nonce kept
+// in memory.
+func (r *Relayer) UseNonces(nonces Nonces) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nonces = nonces
+}
+
+// Sign - sign the calls with consecutive nonces. The max cost of all calls
+// is charged to the daily budget, whether they are mined or not.
+func (r *Relayer) Sign(ctx context.Context, calls ...Call) ([]*types.Transaction, error) {
+	tip, err := r.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	baseFee, err := chain.BaseFee(ctx, r.client)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	// leave room for the base fee to double before the transactions are mined
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(baseFee, big.NewInt(2)))
+	if r.budget.MaxFeeCap != nil && r.budget.MaxFeeCap.Sign() > 0 && feeCap.Cmp(r.budget.MaxFeeCap) > 0 {
+		if new(big.Int).Add(tip, baseFee).Cmp(r.budget.MaxFeeCap) > 0 {
+			return nil, errors.Join(ErrGasBudget, fmt.Errorf("base fee %s is over the max fee cap", baseFee))
+		}
+		feeCap = new(big.Int).Set(r.budget.MaxFeeCap)
+	}
+
+	gas := make([]uint64, len(calls))
+	values := make([]*big.Int, len(calls))
+	cost := big.NewInt(0)
+	for i, call := range calls {
+		values[i] = call.Value
+		if values[i] == nil {
+			values[i] = big.NewInt(0)
+		}
+		gas[i] = call.Gas
+		if gas[i] == 0 {
+			gas[i], err = r.client.EstimateGas(ctx, ethereum.CallMsg{From: r.address, To: &call.To, Data: call.Data, Value: values[i]})
+			if err != nil {
+				return nil, errors.Join(ErrEthClient, err)
+			}
+		}
+		if r.budget.MaxGas > 0 && gas[i] > r.budget.MaxGas {
+			return nil, errors.Join(ErrGasBudget, fmt.Errorf("gas %d is over the max gas", gas[i]))
+		}
+		cost.Add(cost, new(big.Int).Mul(new(big.Int).SetUint64(gas[i]), feeCap))
+		cost.Add(cost, values[i])
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if day := time.Now().Unix() / 86400; day != r.day {
+		r.day, r.spent = day, big.NewInt(0)
+	}
+	if r.budget.Daily != nil && r.budget.Daily.Sign() > 0 && new(big.Int).Add(r.spent, cost).Cmp(r.budget.Daily) > 0 {
+		return nil, errors.Join(ErrGasBudget, fmt.Errorf("daily budget %s is spent", r.budget.Daily))
+	}
+	if !r.synced && r.nonces == nil {
+		r.nonce, err = r.client.PendingNonceAt(ctx, r.address)
+		if err != nil {
+			return nil, errors.Join(ErrEthClient, err)
+		}
+		r.synced = true
+	}
+
+	txs := make([]*types.Transaction, len(calls))
+	for i, call := range calls {
+		to := call.To
+		tx := types.NewTx(&types.DynamicFeeTx{
+			ChainID:   r.chainId,
+			Nonce:     r.nonce + uint64(i),
+			GasTipCap: tip,
+			GasFeeCap: feeCap,
+			Gas:       gas[i],
+			To:        &to,
+			Data:      call.Data,
+			Value:     values[i],
+		})
+		if r.nonces != nil {
+			txs[i], err = r.nonces.Sign(ctx, tx)
+		} else {
+			txs[i], err = r.signer.SignTx(ctx, tx, r.chainId)
+		}
+		if err != nil {
+			return nil, errors.Join(ErrSign, err)
+		}
+	}
+	if r.nonces == nil {
+		r.nonce += uint64(len(calls))
+	}
+	r.spent.Add(r.spent, cost)
+	return txs, nil
+}
+
+// Send - broadcast the signed transactions in order. A failure which may
+// follow the broadcast, e.g. a timeout, does not stop the transactions after
+// it. A transaction which the node rejects stops the send, the transactions
+// after it are abandoned. The nonce in memory is synced with the chain
+// again when one of them fails.
+func (r *Relayer) Send(ctx context.Context, txs ...*types.Transaction) error {
+	r.mu.Lock()
+	nonces := r.nonces
+	r.mu.Unlock()
+	var errs []error
+	for i, tx := range txs {
+		var err error
+		if nonces != nil {
+			err = nonces.Send(ctx, tx)
+		} else {
+			err = r.client.SendTransaction(ctx, tx)
+		}
+		if err == nil {
+			continue
+		}
+		errs = append(errs, err)
+		if nonces == nil {
+			r.Reset()
+		}
+		if chain.IsRejected(err) {
+			return errors.Join(ErrEthClient, errors.Join(errs...), r.Abandon(ctx, txs[i+1:]...))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(ErrEthClient, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Reset - sync the nonce with the chain on the next sign, the signed
+// transactions which are never sent leave a gap otherwise.
+func (r *Relayer) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.synced = false
+}
+
+// Abandon - release the nonces of the signed transactions which are never
+// sent. The shared nonces mark them failed, the nonce in memory is synced
+// with the chain again.
+func (r *Relayer) Abandon(ctx context.Context, txs ...*types.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	nonces := r.nonces
+	r.mu.Unlock()
+	if nonces == nil {
+		r.Reset()
+		return nil
+	}
+	var errs []error
+	for _, tx := range txs {
+		if err := nonces.Abandon(ctx, tx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
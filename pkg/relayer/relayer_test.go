@@ -0,0 +1,227 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/erc20"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// feeClient - a client with fixed fees which records the sent transactions.
+type feeClient struct {
+	chain.Client
+	nonce   uint64
+	baseFee *big.Int
+	sent    []*types.Transaction
+	fail    bool
+}
+
+func (c *feeClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (c *feeClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: c.baseFee}, nil
+}
+
+func (c *feeClient) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 50000, nil
+}
+
+func (c *feeClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return c.nonce, nil
+}
+
+func (c *feeClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if c.fail {
+		return errors.New("nonce too low")
+	}
+	c.sent = append(c.sent, tx)
+	c.nonce = tx.Nonce() + 1
+	return nil
+}
+
+func TestRelayer(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &feeClient{nonce: 7, baseFee: big.NewInt(1e9)}
+	budget := Budget{MaxGas: 100000, MaxFeeCap: big.NewInt(5e9), Daily: big.NewInt(1e15)}
+	r := NewRelayer(client, big.NewInt(1337), erc20.NewKeySigner(key), budget)
+	token := common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+
+	txs, err := r.Sign(context.Background(), Call{To: token}, Call{To: token, Gas: 80000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs[0].Nonce() != 7 || txs[1].Nonce() != 8 || txs[0].Gas() != 50000 || txs[1].Gas() != 80000 {
+		t.Fatalf("unexpected transactions %d %d", txs[0].Nonce(), txs[1].Nonce())
+	}
+	if txs[0].GasFeeCap().Cmp(big.NewInt(3e9)) != 0 {
+		t.Fatalf("unexpected fee cap %s", txs[0].GasFeeCap())
+	}
+	sender, err := types.Sender(types.NewLondonSigner(big.NewInt(1337)), txs[0])
+	if err != nil || sender != r.Address() {
+		t.Fatalf("unexpected sender %s, %v", sender.Hex(), err)
+	}
+	if err := r.Send(context.Background(), txs...); err != nil {
+		t.Fatal(err)
+	}
+
+	// the nonce is managed locally until a send fails
+	txs, err = r.Sign(context.Background(), Call{To: token})
+	if err != nil || txs[0].Nonce() != 9 {
+		t.Fatalf("unexpected nonce, %v", err)
+	}
+	client.fail = true
+	if err := r.Send(context.Background(), txs...); err == nil {
+		t.Fatal("expected send error")
+	}
+	client.fail = false
+	txs, err = r.Sign(context.Background(), Call{To: token})
+	if err != nil || txs[0].Nonce() != 9 {
+		t.Fatalf("expected the nonce synced again, %v", err)
+	}
+
+	// the fee cap is capped, the base fee over it is rejected
+	client.baseFee = big.NewInt(3e9)
+	if txs, err = r.Sign(context.Background(), Call{To: token}); err != nil || txs[0].GasFeeCap().Cmp(big.NewInt(5e9)) != 0 {
+		t.Fatalf("expected the capped fee cap, %v", err)
+	}
+	client.baseFee = big.NewInt(5e9)
+	if _, err := r.Sign(context.Background(), Call{To: token}); !errors.Is(err, ErrGasBudget) {
+		t.Fatalf("expected gas budget, got %v", err)
+	}
+	client.baseFee = big.NewInt(1e9)
+	if _, err := r.Sign(context.Background(), Call{To: token, Gas: 200000}); !errors.Is(err, ErrGasBudget) {
+		t.Fatalf("expected gas budget, got %v", err)
+	}
+}
+
+func TestDailyBudget(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &feeClient{baseFee: big.NewInt(1e9)}
+	// two calls of 50000 gas at 3 gwei
+	r := NewRelayer(client, big.NewInt(1337), erc20.NewKeySigner(key), Budget{Daily: big.NewInt(3e14)})
+	token := common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+	if _, err := r.Sign(context.Background(), Call{To: token}, Call{To: token}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Sign(context.Background(), Call{To: token}); !errors.Is(err, ErrGasBudget) {
+		t.Fatalf("expected gas budget, got %v", err)
+	}
+}
+
+// sharedNonces - nonces handed out by another instance.
+type sharedNonces struct {
+	signer    erc20.Signer
+	next      uint64
+	sent      []*types.Transaction
+	abandoned []uint64
+	fail      error
+}
+
+func (n *sharedNonces) Sign(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	tx = types.NewTx(&types.DynamicFeeTx{
+		ChainID:   tx.ChainId(),
+		Nonce:     n.next,
+		GasTipCap: tx.GasTipCap(),
+		GasFeeCap: tx.GasFeeCap(),
+		Gas:       tx.Gas(),
+		To:        tx.To(),
+		Data:      tx.Data(),
+		Value:     tx.Value(),
+	})
+	n.next++
+	return n.signer.SignTx(ctx, tx, tx.ChainId())
+}
+
+func (n *sharedNonces) Send(ctx context.Context, tx *types.Transaction) error {
+	n.sent = append(n.sent, tx)
+	if n.fail != nil {
+		return n.fail
+	}
+	return nil
+}
+
+func (n *sharedNonces) Abandon(ctx context.Context, tx *types.Transaction) error {
+	n.abandoned = append(n.abandoned, tx.Nonce())
+	return nil
+}
+
+func TestSharedNonces(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &feeClient{nonce: 7, baseFee: big.NewInt(1e9)}
+	r := NewRelayer(client, big.NewInt(1337), erc20.NewKeySigner(key), Budget{})
+	nonces := &sharedNonces{signer: r.Signer(), next: 20}
+	r.UseNonces(nonces)
+	token := common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238")
+
+	txs, err := r.Sign(context.Background(), Call{To: token}, Call{To: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs[0].Nonce() != 20 || txs[1].Nonce() != 21 {
+		t.Fatalf("unexpected nonces %d %d", txs[0].Nonce(), txs[1].Nonce())
+	}
+	if err := r.Send(context.Background(), txs...); err != nil {
+		t.Fatal(err)
+	}
+	if len(nonces.sent) != 2 || len(client.sent) != 0 {
+		t.Fatalf("expected the shared nonces to send, got %d and %d", len(nonces.sent), len(client.sent))
+	}
+
+	// the transactions which are never sent release their nonces
+	txs, err = r.Sign(context.Background(), Call{To: token}, Call{To: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Abandon(context.Background(), txs...); err != nil {
+		t.Fatal(err)
+	}
+	if len(nonces.abandoned) != 2 || nonces.abandoned[0] != 22 || nonces.abandoned[1] != 23 {
+		t.Fatalf("unexpected abandoned nonces %v", nonces.abandoned)
+	}
+
+	// the transactions after a failed send are abandoned
+	nonces.abandoned = nil
+	txs, err = r.Sign(context.Background(), Call{To: token}, Call{To: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces.fail = errors.New("insufficient funds for gas * price + value")
+	if err := r.Send(context.Background(), txs...); !errors.Is(err, ErrEthClient) {
+		t.Fatalf("expected send error, got %v", err)
+	}
+	if len(nonces.abandoned) != 1 || nonces.abandoned[0] != 25 {
+		t.Fatalf("expected the unsent nonce 25 abandoned, got %v", nonces.abandoned)
+	}
+
+	// a send which may follow the broadcast does not stop the transactions after it
+	nonces.abandoned, nonces.sent = nil, nil
+	txs, err = r.Sign(context.Background(), Call{To: token}, Call{To: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces.fail = context.DeadlineExceeded
+	if err := r.Send(context.Background(), txs...); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the timeout, got %v", err)
+	}
+	if len(nonces.sent) != 2 || len(nonces.abandoned) != 0 {
+		t.Fatalf("expected both transactions sent, got %d sent and %v abandoned", len(nonces.sent), nonces.abandoned)
+	}
+}
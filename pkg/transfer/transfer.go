@@ -0,0 +1,166 @@
+// Package transfer - the unsigned EIP-1559 transfers prepared for the
+// wallets and the checks of the signed ones, shared by the native currency
+// and the tokens. A native transfer sends its value to the recipient, a
+// token transfer calls the contract with its calldata.
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0x726f6f6b6965/web3-ecommerce/pkg/chain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrInvalidAddress is returned when an invalid address is provided.
+	ErrInvalidAddress = errors.New("invalid address")
+
+	// ErrInvalidAmount is returned when an invalid amount is provided.
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrInvalidSignature is returned when an invalid signature is provided.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrInvalidTransaction is returned when an invalid transaction is provided.
+	ErrInvalidTransaction = errors.New("invalid transaction")
+
+	// ErrEthClient is returned when ethereum client error.
+	ErrEthClient = errors.New("ethereum client error")
+
+	// ErrSign is returned when sign error.
+	ErrSign = errors.New("sign error")
+)
+
+// Transfer - what the transaction sends from the sender to the recipient,
+// the value of a native transfer or the calldata of a token contract, whose
+// value is 0.
+type Transfer struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+	Data  []byte
+}
+
+// value - the value of the transaction, 0 for a contract call.
+func (t Transfer) value() *big.Int {
+	if t.Value == nil {
+		return big.NewInt(0)
+	}
+	return t.Value
+}
+
+func (t Transfer) callMsg() ethereum.CallMsg {
+	return ethereum.CallMsg{
+		From:  t.From,
+		To:    &t.To,
+		Value: t.Value,
+		Data:  t.Data,
+	}
+}
+
+// Estimate - the gas of the transfer.
+func Estimate(ctx context.Context, client chain.Client, t Transfer) (uint64, error) {
+	gas, err := client.EstimateGas(ctx, t.callMsg())
+	if err != nil {
+		return 0, errors.Join(ErrEthClient, err)
+	}
+	return gas, nil
+}
+
+// Prepare - build the unsigned EIP-1559 transfer with estimated gas,
+// suggested fees and the pending nonce of the sender.
+// @param ctx - context
+// @param client - client of the chain
+// @param chainId - id of the chain
+// @param t - the transfer
+// @return transaction
+// @return error
+func Prepare(ctx context.Context, client chain.Client, chainId *big.Int, t Transfer) (*types.Transaction, error) {
+	nonce, err := client.PendingNonceAt(ctx, t.From)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	gas, err := Estimate(ctx, client, t)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	baseFee, err := chain.BaseFee(ctx, client)
+	if err != nil {
+		return nil, errors.Join(ErrEthClient, err)
+	}
+	// leave room for the base fee to double before the transaction is mined
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(baseFee, big.NewInt(2)))
+
+	to := t.To
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainId,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &to,
+		Data:      t.Data,
+		Value:     t.value(),
+	}), nil
+}
+
+// SignPrepared - attach the signature to the prepared transaction and make
+// sure it was signed by the sender.
+// @param chainId - id of the chain
+// @param prepared - prepared transaction
+// @param from - sender address
+// @param signature - signature
+// @return transaction
+// @return error
+func SignPrepared(chainId *big.Int, prepared *types.Transaction, from string, signature []byte) (*types.Transaction, error) {
+	signer := types.NewLondonSigner(chainId)
+	signTx, err := prepared.WithSignature(signer, signature)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	return checkSender(signer, signTx, from)
+}
+
+// CheckSigned - make sure the signed transaction is the transfer on the
+// chain, signed by its sender.
+// @param chainId - id of the chain
+// @param signTx - signed transaction
+// @param t - the transfer
+// @return transaction
+// @return error
+func CheckSigned(chainId *big.Int, signTx *types.Transaction, t Transfer) (*types.Transaction, error) {
+	if signTx.ChainId().Cmp(chainId) != 0 {
+		return nil, errors.Join(ErrInvalidTransaction, fmt.Errorf("chain id %s is not %s", signTx.ChainId(), chainId))
+	}
+	if signTx.To() == nil || *signTx.To() != t.To {
+		return nil, errors.Join(ErrInvalidAddress, fmt.Errorf("to address is invalid"))
+	}
+	if signTx.Value().Cmp(t.value()) != 0 {
+		return nil, errors.Join(ErrInvalidAmount, fmt.Errorf("transaction value %s is not %s", signTx.Value(), t.value()))
+	}
+	if !bytes.Equal(signTx.Data(), t.Data) {
+		return nil, errors.Join(ErrInvalidTransaction, fmt.Errorf("transaction data is not the transfer"))
+	}
+	return checkSender(types.LatestSignerForChainID(chainId), signTx, t.From.Hex())
+}
+
+func checkSender(signer types.Signer, signTx *types.Transaction, from string) (*types.Transaction, error) {
+	sender, err := signer.Sender(signTx)
+	if err != nil {
+		return nil, errors.Join(ErrSign, err)
+	}
+	if !bytes.Equal(sender.Bytes(), common.HexToAddress(from).Bytes()) {
+		return nil, ErrInvalidSignature
+	}
+	return signTx, nil
+}
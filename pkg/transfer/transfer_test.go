@@ -0,0 +1,64 @@
+package transfer
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestCheckSignedCall(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainId := big.NewInt(11155111)
+	call := Transfer{
+		From: crypto.PubkeyToAddress(privateKey.PublicKey),
+		To:   common.HexToAddress("0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"),
+		Data: common.FromHex("0xa9059cbb"),
+	}
+	prepared := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainId,
+		Nonce:     1,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(2e9),
+		Gas:       60000,
+		To:        &call.To,
+		Data:      call.Data,
+		Value:     call.value(),
+	})
+	signer := types.NewLondonSigner(chainId)
+	signature, err := crypto.Sign(signer.Hash(prepared).Bytes(), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signTx, err := SignPrepared(chainId, prepared, call.From.Hex(), signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckSigned(chainId, signTx, call); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SignPrepared(chainId, prepared, call.To.Hex(), signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected the signature of another sender to be invalid, got %v", err)
+	}
+
+	other := call
+	other.Data = common.FromHex("0x095ea7b3")
+	if _, err := CheckSigned(chainId, signTx, other); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("expected the other calldata to be invalid, got %v", err)
+	}
+	other = call
+	other.Value = big.NewInt(1)
+	if _, err := CheckSigned(chainId, signTx, other); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("expected the value to be invalid, got %v", err)
+	}
+	if _, err := CheckSigned(big.NewInt(1), signTx, call); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("expected the chain id to be invalid, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package protos
+
+type IdempotencyStatus int
+
+const (
+	IdempotencyUnknow IdempotencyStatus = iota
+	IdempotencyInProgress
+	IdempotencyCompleted
+)
+
+func (s IdempotencyStatus) String() string {
+	switch s {
+	case IdempotencyInProgress:
+		return "in_progress"
+	case IdempotencyCompleted:
+		return "completed"
+	default:
+		return "unknow"
+	}
+}
+
+// Idempotency - the response produced by a request with an idempotency key.
+type Idempotency struct {
+	Key           string            `json:"key" dynamodbav:"sk"`
+	PublicAddress string            `json:"public_address" dynamodbav:"pk"`
+	OrderId       string            `json:"order_id" dynamodbav:"order_id"`
+	Status        IdempotencyStatus `json:"status" dynamodbav:"status"`
+	Response      string            `json:"response,omitempty" dynamodbav:"response,omitempty"`
+
+	CreatedAt int64 `dynamodbav:"created_at" json:"created_at"`
+	UpdatedAt int64 `dynamodbav:"updated_at" json:"updated_at"`
+	ExpireAt  int64 `dynamodbav:"expire_at" json:"expire_at"`
+}
@@ -3,11 +3,36 @@ package protos
 type CreateMonitorRequest struct {
 	OrderId   string   `json:"order_id"`
 	Table     string   `json:"table"`
+	ChainId   uint64   `json:"chain_id,omitempty"`
 	Contract  string   `json:"contract"`
 	Topics    []string `json:"topics"`
 	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Native    bool     `json:"native,omitempty"`
+	Value     string   `json:"value,omitempty"`
 	FromBlock uint64   `json:"from_block"`
 	TxHash    string   `json:"tx_hash" dynamodbav:"payment_hash,omitempty"`
+
+	// Nonce - the authorization nonce, the payment is matched by its
+	// AuthorizationUsed log whoever submits it
+	Nonce string `json:"nonce,omitempty"`
+
+	// Sender, SenderNonce - the signer and the nonce of the transaction, a
+	// transaction mined with the same nonce replaced it
+	Sender      string  `json:"sender,omitempty"`
+	SenderNonce *uint64 `json:"sender_nonce,omitempty"`
+
+	// Refund - the transaction is a refund of the order sent by the treasury
+	Refund *RefundMonitor `json:"refund,omitempty"`
+}
+
+// RefundMonitor - the order status once the refund is confirmed and the
+// status restored when it fails.
+type RefundMonitor struct {
+	Id       string  `json:"id"`
+	Amount   float64 `json:"amount"`
+	Status   Status  `json:"status"`
+	Previous Status  `json:"previous"`
 }
 
 type UpdateTrans struct {
@@ -17,3 +42,42 @@ type UpdateTrans struct {
 	From    string `json:"from"`
 	Status  Status `json:"status"`
 }
+
+type OutboxStatus int
+
+const (
+	OutboxUnknow OutboxStatus = iota
+	OutboxPending
+	OutboxSent
+	OutboxCancelled
+	// OutboxDead - the message failed to publish too many times
+	OutboxDead
+)
+
+func (s OutboxStatus) String() string {
+	switch s {
+	case OutboxPending:
+		return "pending"
+	case OutboxSent:
+		return "sent"
+	case OutboxCancelled:
+		return "cancelled"
+	case OutboxDead:
+		return "dead"
+	default:
+		return "unknow"
+	}
+}
+
+// Outbox - a monitor request waiting to be published to sqs.
+type Outbox struct {
+	Id        string                `json:"id" dynamodbav:"sk"`
+	Message   *CreateMonitorRequest `json:"message" dynamodbav:"message"`
+	Status    OutboxStatus          `json:"status" dynamodbav:"status"`
+	Attempts  int                   `json:"attempts" dynamodbav:"attempts"`
+	LastError string                `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+
+	CreatedAt int64 `dynamodbav:"created_at" json:"created_at"`
+	UpdatedAt int64 `dynamodbav:"updated_at" json:"updated_at"`
+	SentAt    int64 `dynamodbav:"sent_at,omitempty" json:"sent_at,omitempty"`
+}
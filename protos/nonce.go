@@ -0,0 +1,44 @@
+package protos
+
+type NonceStatus int
+
+const (
+	NonceUnknow NonceStatus = iota
+	// NonceReserved - the nonce is handed out, the transaction is not sent yet
+	NonceReserved
+	NoncePending
+	NonceMined
+	// NonceFailed - the transaction is not sent, the nonce is a gap until it is filled
+	NonceFailed
+)
+
+func (s NonceStatus) String() string {
+	switch s {
+	case NonceReserved:
+		return "reserved"
+	case NoncePending:
+		return "pending"
+	case NonceMined:
+		return "mined"
+	case NonceFailed:
+		return "failed"
+	default:
+		return "unknow"
+	}
+}
+
+// NonceTx - the transaction sent with a nonce of a merchant address. The raw
+// transaction is kept to replace it by fee, the replaced are the hashes of
+// the transactions it replaced.
+type NonceTx struct {
+	ChainId  uint64      `json:"chain_id" dynamodbav:"chain_id"`
+	Address  string      `json:"address" dynamodbav:"address"`
+	Nonce    uint64      `json:"nonce" dynamodbav:"nonce"`
+	TxHash   string      `json:"tx_hash" dynamodbav:"tx_hash"`
+	RawTx    string      `json:"raw_tx" dynamodbav:"raw_tx"`
+	Replaced []string    `json:"replaced,omitempty" dynamodbav:"replaced,omitempty"`
+	Status   NonceStatus `json:"status" dynamodbav:"status"`
+
+	CreatedAt int64 `dynamodbav:"created_at" json:"created_at"`
+	UpdatedAt int64 `dynamodbav:"updated_at" json:"updated_at"`
+}
@@ -6,16 +6,61 @@ type Order struct {
 	ProductIds   []OrderProducts `json:"product_ids"`
 	Address      string          `json:"address" dynamodbav:"address"`
 	Amount       float64         `json:"amount" dynamodbav:"amount"`
+	Total        float64         `json:"total,omitempty" dynamodbav:"total,omitempty"`
+	Currency     string          `json:"currency,omitempty" dynamodbav:"currency,omitempty"`
 	Status       Status          `json:"status" dynamodbav:"status"`
+	ChainId      uint64          `json:"chain_id,omitempty" dynamodbav:"chain_id,omitempty"`
 	Token        string          `json:"token,omitempty" dynamodbav:"token,omitempty"`
 	PaymentHash  string          `json:"payment_hash,omitempty" dynamodbav:"payment_hash,omitempty"`
 	ShipmentHash string          `json:"shipment_hash,omitempty" dynamodbav:"shipment_hash,omitempty"`
+	PreparedTx   string          `json:"prepared_tx,omitempty" dynamodbav:"prepared_tx,omitempty"`
+	Quote        *Quote          `json:"quote,omitempty" dynamodbav:"quote,omitempty"`
+	// PaymentSender, PaymentNonce - the signer and the nonce of the payment,
+	// the wallet can replace it by another transaction with the nonce. The
+	// payment block is the block the payment is watched from.
+	PaymentSender string  `json:"-" dynamodbav:"payment_sender,omitempty"`
+	PaymentNonce  *uint64 `json:"-" dynamodbav:"payment_nonce,omitempty"`
+	PaymentBlock  uint64  `json:"-" dynamodbav:"payment_block,omitempty"`
+	// AuthorizationNonce - the nonce of the EIP-3009 authorization paying the order
+	AuthorizationNonce string `json:"authorization_nonce,omitempty" dynamodbav:"authorization_nonce,omitempty"`
+	// Deposit - the order is paid to its own deposit address by any sender,
+	// the address is derived from the xpub by the index and watched from the block
+	Deposit        bool   `json:"deposit,omitempty" dynamodbav:"deposit,omitempty"`
+	DepositAddress string `json:"deposit_address,omitempty" dynamodbav:"deposit_address,omitempty"`
+	DepositIndex   uint32 `json:"-" dynamodbav:"deposit_index,omitempty"`
+	DepositBlock   uint64 `json:"-" dynamodbav:"deposit_block,omitempty"`
+	// SweepHash - the transfer of the deposit to the treasury, the fund hash
+	// is the gas sent to the deposit address before it
+	SweepHash     string `json:"-" dynamodbav:"sweep_hash,omitempty"`
+	SweepFundHash string `json:"-" dynamodbav:"sweep_fund_hash,omitempty"`
+	// Refunded - the token amount of the confirmed refunds
+	Refunded float64 `json:"refunded,omitempty" dynamodbav:"refunded,omitempty"`
 
+	// Version - goes up on every write, it is the ETag of the order
+	Version         uint64 `json:"version" dynamodbav:"version"`
 	StatusCreatedAt string `dynamodbav:"status_created_at,omitempty"`
 	CreatedAt       int64  `dynamodbav:"created_at" json:"created_at"`
 	UpdatedAt       int64  `dynamodbav:"updated_at" json:"updated_at"`
 }
 
+// Quote - the amount of the token for the fiat total of an order, the
+// rate is locked until the quote expires.
+type Quote struct {
+	Symbol    string  `json:"symbol" dynamodbav:"symbol"`
+	Currency  string  `json:"currency" dynamodbav:"currency"`
+	Rate      float64 `json:"rate" dynamodbav:"rate"`
+	Total     float64 `json:"total" dynamodbav:"total"`
+	Amount    float64 `json:"amount" dynamodbav:"amount"`
+	Value     string  `json:"value" dynamodbav:"value"`
+	CreatedAt int64   `json:"created_at" dynamodbav:"created_at"`
+	ExpireAt  int64   `json:"expire_at" dynamodbav:"expire_at"`
+}
+
+// Expired - whether the quote can no longer be paid.
+func (q *Quote) Expired(now int64) bool {
+	return q.ExpireAt > 0 && now >= q.ExpireAt
+}
+
 type OrderProducts struct {
 	Id       string  `json:"id"`
 	Price    float64 `json:"price"`
@@ -34,6 +79,9 @@ const (
 	StatusDelivered
 	StatusCancelled
 	StatusMonitorFailed
+	StatusRefundPending
+	StatusRefunded
+	StatusPartiallyRefunded
 )
 
 func (s Status) String() string {
@@ -54,6 +102,12 @@ func (s Status) String() string {
 		return "cancelled"
 	case StatusMonitorFailed:
 		return "monitor_failed"
+	case StatusRefundPending:
+		return "refund_pending"
+	case StatusRefunded:
+		return "refunded"
+	case StatusPartiallyRefunded:
+		return "partially_refunded"
 	default:
 		return "unknow"
 	}
@@ -6,8 +6,11 @@ type Product struct {
 	Description string  `json:"description" dynamodbav:"description"`
 	Image       string  `json:"image" dynamodbav:"image"`
 	Price       float64 `json:"price" dynamodbav:"price"`
+	Currency    string  `json:"currency" dynamodbav:"currency,omitempty"`
 	SoftDeleted int     `json:"soft_deleted" dynamodbav:"soft_deleted"`
 
-	CreatedAt int64 `dynamodbav:"created_at" json:"created_at"`
-	UpdatedAt int64 `dynamodbav:"updated_at" json:"updated_at"`
+	// Version - the ETag of the product, every write adds one
+	Version   uint64 `dynamodbav:"version" json:"version"`
+	CreatedAt int64  `dynamodbav:"created_at" json:"created_at"`
+	UpdatedAt int64  `dynamodbav:"updated_at" json:"updated_at"`
 }
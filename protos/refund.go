@@ -0,0 +1,50 @@
+package protos
+
+type RefundStatus int
+
+const (
+	RefundUnknow RefundStatus = iota
+	RefundPending
+	RefundConfirmed
+	RefundFailed
+)
+
+func (s RefundStatus) String() string {
+	switch s {
+	case RefundPending:
+		return "pending"
+	case RefundConfirmed:
+		return "confirmed"
+	case RefundFailed:
+		return "failed"
+	default:
+		return "unknow"
+	}
+}
+
+// Refund - a transfer from the treasury back to the buyer of the order, the
+// amount is in the token of the order and the value in its base unit.
+type Refund struct {
+	Id      string       `json:"id" dynamodbav:"sk"`
+	From    string       `json:"from" dynamodbav:"pk"`
+	OrderId string       `json:"order_id" dynamodbav:"order_id"`
+	To      string       `json:"to" dynamodbav:"to"`
+	ChainId uint64       `json:"chain_id" dynamodbav:"chain_id"`
+	Token   string       `json:"token" dynamodbav:"token"`
+	Amount  float64      `json:"amount" dynamodbav:"amount"`
+	Value   string       `json:"value" dynamodbav:"value"`
+	TxHash  string       `json:"tx_hash" dynamodbav:"tx_hash"`
+	Status  RefundStatus `json:"status" dynamodbav:"status"`
+	Reason  string       `json:"reason,omitempty" dynamodbav:"reason,omitempty"`
+
+	CreatedAt int64 `dynamodbav:"created_at" json:"created_at"`
+	UpdatedAt int64 `dynamodbav:"updated_at" json:"updated_at"`
+}
+
+// RefundRequest - the amount is the token amount to refund, zero refunds
+// what is left of the payment.
+type RefundRequest struct {
+	From   string  `json:"from"`
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
@@ -1,5 +1,7 @@
 package protos
 
+import "encoding/json"
+
 type CommonRequest struct {
 	From      string  `json:"from"`
 	To        string  `json:"to"`
@@ -50,7 +52,117 @@ type UpdateOrderStatusRequest struct {
 
 type PayRequest struct {
 	OrderId string         `json:"order_id"`
-	Pay     *CommonRequest `json:"pay"`
+	Pay     *CommonRequest `json:"pay,omitempty"`
+	// Signature - the signature over the prepared transaction
+	Signature string `json:"signature,omitempty"`
+	// SignedTx - the signed raw transfer transaction in hex
+	SignedTx string `json:"signed_tx,omitempty"`
+	// Permit - the signed EIP-2612 permit, the payment is sent by the relayer
+	Permit *PermitRequest `json:"permit,omitempty"`
+	// Authorization - the signed EIP-3009 authorization, the payment is sent
+	// by the relayer
+	Authorization *AuthorizationRequest `json:"authorization,omitempty"`
+}
+
+type PermitRequest struct {
+	Deadline  int64  `json:"deadline"`
+	Signature string `json:"signature"`
+}
+
+// AuthorizationRequest - the message of the signed transfer authorization,
+// the value is in the smallest unit of the token.
+type AuthorizationRequest struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Value       string `json:"value"`
+	ValidAfter  int64  `json:"valid_after"`
+	ValidBefore int64  `json:"valid_before"`
+	Nonce       string `json:"nonce"`
+	Signature   string `json:"signature"`
+}
+
+type PreparePaymentRequest struct {
+	OrderId string `json:"order_id"`
+}
+
+type PreparePaymentResponse struct {
+	OrderId string `json:"order_id"`
+	// Raw - the unsigned transaction in RLP hex
+	Raw string `json:"raw"`
+	// SigningHash - the hash the wallet signs
+	SigningHash string          `json:"signing_hash"`
+	Tx          TransactionArgs `json:"tx"`
+	// Permit - the EIP-2612 permit of the gasless checkout, empty when the
+	// token has no permit
+	Permit *PermitData `json:"permit,omitempty"`
+	// Authorization - the EIP-3009 authorization of the gasless checkout,
+	// empty when the token has no authorization
+	Authorization *AuthorizationData `json:"authorization,omitempty"`
+}
+
+// FeesResponse - the gas of the payment transfer of the order and the fees
+// by speed, the fees are in wei.
+type FeesResponse struct {
+	OrderId  string `json:"order_id"`
+	ChainId  uint64 `json:"chain_id"`
+	Gas      uint64 `json:"gas"`
+	BaseFee  string `json:"base_fee"`
+	Slow     Fee    `json:"slow"`
+	Standard Fee    `json:"standard"`
+	Fast     Fee    `json:"fast"`
+}
+
+// Fee - the EIP-1559 fees of a transaction in wei.
+type Fee struct {
+	GasTipCap string `json:"gas_tip_cap"`
+	GasFeeCap string `json:"gas_fee_cap"`
+}
+
+// BalanceResponse - the balance of the address, the value is in wei and the
+// amount is in the unit of the token.
+type BalanceResponse struct {
+	ChainId uint64 `json:"chain_id"`
+	Token   string `json:"token"`
+	Address string `json:"address"`
+	Value   string `json:"value"`
+	Amount  string `json:"amount"`
+}
+
+// AllowanceResponse - the allowance of the owner for the spender, the value
+// is in wei and the amount is in the unit of the token.
+type AllowanceResponse struct {
+	ChainId uint64 `json:"chain_id"`
+	Token   string `json:"token"`
+	Owner   string `json:"owner"`
+	Spender string `json:"spender"`
+	Value   string `json:"value"`
+	Amount  string `json:"amount"`
+}
+
+// PermitData - the typed data of the permit for eth_signTypedData_v4.
+type PermitData struct {
+	Spender   string          `json:"spender"`
+	Deadline  int64           `json:"deadline"`
+	TypedData json.RawMessage `json:"typed_data"`
+}
+
+// AuthorizationData - the typed data of the authorization for eth_signTypedData_v4.
+type AuthorizationData struct {
+	TypedData json.RawMessage `json:"typed_data"`
+}
+
+// TransactionArgs - the JSON form of a transaction for the wallet.
+type TransactionArgs struct {
+	Type                 string `json:"type"`
+	ChainId              string `json:"chainId"`
+	From                 string `json:"from"`
+	To                   string `json:"to"`
+	Nonce                string `json:"nonce"`
+	Gas                  string `json:"gas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	Value                string `json:"value"`
+	Data                 string `json:"data"`
 }
 
 type TestRequest struct {
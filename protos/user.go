@@ -6,8 +6,10 @@ type User struct {
 	Email         string             `json:"email" dynamodbav:"email"`
 	Addresses     map[string]Address `json:"addresses" dynamodbav:"addresses"`
 
-	CreatedAt int64 `dynamodbav:"created_at" json:"created_at"`
-	UpdatedAt int64 `dynamodbav:"updated_at" json:"updated_at"`
+	// Version - the ETag of the user, every write adds one
+	Version   uint64 `dynamodbav:"version" json:"version"`
+	CreatedAt int64  `dynamodbav:"created_at" json:"created_at"`
+	UpdatedAt int64  `dynamodbav:"updated_at" json:"updated_at"`
 }
 
 type Address struct {
@@ -10,6 +10,7 @@ const (
 
 	ErrorCodeLogin    = 401
 	ErrorCodeNotFound = 404
+	ErrorCodeConflict = 409
 )
 
 var (
@@ -18,6 +19,7 @@ var (
 	InternalServerError  = ErrorString{ErrorCodeOfInternalServerError, "Service internal exception"}
 	ErrorCodeLoginError  = ErrorString{ErrorCodeLogin, "The account is not logged in, please login and try again"}
 	ErrorCodeNotFoundErr = ErrorString{ErrorCodeNotFound, "The resource is not found"}
+	ConflictErr          = ErrorString{ErrorCodeConflict, "The resource is modified by another request"}
 
 	ProtocolClientErr    = ErrorString{Code: ProtocolClientErrCode, Message: "client id or client secret error"}
 	ProtocolAssetTypeErr = ErrorString{Code: ProtocolAssetTypeErrCode, Message: "asset type invalid"}